@@ -1,10 +1,9 @@
 package main
 
 import (
-	"fmt"
 	"os"
 
-	"github.com/st-kuptsov/balabol/internal/app" // импорт внутреннего пакета приложения
+	"github.com/st-kuptsov/balabol/internal/cli" // командная строка приложения
 )
 
 // Version хранит текущую версию приложения.
@@ -14,12 +13,7 @@ var Version = "dev"
 
 // main — точка входа в приложение.
 func main() {
-	// Запуск основной логики приложения через функцию Run из пакета app.
-	// Передаём в неё текущую версию.
-	if err := app.Run(Version); err != nil {
-		// Если Run возвращает ошибку, выводим её в стандартный поток ошибок.
-		fmt.Fprintf(os.Stderr, "application failed: %v\n", err)
-		// Завершаем приложение с кодом 1 (означает ошибку).
-		os.Exit(1)
-	}
+	// Разбор аргументов и выполнение подкоманды (по умолчанию — run).
+	// Код завершения подкоманды становится кодом завершения процесса.
+	os.Exit(cli.Main(os.Args[1:], Version, os.Stdout, os.Stderr))
 }
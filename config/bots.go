@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultBotName — имя бота, который создаётся из корневых настроек,
+// если секция bots в конфигурации не задана.
+const DefaultBotName = "default"
+
+// resolveBots заполняет Config.Bots итоговыми настройками ботов.
+// Если секция bots пуста, создаётся один бот из корневых настроек.
+// Иначе каждому боту недостающие поля подставляются из корня конфигурации.
+func (c *Config) resolveBots() error {
+	if len(c.Bots) == 0 {
+		c.Bots = []BotConfig{{Name: DefaultBotName}}
+	}
+
+	names := make(map[string]bool, len(c.Bots))
+	listens := make(map[string]string)
+	for i := range c.Bots {
+		b := &c.Bots[i]
+		if b.Name == "" {
+			return fmt.Errorf("bots[%d]: name is required", i)
+		}
+		if names[b.Name] {
+			return fmt.Errorf("bots[%d]: duplicate bot name %q", i, b.Name)
+		}
+		names[b.Name] = true
+
+		// Наследуем незаданные настройки из корня конфигурации
+		if b.TokenSecret == "" {
+			b.TokenSecret = b.Name
+		}
+		if b.Rules == nil {
+			b.Rules = c.Rules
+			b.inheritRules = true
+		}
+		// Правила из файлов с bot: <имя> дополняют правила бота;
+		// Clip не даёт append изменить общий срез корневых правил
+		if len(b.fileRules) > 0 {
+			b.Rules = append(slices.Clip(b.Rules), b.fileRules...)
+		}
+		if b.BotMode == "" {
+			b.BotMode = c.BotMode
+		}
+		if b.CleanFilter == "" {
+			b.CleanFilter = c.CleanFilter
+		}
+		cleanRe, err := regexp.Compile(b.CleanFilter)
+		if err != nil {
+			return fmt.Errorf("bot %q: invalid clean_filter %q: %w", b.Name, b.CleanFilter, err)
+		}
+		b.cleanRe = cleanRe
+		b.Redact = c.Logging.Redact
+		b.I18n = c.I18n
+		b.SenderPolicy = c.SenderPolicy
+		b.Moderation = c.Moderation
+		b.Stats = c.Stats
+		b.SendQueue = c.SendQueue
+		b.DryRun = c.DryRun
+		b.Experiments = c.Experiments
+		if b.RemoveDup == nil {
+			removeDup := c.RemoveDup
+			b.RemoveDup = &removeDup
+		}
+		b.Telegram.inherit(c.Telegram)
+		b.Telegram.applyDefaults()
+		b.Telegram.Reactions = c.Experiments.Reactions
+
+		// Два webhook-сервера не могут слушать один адрес
+		if b.Telegram.Poller == PollerWebhook {
+			if other, ok := listens[b.Telegram.Webhook.Listen]; ok {
+				return fmt.Errorf("bots %q and %q: same webhook listen address %q", other, b.Name, b.Telegram.Webhook.Listen)
+			}
+			listens[b.Telegram.Webhook.Listen] = b.Name
+		}
+	}
+	return nil
+}
+
+// inherit подставляет незаданные настройки Telegram бота из корневой секции telegram.
+// Поля наследуются по отдельности: бот может задать, например, только poller или long_polling.timeout.
+// Флаги self_signed и drop_pending_updates включены, если они включены у бота или в корне.
+// Токен и секрет webhook берутся из файла секретов позже, в LoadSecrets.
+func (t *TelegramConfig) inherit(root TelegramConfig) {
+	if t.APIURL == "" {
+		t.APIURL = root.APIURL
+	}
+	if t.Poller == "" {
+		t.Poller = root.Poller
+	}
+	if t.LongPolling.Timeout == 0 {
+		t.LongPolling.Timeout = root.LongPolling.Timeout
+	}
+	w, rw := &t.Webhook, root.Webhook
+	if w.Listen == "" {
+		w.Listen = rw.Listen
+	}
+	if w.Path == "" {
+		w.Path = rw.Path
+	}
+	if w.PublicURL == "" {
+		w.PublicURL = rw.PublicURL
+	}
+	if w.CertFile == "" {
+		w.CertFile = rw.CertFile
+	}
+	if w.KeyFile == "" {
+		w.KeyFile = rw.KeyFile
+	}
+	if w.MaxConnections == 0 {
+		w.MaxConnections = rw.MaxConnections
+	}
+	w.SelfSigned = w.SelfSigned || rw.SelfSigned
+	w.DropPending = w.DropPending || rw.DropPending
+}
+
+// applyDefaults подставляет значения по умолчанию для настроек Telegram,
+// заданных внутри секции bots (cleanenv не обрабатывает элементы списков).
+func (t *TelegramConfig) applyDefaults() {
+	if t.APIURL == "" {
+		t.APIURL = DefaultAPIURL
+	}
+	t.APIURL = strings.TrimRight(t.APIURL, "/") // telebot добавляет /bot<token> к адресу
+	if t.Poller == "" {
+		t.Poller = PollerLongPolling
+	}
+	if t.LongPolling.Timeout == 0 {
+		t.LongPolling.Timeout = 10 * time.Second
+	}
+	if t.Webhook.Listen == "" {
+		t.Webhook.Listen = ":8443"
+	}
+	if t.Webhook.Path == "" {
+		t.Webhook.Path = "/telegram/webhook"
+	}
+	if t.Webhook.MaxConnections == 0 {
+		t.Webhook.MaxConnections = 40
+	}
+}
+
+// Bot возвращает настройки бота по имени или nil, если такого бота нет
+func (c *Config) Bot(name string) *BotConfig {
+	for i := range c.Bots {
+		if c.Bots[i].Name == name {
+			return &c.Bots[i]
+		}
+	}
+	return nil
+}
+
+// CleanRe возвращает скомпилированный clean_filter бота
+func (b *BotConfig) CleanRe() *regexp.Regexp {
+	return b.cleanRe
+}
+
+// RemoveDuplicates сообщает, нужно ли удалять повторяющиеся буквы
+func (b *BotConfig) RemoveDuplicates() bool {
+	return b.RemoveDup != nil && *b.RemoveDup
+}
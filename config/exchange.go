@@ -0,0 +1,493 @@
+package config
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Форматы импорта и экспорта правил (balabol rules import/export)
+const (
+	RulesFormatCSV  = "csv"  // таблица с колонками text, pattern, response
+	RulesFormatJSON = "json" // массив объектов {"text", "pattern", "response"}
+	RulesFormatYAML = "yaml" // файл правил с секцией rules
+)
+
+// Стратегии импорта правил
+const (
+	ImportMerge   = "merge"   // существующие правила сохраняются, импортированные добавляются или заменяют правила с тем же text
+	ImportReplace = "replace" // результат — только импортированные правила
+)
+
+// ruleRecord — правило в файлах импорта и экспорта: только поля, которые пишут люди
+type ruleRecord struct {
+	Text       string            `yaml:"text,omitempty" json:"text,omitempty"`
+	Pattern    string            `yaml:"pattern" json:"pattern"`
+	Response   string            `yaml:"response" json:"response"`
+	I18n       map[string]string `yaml:"responses_i18n,omitempty" json:"responses_i18n,omitempty"`
+	Actions    []Action          `yaml:"actions,omitempty" json:"actions,omitempty"`
+	Shadow     bool              `yaml:"shadow,omitempty" json:"shadow,omitempty"`
+	Experiment *Experiment       `yaml:"experiment,omitempty" json:"experiment,omitempty"`
+	err        string            // ошибка разбора колонки actions, shadow или experiment в CSV
+	errCol     string            // колонка с ошибкой
+}
+
+// csvHeader — колонки CSV при экспорте; за ними идут колонки response_<язык>
+var csvHeader = []string{"text", "pattern", "response"}
+
+// csvLangPrefix — префикс колонок CSV с ответами на других языках (response_en)
+const csvLangPrefix = "response_"
+
+// Необязательные колонки CSV
+const (
+	csvActions    = "actions"    // действия модерации в виде JSON-массива
+	csvShadow     = "shadow"     // теневое правило: true или пусто
+	csvExperiment = "experiment" // эксперимент в виде JSON-объекта
+)
+
+// records переводит правила в записи импорта и экспорта
+func records(rules []Rule) []ruleRecord {
+	out := make([]ruleRecord, len(rules))
+	for i, r := range rules {
+		out[i] = ruleRecord{Text: r.Text, Pattern: r.Pattern, Response: r.Response, I18n: r.I18n, Actions: r.Actions, Shadow: r.Shadow, Experiment: r.Experiment}
+	}
+	return out
+}
+
+// RuleConflict описывает расхождение импортированного правила с существующим
+type RuleConflict struct {
+	Existing Rule   // правило из конфигурации или базового файла
+	Imported Rule   // правило из импортируемого файла
+	Reason   string // в чём расхождение
+}
+
+// String возвращает описание конфликта в одну строку
+func (c RuleConflict) String() string {
+	return fmt.Sprintf("%q: %s", c.Imported.Text, c.Reason)
+}
+
+// RulesFormat определяет формат файла правил по расширению.
+// Возвращает пустую строку, если расширение неизвестно.
+func RulesFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return RulesFormatCSV
+	case ".json":
+		return RulesFormatJSON
+	case ".yaml", ".yml":
+		return RulesFormatYAML
+	}
+	return ""
+}
+
+// ExportRules записывает правила в указанном формате.
+// YAML-вывод — файл правил, пригодный для include, rules_dir или секции rules.
+//
+// Параметры:
+// - w: куда писать
+// - format: csv, json или yaml
+// - rules: экспортируемые правила
+//
+// Возвращает ошибку записи или неизвестного формата.
+func ExportRules(w io.Writer, format string, rules []Rule) error {
+	records := records(rules)
+
+	switch format {
+	case RulesFormatCSV:
+		// Колонка на каждый язык, встречающийся в responses_i18n, колонка actions,
+		// если хотя бы у одного правила есть действия, shadow, если есть теневые правила,
+		// и experiment, если есть эксперименты
+		var langs []string
+		withActions, withShadow, withExperiment := false, false, false
+		for _, r := range records {
+			for lang := range r.I18n {
+				if !slices.Contains(langs, lang) {
+					langs = append(langs, lang)
+				}
+			}
+			withActions = withActions || len(r.Actions) > 0
+			withShadow = withShadow || r.Shadow
+			withExperiment = withExperiment || r.Experiment != nil
+		}
+		sort.Strings(langs)
+
+		cw := csv.NewWriter(w)
+		header := slices.Clone(csvHeader)
+		for _, lang := range langs {
+			header = append(header, csvLangPrefix+lang)
+		}
+		if withActions {
+			header = append(header, csvActions)
+		}
+		if withShadow {
+			header = append(header, csvShadow)
+		}
+		if withExperiment {
+			header = append(header, csvExperiment)
+		}
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		for _, r := range records {
+			row := []string{r.Text, r.Pattern, r.Response}
+			for _, lang := range langs {
+				row = append(row, r.I18n[lang])
+			}
+			if withActions {
+				actions := ""
+				if len(r.Actions) > 0 {
+					data, err := json.Marshal(r.Actions)
+					if err != nil {
+						return err
+					}
+					actions = string(data)
+				}
+				row = append(row, actions)
+			}
+			if withShadow {
+				shadow := ""
+				if r.Shadow {
+					shadow = "true"
+				}
+				row = append(row, shadow)
+			}
+			if withExperiment {
+				experiment := ""
+				if r.Experiment != nil {
+					data, err := json.Marshal(r.Experiment)
+					if err != nil {
+						return err
+					}
+					experiment = string(data)
+				}
+				row = append(row, experiment)
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case RulesFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(records)
+	case RulesFormatYAML:
+		return writeRulesFile(w, "", records)
+	}
+	return fmt.Errorf("unknown rules format %q (want csv, json or yaml)", format)
+}
+
+// WriteRulesFile записывает правила как YAML-файл правил.
+// bot — значение поля bot файла (пусто — правила для корневой секции rules).
+// namespace и defaults не пишутся: в правилах они уже раскрыты.
+func WriteRulesFile(w io.Writer, bot string, rules []Rule) error {
+	return writeRulesFile(w, bot, records(rules))
+}
+
+// writeRulesFile кодирует записи правил в YAML
+func writeRulesFile(w io.Writer, bot string, records []ruleRecord) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(struct {
+		Bot   string       `yaml:"bot,omitempty"`
+		Rules []ruleRecord `yaml:"rules"`
+	}{bot, records}); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// ImportRules читает правила из файла импорта и компилирует каждое (Rule.Compile).
+// Все ошибки собираются сразу с номером строки CSV или элемента JSON,
+// YAML проверяется так же строго, как файлы правил.
+//
+// Параметры:
+// - name: имя файла для сообщений об ошибках
+// - data: содержимое файла
+// - format: csv, json или yaml
+//
+// Возвращает импортированные правила или ошибку.
+func ImportRules(name string, data []byte, format string) ([]Rule, error) {
+	var (
+		records []ruleRecord
+		where   []Problem // позиция каждой записи для сообщений об ошибках
+	)
+
+	switch format {
+	case RulesFormatCSV:
+		rows, lines, err := readCSV(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		records = rows
+		for _, line := range lines {
+			where = append(where, Problem{Line: line, Column: 1})
+		}
+	case RulesFormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&records); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for i := range records {
+			where = append(where, Problem{Path: fmt.Sprintf("[%d]", i)})
+		}
+	case RulesFormatYAML:
+		rf, err := ParseRulesFile(name, data)
+		if err != nil {
+			return nil, err
+		}
+		for i := range rf.Rules {
+			// Правила импорта ещё не принадлежат ни одному файлу конфигурации
+			rf.Rules[i].Source = ""
+		}
+		return rf.Rules, nil
+	default:
+		return nil, fmt.Errorf("unknown rules format %q (want csv, json or yaml)", format)
+	}
+
+	rules := make([]Rule, 0, len(records))
+	verr := &ValidationError{File: name}
+	for i, rec := range records {
+		r := Rule{Text: rec.Text, Pattern: rec.Pattern, Response: rec.Response, I18n: rec.I18n, Actions: rec.Actions, Shadow: rec.Shadow, Experiment: rec.Experiment}
+		p := where[i]
+		switch {
+		case rec.err != "":
+			p.Path, p.Message = joinPath(p.Path, rec.errCol), rec.err
+		case r.Pattern == "":
+			p.Path, p.Message = joinPath(p.Path, "pattern"), "missing pattern"
+		case r.Response == "" && r.Experiment == nil && len(r.Actions) == 0:
+			p.Path, p.Message = joinPath(p.Path, "response"), "missing response"
+		default:
+			if err := r.Compile(); err != nil {
+				// Ошибка регулярного выражения относится к pattern, остальные — к правилу целиком
+				if r.Re() == nil {
+					p.Path = joinPath(p.Path, "pattern")
+				}
+				p.Message = err.Error()
+			}
+		}
+		if p.Message != "" {
+			verr.Problems = append(verr.Problems, p)
+		}
+		rules = append(rules, r)
+	}
+	if len(verr.Problems) > 0 {
+		return nil, verr
+	}
+	return rules, nil
+}
+
+// readCSV читает CSV с заголовком. Колонки ищутся по имени без учёта регистра,
+// колонки response_<язык> попадают в responses_i18n (пустые ячейки пропускаются),
+// лишние колонки (например, комментарии редакторов) игнорируются, пустые строки пропускаются.
+// Возвращает записи и номера строк файла, с которых они начинаются.
+func readCSV(data []byte) ([]ruleRecord, []int, error) {
+	// Excel добавляет BOM в начало файла UTF-8
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("empty file, expected a header with text, pattern, response")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	col := map[string]int{"text": -1, "pattern": -1, "response": -1, csvActions: -1, csvShadow: -1, csvExperiment: -1}
+	langCols := make(map[string]int) // язык → колонка ответа
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if _, ok := col[h]; ok {
+			col[h] = i
+		} else if lang, ok := strings.CutPrefix(h, csvLangPrefix); ok {
+			langCols[lang] = i
+		}
+	}
+	for _, name := range []string{"pattern", "response"} {
+		if col[name] < 0 {
+			return nil, nil, fmt.Errorf("header has no %q column", name)
+		}
+	}
+
+	cell := func(row []string, name string) string {
+		if i := col[name]; i >= 0 && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	var (
+		records []ruleRecord
+		lines   []int
+	)
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return records, lines, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		rec := ruleRecord{
+			Text:     cell(row, "text"),
+			Pattern:  cell(row, "pattern"),
+			Response: cell(row, "response"),
+		}
+		for lang, i := range langCols {
+			if i < len(row) && row[i] != "" {
+				if rec.I18n == nil {
+					rec.I18n = make(map[string]string)
+				}
+				rec.I18n[lang] = row[i]
+			}
+		}
+		if actions := cell(row, csvActions); actions != "" {
+			if err := json.Unmarshal([]byte(actions), &rec.Actions); err != nil {
+				rec.err, rec.errCol = "invalid JSON: "+err.Error(), csvActions
+			}
+		}
+		if shadow := strings.TrimSpace(cell(row, csvShadow)); shadow != "" && rec.err == "" {
+			v, err := strconv.ParseBool(shadow)
+			if err != nil {
+				rec.err, rec.errCol = fmt.Sprintf("invalid value %q (want true or false)", shadow), csvShadow
+			}
+			rec.Shadow = v
+		}
+		if experiment := cell(row, csvExperiment); experiment != "" && rec.err == "" {
+			dec := json.NewDecoder(strings.NewReader(experiment))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&rec.Experiment); err != nil {
+				rec.err, rec.errCol = "invalid JSON: "+err.Error(), csvExperiment
+			}
+		}
+		records = append(records, rec)
+		line, _ := cr.FieldPos(0)
+		lines = append(lines, line)
+	}
+}
+
+// MergeRules объединяет существующие и импортированные правила.
+// Правила сопоставляются по text (если он задан) и по pattern:
+// совпадение с другим ответом или другим описанием считается конфликтом.
+//
+// Параметры:
+// - existing: правила из конфигурации или базового файла
+// - imported: правила из файла импорта
+// - strategy: merge — импортированное правило заменяет существующее с тем же text,
+// новые добавляются в конец; replace — результат состоит только из импортированных правил
+//
+// Возвращает итоговые правила и найденные конфликты.
+func MergeRules(existing, imported []Rule, strategy string) ([]Rule, []RuleConflict, error) {
+	if strategy != ImportMerge && strategy != ImportReplace {
+		return nil, nil, fmt.Errorf("unknown import strategy %q (want merge or replace)", strategy)
+	}
+
+	byText := make(map[string]int)
+	byPattern := make(map[string]int)
+	for i, r := range existing {
+		if r.Text != "" {
+			byText[r.Text] = i
+		}
+		byPattern[r.Pattern] = i
+	}
+
+	result := make([]Rule, 0, len(existing)+len(imported))
+	if strategy == ImportMerge {
+		result = append(result, existing...)
+	}
+
+	var conflicts []RuleConflict
+	for _, r := range imported {
+		i, sameText := byText[r.Text]
+		if r.Text == "" {
+			sameText = false
+		}
+		switch {
+		case sameText:
+			old := existing[i]
+			var diff []string
+			if old.Pattern != r.Pattern {
+				diff = append(diff, "pattern")
+			}
+			if old.Response != r.Response {
+				diff = append(diff, "response")
+			}
+			if !maps.Equal(old.I18n, r.I18n) {
+				diff = append(diff, "responses_i18n")
+			}
+			if !slices.Equal(old.Actions, r.Actions) {
+				diff = append(diff, "actions")
+			}
+			if old.Shadow != r.Shadow {
+				diff = append(diff, "shadow")
+			}
+			if !sameExperiment(old.Experiment, r.Experiment) {
+				diff = append(diff, "experiment")
+			}
+			if len(diff) > 0 {
+				conflicts = append(conflicts, RuleConflict{
+					Existing: old,
+					Imported: r,
+					Reason:   "differs in " + strings.Join(diff, ", ") + " from the existing rule" + sourceSuffix(old),
+				})
+			}
+			if strategy == ImportMerge {
+				result[i] = r
+				continue
+			}
+		default:
+			if j, ok := byPattern[r.Pattern]; ok {
+				old := existing[j]
+				if old.Text != r.Text || old.Response != r.Response || !maps.Equal(old.I18n, r.I18n) || !slices.Equal(old.Actions, r.Actions) || old.Shadow != r.Shadow || !sameExperiment(old.Experiment, r.Experiment) {
+					conflicts = append(conflicts, RuleConflict{
+						Existing: old,
+						Imported: r,
+						Reason:   fmt.Sprintf("same pattern as existing rule %q%s", old.Text, sourceSuffix(old)),
+					})
+				} else if strategy == ImportMerge {
+					// Точная копия существующего правила
+					continue
+				}
+			}
+		}
+		result = append(result, r)
+	}
+	return result, conflicts, nil
+}
+
+// sameExperiment сравнивает эксперименты двух правил (nil — правило без эксперимента)
+func sameExperiment(a, b *Experiment) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Name == b.Name && a.Sticky == b.Sticky && slices.EqualFunc(a.Variants, b.Variants, func(x, y Variant) bool {
+		return x.Name == y.Name && x.weight() == y.weight() && x.Response == y.Response && maps.Equal(x.I18n, y.I18n)
+	})
+}
+
+// sourceSuffix указывает файл существующего правила в тексте конфликта
+func sourceSuffix(r Rule) string {
+	if r.Source == "" {
+		return ""
+	}
+	return " in " + r.Source
+}
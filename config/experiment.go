@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// compile проверяет эксперимент правила rule и подставляет значения по умолчанию
+func (e *Experiment) compile(rule string) error {
+	if e.Name == "" {
+		e.Name = rule
+	}
+	if e.Name == "" {
+		return fmt.Errorf("name is required for a rule without text")
+	}
+	switch e.Sticky {
+	case "":
+		e.Sticky = StickyChat
+	case StickyChat, StickyUser:
+	default:
+		// В YAML значение проверяется тегом enum, а в импорте JSON — только здесь
+		return fmt.Errorf("unknown sticky %q (want chat or user)", e.Sticky)
+	}
+	if len(e.Variants) < 2 {
+		return fmt.Errorf("at least two variants are required, got %d", len(e.Variants))
+	}
+
+	names := make(map[string]bool, len(e.Variants))
+	total := 0
+	for i := range e.Variants {
+		v := &e.Variants[i]
+		if v.Name == "" {
+			return fmt.Errorf("variants[%d]: name is required", i)
+		}
+		if names[v.Name] {
+			return fmt.Errorf("variants[%d]: duplicate variant name %q", i, v.Name)
+		}
+		names[v.Name] = true
+		if v.weight() < 0 {
+			return fmt.Errorf("variant %q: weight must not be negative, got %d", v.Name, v.weight())
+		}
+		total += v.weight()
+		if v.Response == "" {
+			return fmt.Errorf("variant %q: response is required", v.Name)
+		}
+		responses, err := normalizeLanguages("variant "+strconv.Quote(v.Name)+": responses_i18n", v.I18n)
+		if err != nil {
+			return err
+		}
+		for lang, resp := range responses {
+			if resp == "" {
+				return fmt.Errorf("variant %q: responses_i18n.%s: empty response", v.Name, lang)
+			}
+		}
+		v.I18n = responses
+	}
+	if total == 0 {
+		return fmt.Errorf("at least one variant must have a positive weight")
+	}
+	return nil
+}
+
+// clone возвращает копию эксперимента с собственными вариантами (nil для nil).
+// compile изменяет эксперимент, поэтому правила не должны делить один *Experiment.
+func (e *Experiment) clone() *Experiment {
+	if e == nil {
+		return nil
+	}
+	c := *e
+	c.Variants = slices.Clone(e.Variants)
+	for i := range c.Variants {
+		v := &c.Variants[i]
+		v.I18n = maps.Clone(v.I18n)
+		if v.Weight != nil {
+			w := *v.Weight
+			v.Weight = &w
+		}
+	}
+	return &c
+}
+
+// Pick выбирает вариант для чата chatID и отправителя userID.
+// Выбор детерминирован: хеш FNV-1a от имени эксперимента и ID чата (sticky: user — ID пользователя)
+// делится на сумму весов, поэтому доли вариантов соответствуют весам, а повторный выбор даёт тот же вариант.
+// Если отправитель неизвестен (userID 0), вариант закрепляется за чатом.
+func (e *Experiment) Pick(chatID, userID int64) *Variant {
+	id := chatID
+	if e.Sticky == StickyUser && userID != 0 {
+		id = userID
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(e.Name))
+	h.Write([]byte{0})
+	h.Write(strconv.AppendInt(nil, id, 10))
+
+	total := 0
+	for _, v := range e.Variants {
+		total += v.weight()
+	}
+	point := int(h.Sum64() % uint64(total))
+	for i := range e.Variants {
+		if point -= e.Variants[i].weight(); point < 0 {
+			return &e.Variants[i]
+		}
+	}
+	return &e.Variants[len(e.Variants)-1]
+}
+
+// weight возвращает вес варианта; незаданный вес равен 1, вариант с весом 0 не выбирается
+func (v *Variant) weight() int {
+	if v.Weight == nil {
+		return 1
+	}
+	return *v.Weight
+}
+
+// ResponseFor возвращает ответ варианта на первом языке цепочки, как Rule.ResponseFor
+func (v *Variant) ResponseFor(chain []string) (response, lang string) {
+	for _, lang := range chain {
+		if resp, ok := v.I18n[lang]; ok {
+			return resp, lang
+		}
+	}
+	return v.Response, ""
+}
+
+// resolveExperiments проверяет секцию experiments
+func (c *Config) resolveExperiments() error {
+	if c.Experiments.Window <= 0 {
+		return fmt.Errorf("experiments.window must be positive, got %s", c.Experiments.Window)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NormalizeLanguage приводит код языка к базовому виду в нижнем регистре:
+// "en-US" и "EN_us" → "en". Возвращает пустую строку, если код некорректен
+// (базовый код — 2–3 латинские буквы, как в language_code Telegram).
+func NormalizeLanguage(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	if len(code) < 2 || len(code) > 3 {
+		return ""
+	}
+	for _, r := range code {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+	return code
+}
+
+// normalizeLanguages приводит ключи карты языков к базовому виду.
+// what — описание карты для сообщений об ошибках.
+func normalizeLanguages[V any](what string, m map[string]V) (map[string]V, error) {
+	if len(m) == 0 {
+		return m, nil
+	}
+	out := make(map[string]V, len(m))
+	for code, v := range m {
+		lang := NormalizeLanguage(code)
+		if lang == "" {
+			return nil, fmt.Errorf("%s: invalid language code %q", what, code)
+		}
+		if _, ok := out[lang]; ok {
+			return nil, fmt.Errorf("%s: language %q is set twice", what, lang)
+		}
+		out[lang] = v
+	}
+	return out, nil
+}
+
+// resolveI18n проверяет секцию i18n и приводит коды языков к базовому виду
+func (c *Config) resolveI18n() error {
+	conf := &c.I18n
+	lang := NormalizeLanguage(conf.DefaultLanguage)
+	if lang == "" {
+		return fmt.Errorf("i18n.default_language: invalid language code %q", conf.DefaultLanguage)
+	}
+	conf.DefaultLanguage = lang
+
+	for _, ids := range []struct {
+		name string
+		m    map[int64]string
+	}{{"i18n.chats", conf.Chats}, {"i18n.users", conf.Users}} {
+		for id, code := range ids.m {
+			lang := NormalizeLanguage(code)
+			if lang == "" {
+				return fmt.Errorf("%s[%d]: invalid language code %q", ids.name, id, code)
+			}
+			ids.m[id] = lang
+		}
+	}
+
+	messages, err := normalizeLanguages("i18n.messages", conf.Messages)
+	if err != nil {
+		return err
+	}
+	conf.Messages = messages
+	return nil
+}
+
+// ResponseFor возвращает ответ правила на первом языке цепочки, для которого
+// задан перевод в responses_i18n. Если перевода нет, возвращается Response
+// и пустой код языка.
+func (r *Rule) ResponseFor(chain []string) (response, lang string) {
+	for _, lang := range chain {
+		if resp, ok := r.I18n[lang]; ok {
+			return resp, lang
+		}
+	}
+	return r.Response, ""
+}
@@ -0,0 +1,295 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// emptyProbe — непустой текст, с которым проверяется правило, совпадающее с пустой строкой:
+// если совпадение есть и здесь, пустое совпадение не привязано к пустому тексту
+const emptyProbe = "\x00"
+
+// Уровни замечаний линтера правил
+const (
+	LintError   = "error"   // правило никогда не сработает или срабатывает на всё
+	LintWarning = "warning" // правило избыточно
+)
+
+// LintIssue — замечание линтера к одному правилу бота
+type LintIssue struct {
+	Bot      string // имя бота
+	Rule     int    // индекс правила в списке правил бота
+	Text     string // описание правила (Rule.Text)
+	Pattern  string // регулярное выражение правила
+	Source   string // файл правил (пусто — config.yaml)
+	Severity string // LintError или LintWarning
+	Message  string // описание проблемы
+}
+
+// String выводит замечание в виде одной строки
+func (i LintIssue) String() string {
+	from := ""
+	if i.Source != "" {
+		from = " from " + i.Source
+	}
+	return fmt.Sprintf("%s: bot %q rules[%d] %q%s: %s", i.Severity, i.Bot, i.Rule, i.Text, from, i.Message)
+}
+
+// Lint проверяет правила каждого бота с учётом того, как cleanText готовит текст:
+// clean_filter удаляет символы, текст приводится к нижнему регистру,
+// пробелы схлопываются, а при remove_duplicate_letters удаляются повторяющиеся
+// буквы и слова. Находит правила, которые после такой очистки не могут сработать,
+// правила, совпадающие с пустой строкой, а также дублирующиеся и перекрытые правила.
+// Вызывается для загруженной конфигурации (после resolveBots).
+func (c *Config) Lint() []LintIssue {
+	var issues []LintIssue
+	for _, b := range c.Bots {
+		issues = append(issues, b.lint()...)
+	}
+	return issues
+}
+
+// lint проверяет правила одного бота
+func (b *BotConfig) lint() []LintIssue {
+	var issues []LintIssue
+	add := func(i int, severity, format string, args ...any) {
+		issues = append(issues, LintIssue{
+			Bot:      b.Name,
+			Rule:     i,
+			Text:     b.Rules[i].Text,
+			Pattern:  b.Rules[i].Pattern,
+			Source:   b.Rules[i].Source,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	cleaner := textCleaner{cleanRe: b.cleanRe, removeDup: b.RemoveDuplicates()}
+	firstByPattern := make(map[string]int, len(b.Rules))
+	for i, rule := range b.Rules {
+		re := rule.Re()
+		if re == nil {
+			continue
+		}
+
+		// Пустое совпадение без якорей есть в любом тексте, а с якорями (^$) — только в пустом,
+		// но пустой после очистки текст правилами не проверяется
+		if re.MatchString("") {
+			if re.MatchString(emptyProbe) {
+				add(i, LintError, "pattern matches the empty string and fires on every message")
+			} else {
+				add(i, LintWarning, "pattern matches the empty string only in empty text, which is never matched (messages empty after cleaning are skipped)")
+			}
+		}
+
+		// Правило, которому не может соответствовать никакой очищенный текст
+		if parsed, err := syntax.Parse(rule.Pattern, syntax.Perl); err == nil {
+			if ok, reason := cleaner.canMatch(parsed); !ok {
+				add(i, LintError, "can never match cleaned text: %s", reason)
+			}
+		}
+
+		// Дубликаты и перекрытие более ранними правилами
+		if first, ok := firstByPattern[rule.Pattern]; ok {
+			add(i, LintWarning, "duplicate of rules[%d]", first)
+			continue
+		}
+		firstByPattern[rule.Pattern] = i
+		// Правила применяются к подстрокам текста: если более раннее правило без якорей
+		// находит совпадение внутри литерала, оно сработает на любом тексте, содержащем литерал
+		if lit, ok := literalText(rule.Pattern); ok {
+			for j := 0; j < i; j++ {
+				if earlier := b.Rules[j].Re(); earlier != nil && !anchored(b.Rules[j].Pattern) && earlier.MatchString(lit) {
+					add(i, LintWarning, "shadowed by rules[%d]: every message this rule matches also matches rules[%d]", j, j)
+					break
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// textCleaner описывает, какие символы и сочетания остаются в тексте после cleanText
+type textCleaner struct {
+	cleanRe   *regexp.Regexp
+	removeDup bool
+}
+
+// survives сообщает, может ли руна r встретиться в очищенном тексте.
+// Если нет — возвращает причину.
+func (c textCleaner) survives(r rune) (bool, string) {
+	if unicode.IsUpper(r) && unicode.ToLower(r) != r {
+		return false, fmt.Sprintf("uppercase %q (text is lowercased; add (?i) or use lowercase)", r)
+	}
+	if unicode.IsSpace(r) && r != ' ' {
+		return false, fmt.Sprintf("whitespace %q (whitespace is collapsed to single spaces)", r)
+	}
+	// clean_filter применяется до приведения к нижнему регистру,
+	// поэтому руна выживает, если фильтр не удаляет её или её заглавный вариант
+	if c.removes(r) && c.removes(unicode.ToUpper(r)) {
+		return false, fmt.Sprintf("%q is removed by clean_filter", r)
+	}
+	return true, ""
+}
+
+// removes сообщает, удаляет ли clean_filter руну r. Пустое совпадение ничего не удаляет:
+// фильтр вроде "" или "x*" совпадает с любой строкой, но символы оставляет.
+func (c textCleaner) removes(r rune) bool {
+	if c.cleanRe == nil {
+		return false
+	}
+	loc := c.cleanRe.FindStringIndex(string(r))
+	return loc != nil && loc[1] > loc[0]
+}
+
+// canMatch сообщает, может ли узел регулярного выражения совпасть с каким-либо
+// очищенным текстом. Если нет — возвращает причину.
+func (c textCleaner) canMatch(re *syntax.Regexp) (bool, string) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return c.literalMatches(re.Rune, re.Flags&syntax.FoldCase != 0)
+
+	case syntax.OpCharClass:
+		var reason string
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			lo, hi := re.Rune[i], re.Rune[i+1]
+			// Широкие классы (\w, [^...]) почти наверняка содержат подходящие символы
+			if hi-lo > 256 {
+				return true, ""
+			}
+			for r := lo; r <= hi; r++ {
+				ok, why := c.survives(r)
+				if ok {
+					return true, ""
+				}
+				reason = why
+			}
+		}
+		if reason == "" {
+			return false, "empty character class"
+		}
+		return false, "character class " + re.String() + ": every character is impossible, e.g. " + reason
+
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if ok, reason := c.canMatch(sub); !ok {
+				return false, reason
+			}
+		}
+		return true, ""
+
+	case syntax.OpAlternate:
+		var reasons []string
+		for _, sub := range re.Sub {
+			ok, reason := c.canMatch(sub)
+			if ok {
+				return true, ""
+			}
+			reasons = append(reasons, reason)
+		}
+		return false, strings.Join(reasons, "; ")
+
+	case syntax.OpCapture, syntax.OpPlus:
+		return c.canMatch(re.Sub[0])
+
+	case syntax.OpRepeat:
+		if re.Min == 0 {
+			return true, ""
+		}
+		if ok, reason := c.canMatch(re.Sub[0]); !ok {
+			return false, reason
+		}
+		// Повтор одной буквы подряд исчезает при удалении дубликатов
+		sub := re.Sub[0]
+		for sub.Op == syntax.OpCapture {
+			sub = sub.Sub[0]
+		}
+		if c.removeDup && re.Min >= 2 && sub.Op == syntax.OpLiteral && len(sub.Rune) == 1 {
+			if r := sub.Rune[0]; !unicode.IsDigit(r) {
+				return false, fmt.Sprintf("%s repeats %q (remove_duplicate_letters collapses doubled letters)", re.String(), r)
+			}
+		}
+		return true, ""
+
+	default:
+		// Якоря, пустые совпадения, любые символы, * и ?
+		return true, ""
+	}
+}
+
+// literalMatches проверяет строку-литерал из регулярного выражения
+func (c textCleaner) literalMatches(runes []rune, foldCase bool) (bool, string) {
+	lowered := make([]rune, len(runes))
+	for i, r := range runes {
+		if foldCase {
+			r = unicode.ToLower(r)
+		}
+		if ok, reason := c.survives(r); !ok {
+			return false, reason
+		}
+		lowered[i] = unicode.ToLower(r)
+	}
+
+	if !c.removeDup {
+		return true, ""
+	}
+
+	// Удвоенные буквы схлопываются: "сс" в тексте не останется
+	for i := 1; i < len(lowered); i++ {
+		if lowered[i] == lowered[i-1] && !unicode.IsDigit(lowered[i]) && !unicode.IsSpace(lowered[i]) {
+			return false, fmt.Sprintf("doubled letter %q in %q (remove_duplicate_letters collapses doubled letters)",
+				string(lowered[i-1:i+1]), string(runes))
+		}
+	}
+
+	// Повторяющиеся слова удаляются: "да да" в тексте не останется
+	seen := make(map[string]bool)
+	for _, w := range strings.Fields(string(lowered)) {
+		if seen[w] {
+			return false, fmt.Sprintf("repeated word %q in %q (remove_duplicate_letters removes repeated words)", w, string(runes))
+		}
+		seen[w] = true
+	}
+	return true, ""
+}
+
+// literalText возвращает текст, если выражение целиком — литерал без флагов
+// (допускается обёртка в группу)
+func literalText(pattern string) (string, bool) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	for re.Op == syntax.OpCapture {
+		re = re.Sub[0]
+	}
+	if re.Op != syntax.OpLiteral || re.Flags&syntax.FoldCase != 0 {
+		return "", false
+	}
+	return string(re.Rune), true
+}
+
+// anchored сообщает, есть ли в выражении якоря или границы слов (^, $, \A, \z, \b, \B):
+// совпадение такого выражения с подстрокой не означает совпадения с текстом, который её содержит
+func anchored(pattern string) bool {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	return err != nil || hasAssertion(re)
+}
+
+// hasAssertion ищет в дереве выражения проверки позиции
+func hasAssertion(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return true
+	}
+	for _, sub := range re.Sub {
+		if hasAssertion(sub) {
+			return true
+		}
+	}
+	return false
+}
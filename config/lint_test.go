@@ -0,0 +1,78 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/st-kuptsov/balabol/config"
+	"github.com/st-kuptsov/balabol/internal/telegramtest"
+)
+
+// lintRules загружает конфигурацию с корневыми настройками settings и правилами patterns
+// (описание правила — его индекс) и возвращает замечания линтера
+func lintRules(t *testing.T, settings string, patterns ...string) []config.LintIssue {
+	t.Helper()
+	conf := settings + "rules:\n"
+	for _, p := range patterns {
+		conf += "  - text: \"" + p + "\"\n" +
+			"    pattern: '" + p + "'\n" +
+			"    response: ответ\n"
+	}
+	cfg, err := config.GetConfig(telegramtest.WriteConfig(t, conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg.Lint()
+}
+
+func TestLint(t *testing.T) {
+	const dedup = "remove_duplicate_letters: true\n"
+	type want struct {
+		rule     int
+		severity string
+		message  string
+	}
+	for _, tc := range []struct {
+		name     string
+		settings string
+		patterns []string
+		want     []want // nil — замечаний нет
+	}{
+		{"clean rules", dedup, []string{"привет", "(?i)Пока", "[a-z]+"}, nil},
+		{"uppercase", "", []string{"Привет"}, []want{{0, config.LintError, "uppercase 'П'"}}},
+		{"uppercase with (?i)", "", []string{"(?i)Привет"}, nil},
+		{"removed by clean_filter", "clean_filter: \"[!?]+\"\n", []string{"ура!", "ура[!?]"}, []want{
+			{0, config.LintError, "'!' is removed by clean_filter"},
+			{1, config.LintError, "every character is impossible"},
+		}},
+		{"doubled letters", dedup, []string{"ссылка", "о{2}", "11 рублей"}, []want{
+			{0, config.LintError, "doubled letter \"сс\""},
+			{1, config.LintError, "repeats 'о'"},
+		}},
+		{"doubled letters kept", "remove_duplicate_letters: false\n", []string{"ссылка"}, nil},
+		{"repeated words", dedup, []string{"да да"}, []want{{0, config.LintError, "repeated word \"да\""}}},
+		{"empty match", "", []string{"а*", "^$"}, []want{
+			{0, config.LintError, "fires on every message"},
+			{1, config.LintWarning, "only in empty text"},
+		}},
+		{"duplicate", "", []string{"привет", "пока", "привет"}, []want{{2, config.LintWarning, "duplicate of rules[0]"}}},
+		{"shadowed", "", []string{"привет", "привет всем"}, []want{{1, config.LintWarning, "shadowed by rules[0]"}}},
+		{"shadowed by a class", "", []string{"при[вф]ет", "скажи привет"}, []want{{1, config.LintWarning, "shadowed by rules[0]"}}},
+		{"anchored earlier rule", "", []string{"^привет$", "привет"}, nil},
+		{"word boundary", "", []string{`\bвет\b`, "привет"}, nil},
+		{"later rule is broader", "", []string{"привет всем", "привет"}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			issues := lintRules(t, tc.settings, tc.patterns...)
+			if len(issues) != len(tc.want) {
+				t.Fatalf("got %d issue(s), want %d: %v", len(issues), len(tc.want), issues)
+			}
+			for i, w := range tc.want {
+				got := issues[i]
+				if got.Rule != w.rule || got.Severity != w.severity || !strings.Contains(got.Message, w.message) {
+					t.Errorf("issue %d = %s; want %s for rules[%d] containing %q", i, got, w.severity, w.rule, w.message)
+				}
+			}
+		})
+	}
+}
@@ -4,55 +4,129 @@ import (
 	"crypto/sha256" // для вычисления хеша конфигурационных файлов
 	"fmt"
 	"github.com/ilyakaznacheev/cleanenv" // библиотека для чтения YAML/ENV конфигов
-	"log"
 	"os"
 )
 
 // GetConfig загружает конфигурацию из файла по указанному пути.
 // Выполняет:
-// 1. Чтение основного конфига через cleanenv
-// 2. Загрузку секретов (например, токена Telegram)
+// 1. Строгую проверку файла (Validate)
+// 2. Чтение основного конфига через cleanenv
 // 3. Компиляцию правил (Rule.Compile)
+// 4. Чтение файлов правил (include и rules_dir)
+// 5. Проверку секций i18n, sender_policy и moderation
+// 6. Построение итоговых настроек ботов (секция bots)
+// 7. Проверку удалённых источников правил (rule_sources)
+// 8. Загрузку секретов (например, токена Telegram)
 func GetConfig(path string) (*Config, error) {
 	var cfg Config
 
+	// Строгая проверка файла: неизвестные поля, типы, обязательные поля и допустимые значения.
+	// cleanenv молча пропускает опечатки вроде "respons:", поэтому проверяем до чтения.
+	if err := Validate(path); err != nil {
+		return nil, err
+	}
+
 	// Чтение конфигурации из файла
 	if err := cleanenv.ReadConfig(path, &cfg); err != nil {
-		// Если произошла ошибка, выводим справку по конфигу
-		if help, err2 := cleanenv.GetDescription(&cfg, nil); err2 == nil {
-			log.Println(help)
-		}
 		return nil, fmt.Errorf("ошибка загрузки конфигурации: %w", err)
 	}
 
+	// Компиляция всех правил из конфига (например, регулярные выражения)
+	if err := cfg.compileRules(); err != nil {
+		return nil, err
+	}
+
+	// Правила из файлов include и rules_dir
+	if err := cfg.loadRuleFiles(); err != nil {
+		return nil, err
+	}
+
+	// Коды языков секции i18n (до resolveBots, который копирует её в ботов)
+	if err := cfg.resolveI18n(); err != nil {
+		return nil, err
+	}
+
+	// Фильтр отправителей
+	if err := cfg.resolveSenderPolicy(); err != nil {
+		return nil, err
+	}
+
+	// Предупреждения и эскалация
+	if err := cfg.resolveModeration(); err != nil {
+		return nil, err
+	}
+
+	// Очередь исходящих сообщений
+	if err := cfg.resolveSendQueue(); err != nil {
+		return nil, err
+	}
+
+	// Учёт экспериментов
+	if err := cfg.resolveExperiments(); err != nil {
+		return nil, err
+	}
+
+	// Итоговые настройки каждого бота с учётом наследования из корня
+	if err := cfg.resolveBots(); err != nil {
+		return nil, err
+	}
+
+	// Статистика и сводка (после resolveBots: сводку отправляет один из ботов)
+	if err := cfg.resolveStats(); err != nil {
+		return nil, err
+	}
+
+	// Проверка удалённых источников правил
+	if err := cfg.resolveRuleSources(); err != nil {
+		return nil, err
+	}
+
 	// Загрузка секретов из отдельного файла (если указан)
 	if err := cfg.LoadSecrets(); err != nil {
 		return nil, err
 	}
 
-	// Компиляция всех правил из конфига (например, регулярные выражения)
-	for i := range cfg.Rules {
-		if err := cfg.Rules[i].Compile(); err != nil {
-			return nil, err
+	return &cfg, nil
+}
+
+// compileRules компилирует корневые правила и собственные правила ботов
+func (c *Config) compileRules() error {
+	for i := range c.Rules {
+		if err := c.Rules[i].Compile(); err != nil {
+			return err
 		}
 	}
-
-	return &cfg, nil
+	for _, b := range c.Bots {
+		for i := range b.Rules {
+			if err := b.Rules[i].Compile(); err != nil {
+				return fmt.Errorf("bot %q: %w", b.Name, err)
+			}
+		}
+	}
+	return nil
 }
 
 // LoadSecrets загружает секреты из отдельного файла (SecretsPath).
-// Поддерживаются, например, токены для Telegram.
+// Поддерживаются токен Telegram, секрет webhook, токен служебных эндпоинтов
+// и значения заголовков авторизации источников правил.
 func (c *Config) LoadSecrets() error {
 	if c.SecretsPath == "" {
 		// Если путь к секретам не указан, пропускаем
 		return nil
 	}
 
+	// Секреты одного бота
+	type botSecrets struct {
+		Token         string `yaml:"token"`
+		WebhookSecret string `yaml:"webhook_secret"`
+	}
+
 	// Структура для парсинга секретов
 	type secrets struct {
-		Telegram struct {
-			Token string `yaml:"token"`
-		} `yaml:"telegram"`
+		Telegram    botSecrets            `yaml:"telegram"`
+		Bots        map[string]botSecrets `yaml:"bots"`
+		AdminToken  string                `yaml:"admin_token"`
+		RuleSources map[string]string     `yaml:"rule_sources"`
 	}
 
 	var sec secrets
@@ -63,12 +137,43 @@ func (c *Config) LoadSecrets() error {
 
 	// Присвоение токена из секрета в основную конфигурацию
 	c.Telegram.Token = sec.Telegram.Token
+	c.Telegram.Webhook.SecretToken = sec.Telegram.WebhookSecret
+	c.AdminToken = sec.AdminToken
+
+	// Значения заголовков авторизации источников правил
+	for i := range c.RuleSources {
+		rs := &c.RuleSources[i]
+		if rs.AuthHeader == "" {
+			continue
+		}
+		value, ok := sec.RuleSources[rs.Name]
+		if !ok {
+			return fmt.Errorf("rule source %q: auth value not found in rule_sources of %s", rs.Name, c.SecretsPath)
+		}
+		rs.AuthValue = value
+	}
+
+	// Токены ботов берутся из секции bots по ключу token_secret.
+	// Единственный бот может использовать токен из секции telegram.
+	for i := range c.Bots {
+		b := &c.Bots[i]
+		bs, ok := sec.Bots[b.TokenSecret]
+		if !ok {
+			if len(c.Bots) > 1 {
+				return fmt.Errorf("bot %q: secret %q not found in %s", b.Name, b.TokenSecret, c.SecretsPath)
+			}
+			bs = sec.Telegram
+		}
+		b.Telegram.Token = bs.Token
+		b.Telegram.Webhook.SecretToken = bs.WebhookSecret
+	}
 	return nil
 }
 
 // LoadConfigWithHash загружает конфигурацию и вычисляет SHA256 хеши
 // 1. Основного конфига
 // 2. Файла секретов (если указан)
+// 3. Файлов правил (include и rules_dir)
 // Возвращает CachedConfig, который хранит конфиг и его хеши
 func LoadConfigWithHash(path string) (*CachedConfig, error) {
 	// Чтение основного конфиг файла
@@ -93,9 +198,17 @@ func LoadConfigWithHash(path string) (*CachedConfig, error) {
 		}
 	}
 
+	// Общий хеш файлов правил
+	rulesHash, err := hashFiles(cfg.RuleFiles)
+	if err != nil {
+		return nil, err
+	}
+
 	return &CachedConfig{
 		Config:      cfg,
 		ConfigHash:  hash,
 		SecretsHash: secretsHash,
+		RulesHash:   rulesHash,
+		local:       cfg,
 	}, nil
 }
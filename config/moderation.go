@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// resolveModeration проверяет секцию moderation и упорядочивает ступени эскалации по числу предупреждений
+func (c *Config) resolveModeration() error {
+	conf := &c.Moderation
+	if conf.WarningsTTL < 0 {
+		return fmt.Errorf("moderation.warnings_ttl must not be negative, got %s", conf.WarningsTTL)
+	}
+
+	sort.SliceStable(conf.Escalation, func(i, j int) bool {
+		return conf.Escalation[i].Warnings < conf.Escalation[j].Warnings
+	})
+	for i, step := range conf.Escalation {
+		if step.Duration < 0 {
+			return fmt.Errorf("moderation.escalation: step at %d warnings: duration must not be negative", step.Warnings)
+		}
+		if i > 0 && conf.Escalation[i-1].Warnings == step.Warnings {
+			return fmt.Errorf("moderation.escalation: two steps at %d warnings", step.Warnings)
+		}
+	}
+	return nil
+}
+
+// Step возвращает ступень эскалации для указанного числа предупреждений или nil.
+// Счётчик больше последней ступени (например, после изменения конфигурации) даёт последнюю ступень.
+// last сообщает, что это последняя ступень (после неё счётчик сбрасывается).
+func (m ModerationConfig) Step(warnings int) (step *EscalationStep, last bool) {
+	for i := range m.Escalation {
+		last = i == len(m.Escalation)-1
+		if m.Escalation[i].Warnings == warnings || (last && warnings > m.Escalation[i].Warnings) {
+			return &m.Escalation[i], last
+		}
+	}
+	return nil, false
+}
+
+// MaxWarnings возвращает число предупреждений последней ступени эскалации (0 — эскалации нет)
+func (m ModerationConfig) MaxWarnings() int {
+	if len(m.Escalation) == 0 {
+		return 0
+	}
+	return m.Escalation[len(m.Escalation)-1].Warnings
+}
@@ -9,11 +9,14 @@ import (
 	"github.com/st-kuptsov/balabol/pkg/metrics" // кастомные Prometheus метрики
 )
 
-// ReloadIfChanged проверяет, изменился ли конфиг или секреты.
-// Если изменился — перечитывает их и обновляет CachedConfig.
+// ReloadIfChanged проверяет, изменились ли конфиг, секреты или файлы правил.
+// Если изменились — перечитывает их и целиком заменяет конфигурацию в CachedConfig.
+// Файлы правил ищутся заново при каждой проверке, поэтому новый файл в rules_dir
+// или новое совпадение шаблона include тоже считаются изменением.
+// Старый *Config не изменяется, поэтому его можно безопасно дочитывать в обработчиках.
 // Возвращает:
-//   - changed = true, если конфиг или секреты обновились
-//   - error при проблемах с чтением или компиляцией правил
+//   - changed = true, если конфиг, секреты или правила обновились
+//   - error при проблемах с чтением или компиляцией правил (с именем файла)
 func (c *CachedConfig) ReloadIfChanged(path string) (bool, error) {
 	// Чтение основного конфига
 	data, err := os.ReadFile(path)
@@ -28,46 +31,124 @@ func (c *CachedConfig) ReloadIfChanged(path string) (bool, error) {
 	// Вычисляем SHA256 хеш файла
 	newHash := fmt.Sprintf("%x", sha256.Sum256(data))
 
-	// Загружаем конфиг
+	// Хеши секретов и правил по путям из текущей конфигурации:
+	// если пути изменились, изменился и основной файл
+	cur := c.Current()
+	newSecretsHash, err := secretsHash(cur.SecretsPath)
+	if err != nil {
+		return false, err
+	}
+	newRulesHash := ""
+	if files, err := cur.ruleFiles(); err == nil {
+		if newRulesHash, err = hashFiles(files); err != nil {
+			return false, err
+		}
+	}
+
+	if newHash == c.Hash() && newSecretsHash == c.currentSecretsHash() && newRulesHash == c.currentRulesHash() {
+		return false, nil
+	}
+
+	// Загружаем конфиг вместе с секретами и скомпилированными правилами
 	cfg, err := GetConfig(path)
 	if err != nil {
 		return false, err
 	}
 
-	changed := false
+	// Хеши по путям из новой конфигурации
+	if newSecretsHash, err = secretsHash(cfg.SecretsPath); err != nil {
+		return false, err
+	}
+	if newRulesHash, err = hashFiles(cfg.RuleFiles); err != nil {
+		return false, err
+	}
 
-	// Проверяем, изменился ли основной конфиг
-	if newHash != c.ConfigHash {
-		c.Config = cfg
-		c.ConfigHash = newHash
-		changed = true
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.local = cfg
+	c.ConfigHash = newHash
+	c.SecretsHash = newSecretsHash
+	c.RulesHash = newRulesHash
+
+	// Правила источников, удалённых из конфигурации, больше не действуют
+	for name := range c.remote {
+		if cfg.Source(name) == nil {
+			delete(c.remote, name)
+		}
 	}
+	c.Config = cfg.withRemote(c.remote)
+	return true, nil
+}
 
-	// Проверяем файл секретов
-	if cfg.SecretsPath != "" {
-		sData, err := os.ReadFile(cfg.SecretsPath)
-		if err != nil {
-			return changed, fmt.Errorf("cannot read secrets file: %w", err)
+// SetRemoteRules заменяет правила удалённого источника и пересобирает итоговую конфигурацию.
+// rf == nil удаляет правила источника. Правила применяются, только если источник
+// есть в текущей конфигурации и указанный в документе бот существует.
+func (c *CachedConfig) SetRemoteRules(source string, rf *RulesFile) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// CachedConfig, собранный без LoadConfigWithHash, хранит только Config
+	if c.local == nil {
+		c.local = c.Config
+	}
+
+	if rf == nil {
+		delete(c.remote, source)
+	} else {
+		if c.local.Source(source) == nil {
+			return fmt.Errorf("rule source %q is not configured", source)
+		}
+		if err := c.local.checkRemote(source, rf); err != nil {
+			return err
 		}
-		newSecretsHash := fmt.Sprintf("%x", sha256.Sum256(sData))
-		if newSecretsHash != c.SecretsHash {
-			// Перечитываем секреты
-			if err := c.Config.LoadSecrets(); err != nil {
-				return changed, err
-			}
-			c.SecretsHash = newSecretsHash
-			changed = true
+		if c.remote == nil {
+			c.remote = make(map[string]*RulesFile)
 		}
+		c.remote[source] = rf
 	}
+	c.Config = c.local.withRemote(c.remote)
+	return nil
+}
 
-	// Перекомпилируем все правила (например, регулярные выражения)
-	for i := range c.Config.Rules {
-		if err := c.Config.Rules[i].Compile(); err != nil {
-			return changed, fmt.Errorf("invalid regexp %q: %w", c.Config.Rules[i].Pattern, err)
-		}
+// secretsHash вычисляет SHA256 хеш файла секретов (пусто, если путь не задан)
+func secretsHash(path string) (string, error) {
+	if path == "" {
+		return "", nil
 	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read secrets file: %w", err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
 
-	return changed, nil
+// currentSecretsHash возвращает хеш текущего файла секретов
+func (c *CachedConfig) currentSecretsHash() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.SecretsHash
+}
+
+// currentRulesHash возвращает хеш текущих файлов правил
+func (c *CachedConfig) currentRulesHash() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.RulesHash
+}
+
+// Current возвращает текущую конфигурацию.
+// Возвращённое значение не меняется при reload и безопасно для чтения из любых горутин.
+func (c *CachedConfig) Current() *Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Config
+}
+
+// Hash возвращает SHA256 хеш текущего основного конфига
+func (c *CachedConfig) Hash() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ConfigHash
 }
 
 // ReloadWithMetrics проверяет изменения конфигурации и обновляет Prometheus метрики
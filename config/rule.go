@@ -1,19 +1,76 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"regexp"
+	"strconv"
+	"time"
 )
 
 // Compile компилирует строковое регулярное выражение Rule.Pattern
 // и сохраняет его в поле re для последующего использования.
-// Возвращает ошибку, если регулярное выражение некорректное.
+// Коды языков в responses_i18n приводятся к базовому виду ("en-US" → "en").
+// Возвращает ошибку, если регулярное выражение или код языка некорректны
+// либо у правила нет ни ответа, ни эксперимента, ни действий.
 func (r *Rule) Compile() error {
 	re, err := regexp.Compile(r.Pattern)
 	if err != nil {
 		return fmt.Errorf("invalid regexp %q: %w", r.Pattern, err)
 	}
 	r.re = re
+
+	// Коды языков ответов приводятся к виду, в котором их ищет ResponseFor
+	responses, err := normalizeLanguages("rule "+strconv.Quote(r.Text)+": responses_i18n", r.I18n)
+	if err != nil {
+		return err
+	}
+	for lang, resp := range responses {
+		if resp == "" {
+			return fmt.Errorf("rule %q: responses_i18n.%s: empty response", r.Text, lang)
+		}
+	}
+	r.I18n = responses
+
+	// Ответы эксперимента заменяют response: текущий ответ становится одним из вариантов
+	if r.Experiment != nil {
+		if r.Response != "" || len(r.I18n) > 0 {
+			return fmt.Errorf("rule %q: response and experiment are mutually exclusive: move the response into a variant", r.Text)
+		}
+		// Копия: эксперимент из defaults файла правил общий для нескольких правил
+		r.Experiment = r.Experiment.clone()
+		if err := r.Experiment.compile(r.Text); err != nil {
+			return fmt.Errorf("rule %q: experiment: %w", r.Text, err)
+		}
+	}
+
+	// Правило должно что-то делать: отвечать или выполнять действия модерации
+	if r.Response == "" && r.Experiment == nil && len(r.Actions) == 0 {
+		return fmt.Errorf("rule %q: response, experiment or actions is required", r.Text)
+	}
+	for i, a := range r.Actions {
+		if err := a.check(); err != nil {
+			return fmt.Errorf("rule %q: actions[%d]: %w", r.Text, i, err)
+		}
+	}
+	return nil
+}
+
+// check проверяет действие: тип и срок
+func (a Action) check() error {
+	switch a.Type {
+	case ActionDelete, ActionRestrict, ActionBan, ActionPin, ActionWarn:
+	default:
+		// В YAML тип проверяется тегом enum, а в импорте CSV и JSON — только здесь
+		return fmt.Errorf("unknown action type %q", a.Type)
+	}
+	if a.Duration < 0 {
+		return fmt.Errorf("duration must not be negative, got %s", a.Duration)
+	}
+	if a.Duration != 0 && a.Type != ActionRestrict && a.Type != ActionBan {
+		return fmt.Errorf("duration applies only to restrict and ban, not %s", a.Type)
+	}
 	return nil
 }
 
@@ -22,3 +79,38 @@ func (r *Rule) Compile() error {
 func (r *Rule) Re() *regexp.Regexp {
 	return r.re
 }
+
+// actionJSON — действие в JSON импорта и экспорта: срок в виде строки ("30m"), как в YAML
+type actionJSON struct {
+	Type     string `json:"type"`
+	Duration string `json:"duration,omitempty"`
+	DryRun   bool   `json:"dry_run,omitempty"`
+}
+
+// MarshalJSON кодирует действие со сроком в формате time.Duration
+func (a Action) MarshalJSON() ([]byte, error) {
+	v := actionJSON{Type: a.Type, DryRun: a.DryRun}
+	if a.Duration != 0 {
+		v.Duration = a.Duration.String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON разбирает действие со сроком в формате time.Duration
+func (a *Action) UnmarshalJSON(data []byte) error {
+	var v actionJSON
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	*a = Action{Type: v.Type, DryRun: v.DryRun}
+	if v.Duration != "" {
+		d, err := time.ParseDuration(v.Duration)
+		if err != nil {
+			return fmt.Errorf("action %s: %w", v.Type, err)
+		}
+		a.Duration = d
+	}
+	return nil
+}
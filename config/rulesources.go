@@ -0,0 +1,100 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// DefaultSignatureHeader — заголовок с подписью документа правил по умолчанию
+const DefaultSignatureHeader = "X-Signature-Ed25519"
+
+// resolveRuleSources проверяет секцию rule_sources и подставляет значения по умолчанию
+// (cleanenv не обрабатывает элементы списков).
+func (c *Config) resolveRuleSources() error {
+	names := make(map[string]bool, len(c.RuleSources))
+	for i := range c.RuleSources {
+		s := &c.RuleSources[i]
+		if names[s.Name] {
+			return fmt.Errorf("rule_sources[%d]: duplicate source name %q", i, s.Name)
+		}
+		names[s.Name] = true
+
+		u, err := url.Parse(s.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("rule source %q: url must be an absolute http or https URL", s.Name)
+		}
+		if s.SHA256 != "" {
+			if sum, err := hex.DecodeString(s.SHA256); err != nil || len(sum) != 32 {
+				return fmt.Errorf("rule source %q: sha256 must be 64 hex characters", s.Name)
+			}
+		}
+		if s.PublicKey != "" {
+			if key, err := base64.StdEncoding.DecodeString(s.PublicKey); err != nil || len(key) != ed25519.PublicKeySize {
+				return fmt.Errorf("rule source %q: public_key must be a base64 Ed25519 public key", s.Name)
+			}
+		}
+
+		if s.Interval == 0 {
+			s.Interval = time.Minute
+		}
+		if s.Timeout == 0 {
+			s.Timeout = 30 * time.Second
+		}
+		if s.SignatureHeader == "" {
+			s.SignatureHeader = DefaultSignatureHeader
+		}
+	}
+	return nil
+}
+
+// Source возвращает настройки источника правил по имени или nil
+func (c *Config) Source(name string) *RuleSourceConfig {
+	for i := range c.RuleSources {
+		if c.RuleSources[i].Name == name {
+			return &c.RuleSources[i]
+		}
+	}
+	return nil
+}
+
+// withRemote возвращает копию конфигурации, в которой к правилам добавлены
+// правила удалённых источников (в порядке rule_sources).
+// Правила без bot добавляются к корневым и ко всем ботам, наследующим корневые правила;
+// правила с bot — только указанному боту. Исходная конфигурация не меняется.
+func (c *Config) withRemote(remote map[string]*RulesFile) *Config {
+	if len(remote) == 0 {
+		return c
+	}
+
+	merged := *c
+	merged.Bots = slices.Clone(c.Bots)
+	for _, src := range c.RuleSources {
+		rf := remote[src.Name]
+		if rf == nil {
+			continue
+		}
+		for i := range merged.Bots {
+			b := &merged.Bots[i]
+			if (rf.Bot == "" && b.inheritRules) || rf.Bot == b.Name {
+				b.Rules = append(slices.Clip(b.Rules), rf.Rules...)
+			}
+		}
+		if rf.Bot == "" {
+			merged.Rules = append(slices.Clip(merged.Rules), rf.Rules...)
+		}
+	}
+	return &merged
+}
+
+// checkRemote проверяет, что правила источника можно применить к конфигурации
+func (c *Config) checkRemote(source string, rf *RulesFile) error {
+	if rf.Bot != "" && c.Bot(rf.Bot) == nil {
+		return fmt.Errorf("rule source %q: bot %q is not defined in bots", source, rf.Bot)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// schemaID — идентификатор диалекта JSON Schema
+const schemaID = "https://json-schema.org/draft/2020-12/schema"
+
+// durationPattern — формат time.Duration в YAML ("10s", "1m30s")
+const durationPattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+// JSONSchema возвращает JSON Schema файла config.yaml.
+// Схема строится по тем же структурам и тегам, что и проверка Validate,
+// поэтому подсказки редактора совпадают с тем, что примет приложение.
+func JSONSchema() ([]byte, error) {
+	return jsonSchema(reflect.TypeOf(Config{}), "balabol config.yaml", true)
+}
+
+// RulesFileJSONSchema возвращает JSON Schema файла правил (include, rules_dir).
+// Обязательные поля правил в ней не требуются: их может задать defaults.
+func RulesFileJSONSchema() ([]byte, error) {
+	return jsonSchema(reflect.TypeOf(RulesFile{}), "balabol rules file", false)
+}
+
+// jsonSchema строит схему документа для типа t
+func jsonSchema(t reflect.Type, title string, requireFields bool) ([]byte, error) {
+	schema := typeSchema(t, requireFields)
+	schema["$schema"] = schemaID
+	schema["title"] = title
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// typeSchema строит схему для типа Go.
+// requireFields=false не добавляет списки required (как и в validateYAML).
+func typeSchema(t reflect.Type, requireFields bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == durationType:
+		return map[string]any{"type": "string", "pattern": durationPattern}
+	case t.Kind() == reflect.Struct:
+		props := make(map[string]any)
+		var required []string
+		var anyOf []any // поля с required_unless: обязательно хотя бы одно из полей
+		for _, f := range yamlFields(t) {
+			props[f.name] = fieldSchema(f, requireFields)
+			if requireFields && f.Tag.Get("required") == "true" {
+				required = append(required, f.name)
+			}
+			if others, ok := f.Tag.Lookup("required_unless"); ok && requireFields {
+				for _, name := range append([]string{f.name}, strings.Split(others, ",")...) {
+					anyOf = append(anyOf, map[string]any{"required": []string{name}})
+				}
+			}
+		}
+		s := map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		if len(anyOf) > 0 {
+			s["anyOf"] = anyOf
+		}
+		return s
+	case t.Kind() == reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), requireFields)}
+	case t.Kind() == reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem(), requireFields)}
+	default:
+		return map[string]any{"type": typeName(t)}
+	}
+}
+
+// fieldSchema дополняет схему типа поля ограничениями из тегов
+func fieldSchema(f yamlField, requireFields bool) map[string]any {
+	s := typeSchema(f.Type, requireFields)
+
+	if enum := f.Tag.Get("enum"); enum != "" {
+		s["enum"] = strings.Split(enum, ",")
+	}
+	if v, err := strconv.ParseFloat(f.Tag.Get("min"), 64); err == nil {
+		s["minimum"] = v
+	}
+	if v, err := strconv.ParseFloat(f.Tag.Get("max"), 64); err == nil {
+		s["maximum"] = v
+	}
+	switch f.Tag.Get("format") {
+	case "regex":
+		s["format"] = "regex"
+	case "url":
+		s["format"] = "uri"
+	}
+	if f.Tag.Get("required") == "true" && s["type"] == "string" {
+		s["minLength"] = 1
+	}
+	if def, ok := f.Tag.Lookup("env-default"); ok {
+		if v, ok := defaultValue(s["type"], def); ok {
+			s["default"] = v
+		}
+	}
+	return s
+}
+
+// defaultValue переводит значение env-default в значение нужного JSON-типа
+func defaultValue(typ any, def string) (any, bool) {
+	switch typ {
+	case "boolean":
+		v, err := strconv.ParseBool(def)
+		return v, err == nil
+	case "integer":
+		v, err := strconv.ParseInt(def, 10, 64)
+		return v, err == nil
+	case "number":
+		v, err := strconv.ParseFloat(def, 64)
+		return v, err == nil
+	case "string":
+		return def, true
+	default:
+		return nil, false
+	}
+}
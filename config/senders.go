@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Результат проверки ID по спискам AccessList
+const (
+	AccessAllowed    = ""            // ID разрешён
+	AccessBlocked    = "blocked"     // ID в списке block
+	AccessNotAllowed = "not_allowed" // allow не пуст и ID в нём нет
+)
+
+// Check проверяет ID по спискам. Запрет важнее разрешения.
+// Возвращает AccessAllowed, AccessBlocked или AccessNotAllowed.
+func (l AccessList) Check(id int64) string {
+	if slices.Contains(l.Block, id) {
+		return AccessBlocked
+	}
+	if len(l.Allow) > 0 && !slices.Contains(l.Allow, id) {
+		return AccessNotAllowed
+	}
+	return AccessAllowed
+}
+
+// resolveSenderPolicy проверяет секцию sender_policy
+func (c *Config) resolveSenderPolicy() error {
+	flood := c.SenderPolicy.Flood
+	if !flood.Enabled {
+		return nil
+	}
+	if flood.Window <= 0 {
+		return fmt.Errorf("sender_policy.flood.window must be positive, got %s", flood.Window)
+	}
+	if flood.Mute <= 0 {
+		return fmt.Errorf("sender_policy.flood.mute must be positive, got %s", flood.Mute)
+	}
+	return nil
+}
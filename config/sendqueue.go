@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// resolveSendQueue проверяет паузы и сроки секции send_queue
+func (c *Config) resolveSendQueue() error {
+	conf := &c.SendQueue
+	if conf.RetryBackoff <= 0 {
+		return fmt.Errorf("send_queue.retry_backoff must be positive, got %s", conf.RetryBackoff)
+	}
+	if conf.MaxBackoff < conf.RetryBackoff {
+		return fmt.Errorf("send_queue.max_backoff (%s) must not be less than retry_backoff (%s)", conf.MaxBackoff, conf.RetryBackoff)
+	}
+	if conf.MaxAge <= 0 {
+		return fmt.Errorf("send_queue.max_age must be positive, got %s", conf.MaxAge)
+	}
+	if conf.Breaker.Cooldown <= 0 {
+		return fmt.Errorf("send_queue.breaker.cooldown must be positive, got %s", conf.Breaker.Cooldown)
+	}
+	return nil
+}
+
+// GlobalInterval возвращает минимальный интервал между любыми двумя сообщениями бота
+func (c SendQueueConfig) GlobalInterval() time.Duration {
+	return time.Second / time.Duration(max(c.GlobalRate, 1))
+}
+
+// ChatInterval возвращает минимальный интервал между сообщениями в один чат:
+// для групп — по group_rate в минуту, для личных чатов — по chat_rate в секунду
+func (c SendQueueConfig) ChatInterval(group bool) time.Duration {
+	if group {
+		return time.Minute / time.Duration(max(c.GroupRate, 1))
+	}
+	return time.Second / time.Duration(max(c.ChatRate, 1))
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RulesFile — файл с правилами из include или rules_dir.
+// Пример:
+//
+//	namespace: greetings     # префикс к text каждого правила: "greetings/Привет"
+//	bot: polite              # добавить правила только этому боту (по умолчанию — в корневые rules)
+//	defaults:
+//	  response: "Здрасьте"   # подставляется в правила, где поле не задано
+//	rules:
+//	  - text: "Привет"
+//	    pattern: '(?i)привет'
+type RulesFile struct {
+	Namespace string `yaml:"namespace"` // Префикс описаний правил файла
+	Bot       string `yaml:"bot"`       // Имя бота, которому добавляются правила (пусто — корневые правила)
+	Defaults  Rule   `yaml:"defaults"`  // Значения по умолчанию для незаданных полей правил
+	Rules     []Rule `yaml:"rules"`     // Правила файла
+}
+
+// ruleFiles возвращает отсортированный список файлов правил:
+// сначала совпадения шаблонов include, затем *.yaml и *.yml из rules_dir.
+// Пути, как и secrets, задаются относительно рабочего каталога.
+func (c *Config) ruleFiles() ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	addFile := func(path string) {
+		path = filepath.Clean(path)
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, pattern := range c.Include {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("include %q: %w", pattern, err)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			addFile(m)
+		}
+	}
+
+	if c.RulesDir != "" {
+		entries, err := os.ReadDir(c.RulesDir)
+		if err != nil {
+			return nil, fmt.Errorf("rules_dir: %w", err)
+		}
+		// ReadDir возвращает записи, отсортированные по имени
+		for _, e := range entries {
+			ext := filepath.Ext(e.Name())
+			if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+				continue
+			}
+			addFile(filepath.Join(c.RulesDir, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// loadRuleFiles читает файлы правил и добавляет их правила
+// в корневые rules или в правила указанного бота.
+// Вызывается до resolveBots. Ошибки содержат имя файла.
+func (c *Config) loadRuleFiles() error {
+	files, err := c.ruleFiles()
+	if err != nil {
+		return err
+	}
+	c.RuleFiles = files
+
+	for _, path := range files {
+		rf, err := readRulesFile(path)
+		if err != nil {
+			return err
+		}
+
+		if rf.Bot == "" {
+			c.Rules = append(c.Rules, rf.Rules...)
+			continue
+		}
+		i := slices.IndexFunc(c.Bots, func(b BotConfig) bool { return b.Name == rf.Bot })
+		if i < 0 {
+			return fmt.Errorf("%s: bot %q is not defined in bots", path, rf.Bot)
+		}
+		c.Bots[i].fileRules = append(c.Bots[i].fileRules, rf.Rules...)
+	}
+	return nil
+}
+
+// readRulesFile читает, проверяет и компилирует один файл правил
+func readRulesFile(path string) (*RulesFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read rules file: %w", err)
+	}
+	return ParseRulesFile(path, data)
+}
+
+// ParseRulesFile проверяет и компилирует документ с правилами.
+// path — имя файла или источника; оно попадает в ошибки и в Rule.Source.
+func ParseRulesFile(path string, data []byte) (*RulesFile, error) {
+	// Обязательные поля правил могут прийти из defaults, поэтому их наличие проверяется ниже
+	if err := validateYAML(path, data, reflect.TypeOf(RulesFile{}), false); err != nil {
+		return nil, err
+	}
+
+	var rf RulesFile
+	if err := yaml.Unmarshal(data, &rf); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// Узлы правил: по ним видно, какие поля заданы явно (в том числе false или 0)
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var ruleNodes []*yaml.Node
+	if len(doc.Content) > 0 {
+		if n := mappingValue(doc.Content[0], "rules"); n != nil && n.Kind == yaml.SequenceNode {
+			ruleNodes = n.Content
+		}
+	}
+
+	for i := range rf.Rules {
+		r := &rf.Rules[i]
+		var node *yaml.Node
+		if i < len(ruleNodes) {
+			node = ruleNodes[i]
+		}
+		r.applyDefaults(rf.Defaults, node)
+		if rf.Namespace != "" {
+			r.Text = rf.Namespace + "/" + r.Text
+		}
+		r.Source = path
+
+		var missing []string
+		if r.Pattern == "" {
+			missing = append(missing, "pattern")
+		}
+		if r.Response == "" && r.Experiment == nil && len(r.Actions) == 0 {
+			missing = append(missing, "response")
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("%s: rules[%d]: missing %s (set it in the rule or in defaults)", path, i, strings.Join(missing, ", "))
+		}
+		if err := r.Compile(); err != nil {
+			return nil, fmt.Errorf("%s: rules[%d]: %w", path, i, err)
+		}
+	}
+	return &rf, nil
+}
+
+// responseFields — поля YAML, которые вместе задают ответ правила
+var responseFields = []string{"response", "responses_i18n", "experiment"}
+
+// applyDefaults заполняет поля правила, которых нет в его узле YAML node, значениями из defaults.
+// Заданное явно значение, даже false или 0, не заменяется: правило может отменить shadow: true из defaults.
+// Учитываются все поля, читаемые из YAML, поэтому новые поля Rule
+// поддерживаются в defaults без изменений этой функции.
+// Ответ правила (response, responses_i18n и experiment) — одна группа: если правило задаёт
+// хотя бы одно из этих полей, ни одно из них не берётся из defaults, иначе ответ из defaults
+// и собственный эксперимент правила (или наоборот) оказались бы в правиле одновременно.
+// Списки, словари и эксперимент копируются: правила файла не делят их между собой.
+func (r *Rule) applyDefaults(defaults Rule, node *yaml.Node) {
+	keys := mappingKeys(node)
+	if slices.ContainsFunc(responseFields, func(k string) bool { return keys[k] }) {
+		for _, k := range responseFields {
+			keys[k] = true
+		}
+	}
+	dst := reflect.ValueOf(r).Elem()
+	src := reflect.ValueOf(defaults)
+	for _, f := range yamlFields(dst.Type()) {
+		if keys[f.name] {
+			continue
+		}
+		dst.FieldByIndex(f.Index).Set(src.FieldByIndex(f.Index))
+		switch f.name {
+		case "responses_i18n":
+			r.I18n = maps.Clone(r.I18n)
+		case "actions":
+			r.Actions = slices.Clone(r.Actions)
+		case "experiment":
+			r.Experiment = r.Experiment.clone()
+		}
+	}
+}
+
+// mappingKeys возвращает ключи mapping-узла, значения которых не null,
+// включая ключи, подставленные слиянием <<: *anchor
+func mappingKeys(n *yaml.Node) map[string]bool {
+	keys := make(map[string]bool)
+	if n == nil {
+		return keys
+	}
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind != yaml.MappingNode {
+		return keys
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i], n.Content[i+1]
+		if key.Value == "<<" {
+			merged := []*yaml.Node{val}
+			if val.Kind == yaml.SequenceNode {
+				merged = val.Content
+			}
+			for _, m := range merged {
+				maps.Copy(keys, mappingKeys(m))
+			}
+			continue
+		}
+		if val.Tag != "!!null" {
+			keys[key.Value] = true
+		}
+	}
+	return keys
+}
+
+// mappingValue возвращает значение ключа key mapping-узла n или nil
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// hashFiles вычисляет общий SHA256 хеш содержимого и имён файлов.
+// Добавление, удаление и переименование файла тоже меняют хеш.
+func hashFiles(files []string) (string, error) {
+	h := sha256.New()
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot read rules file: %w", err)
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", path, len(data))
+		h.Write(data)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
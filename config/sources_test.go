@@ -0,0 +1,92 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/st-kuptsov/balabol/config"
+)
+
+// experimentYAML — эксперимент с двумя вариантами, с отступом полей правила
+const experimentYAML = `    experiment:
+      variants:
+        - name: a
+          response: первый
+        - name: b
+          response: второй
+`
+
+func TestRulesFileDefaults(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		doc        string
+		response   string // ожидаемый response правила
+		i18n       bool   // есть ли responses_i18n
+		experiment bool   // есть ли эксперимент
+		shadow     bool
+	}{
+		{
+			name: "response from defaults",
+			doc: "defaults:\n  response: по умолчанию\n" +
+				"rules:\n  - text: r\n    pattern: x\n",
+			response: "по умолчанию",
+		},
+		{
+			name: "own experiment ignores default response",
+			doc: "defaults:\n  response: по умолчанию\n  responses_i18n:\n    en: default\n" +
+				"rules:\n  - text: r\n    pattern: x\n" + experimentYAML,
+			experiment: true,
+		},
+		{
+			name: "own response ignores default experiment",
+			doc: "defaults:\n  experiment:\n    variants:\n      - name: a\n        response: первый\n      - name: b\n        response: второй\n" +
+				"rules:\n  - text: r\n    pattern: x\n    response: свой\n",
+			response: "свой",
+		},
+		{
+			name: "own translations ignore default response",
+			doc: "defaults:\n  response: по умолчанию\n" +
+				"rules:\n  - text: r\n    pattern: x\n    response: свой\n    responses_i18n:\n      en: own\n",
+			response: "свой",
+			i18n:     true,
+		},
+		{
+			name: "own response ignores default translations",
+			doc: "defaults:\n  response: по умолчанию\n  responses_i18n:\n    en: default\n" +
+				"rules:\n  - text: r\n    pattern: x\n    response: свой\n",
+			response: "свой",
+		},
+		{
+			name: "shadow from defaults",
+			doc: "defaults:\n  shadow: true\n" +
+				"rules:\n  - text: r\n    pattern: x\n    response: свой\n",
+			response: "свой",
+			shadow:   true,
+		},
+		{
+			name: "explicit shadow false overrides defaults",
+			doc: "defaults:\n  shadow: true\n" +
+				"rules:\n  - text: r\n    pattern: x\n    response: свой\n    shadow: false\n",
+			response: "свой",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rf, err := config.ParseRulesFile("rules.yaml", []byte(tc.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := rf.Rules[0]
+			if r.Response != tc.response {
+				t.Errorf("response = %q, want %q", r.Response, tc.response)
+			}
+			if got := len(r.I18n) > 0; got != tc.i18n {
+				t.Errorf("responses_i18n = %v, want present %v", r.I18n, tc.i18n)
+			}
+			if got := r.Experiment != nil; got != tc.experiment {
+				t.Errorf("experiment = %+v, want present %v", r.Experiment, tc.experiment)
+			}
+			if r.Shadow != tc.shadow {
+				t.Errorf("shadow = %v, want %v", r.Shadow, tc.shadow)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// minDigestInterval — минимальный период сводки
+const minDigestInterval = time.Minute
+
+// resolveStats проверяет секцию stats и выбирает бота для сводки.
+// Вызывается после resolveBots.
+func (c *Config) resolveStats() error {
+	digest := &c.Stats.Digest
+	if !digest.Enabled {
+		return nil
+	}
+	if digest.ChatID == 0 {
+		return fmt.Errorf("stats.digest.chat_id is required when the digest is enabled")
+	}
+	if digest.Interval < minDigestInterval {
+		return fmt.Errorf("stats.digest.interval must be at least %s, got %s", minDigestInterval, digest.Interval)
+	}
+	if digest.Bot == "" {
+		digest.Bot = c.Bots[0].Name
+	}
+	if c.Bot(digest.Bot) == nil {
+		return fmt.Errorf("stats.digest.bot: unknown bot %q", digest.Bot)
+	}
+	return nil
+}
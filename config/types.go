@@ -1,52 +1,364 @@
 package config
 
-import "regexp"
+import (
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Способы получения обновлений от Telegram (telegram.poller)
+const (
+	PollerLongPolling = "long_polling" // классический long polling через getUpdates
+	PollerWebhook     = "webhook"      // входящие запросы от Telegram на собственный HTTP-сервер
+)
+
+// DefaultAPIURL — адрес Telegram Bot API по умолчанию (telegram.api_url)
+const DefaultAPIURL = "https://api.telegram.org"
+
+// Режимы скрытия текста сообщений в логах и журнале аудита (log_settings.redact)
+const (
+	RedactNone   = "none"   // текст пишется как есть
+	RedactHash   = "hash"   // вместо текста — короткий SHA-256
+	RedactLength = "length" // вместо текста — только его длина
+	RedactHidden = "hidden" // текст полностью скрыт
+)
+
+// Действия модерации (rules[].actions[].type)
+const (
+	ActionDelete   = "delete"   // удалить сообщение
+	ActionRestrict = "restrict" // запретить отправителю писать на duration
+	ActionBan      = "ban"      // исключить отправителя из чата на duration
+	ActionPin      = "pin"      // закрепить сообщение
+	ActionWarn     = "warn"     // предупреждение с учётом в счётчике и эскалацией
+)
+
+// За кем закрепляется вариант эксперимента (rules[].experiment.sticky)
+const (
+	StickyChat = "chat" // один вариант на чат
+	StickyUser = "user" // один вариант на пользователя во всех чатах
+)
+
+// Форматы вывода логов в консоль (log_settings.console_format)
+const (
+	LogFormatJSON    = "json"    // одна JSON-строка на запись
+	LogFormatConsole = "console" // человекочитаемый формат
+)
 
 // Config представляет основную конфигурацию приложения.
 type Config struct {
-	Rules       []Rule         `yaml:"rules"`                             // Список правил фильтрации/ответов
-	Telegram    TelegramConfig `yaml:"telegram"`                          // Настройки Telegram-бота
-	Logging     LogConfig      `yaml:"log_settings"`                      // Настройки логирования
-	CleanFilter string         `yaml:"clean_filter"`                      // Фильтр для очистки текста перед обработкой
-	RemoveDup   bool           `yaml:"remove_duplicate_letters"`          // Удалять ли повторяющиеся буквы
-	BotMode     string         `yaml:"bot_mode" env-default:"first_last"` // Режим работы бота
-	SecretsPath string         `yaml:"secrets"`                           // Путь к файлу секретов (например, токен Telegram)
-	ServicePort int            `yaml:"service_port" env-default:"9090"`   // Порт сервиса для Prometheus метрик
+	Rules        []Rule             `yaml:"rules"`                                                   // Список правил фильтрации/ответов
+	Telegram     TelegramConfig     `yaml:"telegram"`                                                // Настройки Telegram-бота
+	Logging      LogConfig          `yaml:"log_settings"`                                            // Настройки логирования
+	CleanFilter  string             `yaml:"clean_filter" format:"regex"`                             // Фильтр для очистки текста перед обработкой
+	RemoveDup    bool               `yaml:"remove_duplicate_letters"`                                // Удалять ли повторяющиеся буквы
+	BotMode      string             `yaml:"bot_mode" env-default:"first_last" enum:"first_last,all"` // Режим работы бота
+	SecretsPath  string             `yaml:"secrets"`                                                 // Путь к файлу секретов (например, токен Telegram)
+	ServicePort  int                `yaml:"service_port" env-default:"9090" min:"1" max:"65535"`     // Порт сервиса для Prometheus метрик
+	Bots         []BotConfig        `yaml:"bots"`                                                    // Несколько ботов в одном процессе (если пусто — один бот из корневых настроек)
+	Tracing      TracingConfig      `yaml:"tracing"`                                                 // Настройки трассировки OpenTelemetry
+	Audit        AuditConfig        `yaml:"audit"`                                                   // Журнал аудита ответов бота
+	AdminToken   string             `yaml:"-"`                                                       // Токен служебных HTTP-эндпоинтов (из файла секретов)
+	Include      []string           `yaml:"include"`                                                 // Шаблоны (glob) файлов с правилами
+	RulesDir     string             `yaml:"rules_dir"`                                               // Каталог с файлами правил (*.yaml, *.yml)
+	RuleFiles    []string           `yaml:"-"`                                                       // Прочитанные файлы правил (для отслеживания изменений)
+	RuleSources  []RuleSourceConfig `yaml:"rule_sources"`                                            // Удалённые источники правил (HTTP)
+	I18n         I18nConfig         `yaml:"i18n"`                                                    // Язык ответов и системных сообщений
+	SenderPolicy SenderPolicyConfig `yaml:"sender_policy"`                                           // Какие сообщения бот игнорирует
+	Moderation   ModerationConfig   `yaml:"moderation"`                                              // Предупреждения и их эскалация
+	Stats        StatsConfig        `yaml:"stats"`                                                   // Статистика чатов: /stats и периодическая сводка
+	SendQueue    SendQueueConfig    `yaml:"send_queue"`                                              // Очередь исходящих сообщений: лимиты, повторы, dead letter
+	DryRun       bool               `yaml:"dry_run" env-default:"false"`                             // Ничего не отправлять в Telegram: ответы и действия только в лог, метрики и аудит
+	Experiments  ExperimentsConfig  `yaml:"experiments"`                                             // A/B-эксперименты над ответами правил: учёт исходов и результаты
+}
+
+// BotConfig описывает одного бота из секции bots.
+// Незаданные поля наследуются из корневых настроек конфигурации.
+type BotConfig struct {
+	Name         string             `yaml:"name" required:"true"`           // Уникальное имя бота, используется в логах и метриках
+	TokenSecret  string             `yaml:"token_secret"`                   // Ключ в секции bots файла секретов (по умолчанию совпадает с name)
+	Rules        []Rule             `yaml:"rules"`                          // Собственные правила бота
+	BotMode      string             `yaml:"bot_mode" enum:"first_last,all"` // Режим работы бота
+	CleanFilter  string             `yaml:"clean_filter" format:"regex"`    // Фильтр для очистки текста
+	RemoveDup    *bool              `yaml:"remove_duplicate_letters"`       // Удалять ли повторяющиеся буквы
+	Telegram     TelegramConfig     `yaml:"telegram"`                       // Настройки получения обновлений
+	Redact       string             `yaml:"-"`                              // Скрытие текста сообщений (из log_settings.redact)
+	I18n         I18nConfig         `yaml:"-"`                              // Настройки языка (из секции i18n)
+	SenderPolicy SenderPolicyConfig `yaml:"-"`                              // Фильтр отправителей (из секции sender_policy)
+	Moderation   ModerationConfig   `yaml:"-"`                              // Настройки предупреждений (из секции moderation)
+	Stats        StatsConfig        `yaml:"-"`                              // Настройки статистики (из секции stats)
+	SendQueue    SendQueueConfig    `yaml:"-"`                              // Очередь исходящих сообщений (из секции send_queue)
+	DryRun       bool               `yaml:"-"`                              // Ничего не отправлять (из dry_run)
+	Experiments  ExperimentsConfig  `yaml:"-"`                              // Учёт исходов экспериментов (из секции experiments)
+	cleanRe      *regexp.Regexp     `yaml:"-"`                              // Скомпилированный clean_filter
+	fileRules    []Rule             `yaml:"-"`                              // Правила из файлов правил с bot: <имя>
+	inheritRules bool               `yaml:"-"`                              // Правила унаследованы из корня (к ним добавляются корневые правила источников)
 }
 
 // Rule представляет одно правило для бота:
 //   - Pattern — регулярное выражение для сопоставления текста
 //   - Response — текст ответа, если правило сработало
+//   - I18n — ответы на других языках (responses_i18n), Response — ответ на языке по умолчанию
+//   - Actions — действия модерации; правило с действиями может обходиться без Response
+//   - Shadow — теневое правило: проверяется и учитывается в метриках и логе, но не отвечает и не выполняет действия
+//   - Experiment — A/B-эксперимент: вместо Response бот отвечает одним из вариантов
 //   - Text — дополнительное описание правила
 //   - re — скомпилированное регулярное выражение (не сохраняется в YAML)
 type Rule struct {
-	Text     string         `yaml:"text"`     // Описание правила
-	Pattern  string         `yaml:"pattern"`  // Регулярное выражение в виде строки
-	Response string         `yaml:"response"` // Ответ бота при совпадении
-	re       *regexp.Regexp `yaml:"-"`        // Скомпилированное регулярное выражение
+	Text       string            `yaml:"text"`                                          // Описание правила
+	Pattern    string            `yaml:"pattern" required:"true" format:"regex"`        // Регулярное выражение в виде строки
+	Response   string            `yaml:"response" required_unless:"experiment,actions"` // Ответ бота при совпадении (обязателен, если нет experiment и actions)
+	I18n       map[string]string `yaml:"responses_i18n"`                                // Ответы на других языках: код языка → текст
+	Actions    []Action          `yaml:"actions"`                                       // Действия модерации при совпадении
+	Shadow     bool              `yaml:"shadow"`                                        // Теневое правило: только метрика bot_rule_shadow_hits_total и лог
+	Experiment *Experiment       `yaml:"experiment"`                                    // A/B-эксперимент: варианты ответа вместо response
+	Source     string            `yaml:"-"`                                             // Файл правил, из которого загружено правило (пусто — config.yaml)
+	re         *regexp.Regexp    `yaml:"-"`                                             // Скомпилированное регулярное выражение
+}
+
+// Experiment описывает A/B-эксперимент над ответом правила.
+// Вариант выбирается детерминированным хешем от имени эксперимента и ID чата (или пользователя),
+// поэтому один чат всегда получает один и тот же вариант, пока не изменятся варианты и их веса.
+type Experiment struct {
+	Name     string    `yaml:"name" json:"name,omitempty"`                      // Имя в метриках и отчёте (по умолчанию text правила)
+	Sticky   string    `yaml:"sticky" json:"sticky,omitempty" enum:"chat,user"` // За кем закрепляется вариант: chat (по умолчанию) или user
+	Variants []Variant `yaml:"variants" json:"variants" required:"true"`        // Варианты ответа, не меньше двух
+}
+
+// Variant — вариант ответа в эксперименте. Доля трафика варианта — его вес, делённый на сумму весов.
+type Variant struct {
+	Name     string            `yaml:"name" json:"name" required:"true"`                         // Имя варианта в метриках и отчёте
+	Weight   *int              `yaml:"weight,omitempty" json:"weight,omitempty" min:"0"`         // Вес (по умолчанию 1; 0 — вариант не выбирается)
+	Response string            `yaml:"response" json:"response" required:"true"`                 // Ответ бота
+	I18n     map[string]string `yaml:"responses_i18n,omitempty" json:"responses_i18n,omitempty"` // Ответы на других языках
 }
 
 // TelegramConfig хранит настройки Telegram-бота
 type TelegramConfig struct {
-	Token string // Токен бота
+	Token       string            // Токен бота
+	APIURL      string            `yaml:"api_url" env-default:"https://api.telegram.org" format:"url"`   // Адрес Bot API (собственный сервер telegram-bot-api или тестовый)
+	Poller      string            `yaml:"poller" env-default:"long_polling" enum:"long_polling,webhook"` // Способ получения обновлений: long_polling или webhook
+	LongPolling LongPollingConfig `yaml:"long_polling"`                                                  // Настройки long polling
+	Webhook     WebhookConfig     `yaml:"webhook"`                                                       // Настройки webhook
+	Reactions   bool              `yaml:"-"`                                                             // Получать обновления message_reaction (из experiments.reactions)
+}
+
+// LongPollingConfig хранит настройки получения обновлений через getUpdates
+type LongPollingConfig struct {
+	Timeout time.Duration `yaml:"timeout" env-default:"10s"` // Таймаут одного запроса getUpdates
+}
+
+// WebhookConfig хранит настройки приёма обновлений через webhook
+type WebhookConfig struct {
+	Listen         string `yaml:"listen" env-default:":8443" format:"listen"`         // Адрес, на котором слушает HTTP-сервер webhook
+	Path           string `yaml:"path" env-default:"/telegram/webhook"`               // Путь, на который Telegram отправляет обновления
+	PublicURL      string `yaml:"public_url"`                                         // Публичный URL webhook, регистрируемый в Telegram
+	CertFile       string `yaml:"cert_file"`                                          // Путь к TLS-сертификату (если пусто — обычный HTTP)
+	KeyFile        string `yaml:"key_file"`                                           // Путь к приватному ключу TLS
+	SelfSigned     bool   `yaml:"self_signed"`                                        // Загружать ли сертификат в Telegram (самоподписанный)
+	MaxConnections int    `yaml:"max_connections" env-default:"40" min:"1" max:"100"` // Максимум одновременных соединений от Telegram
+	DropPending    bool   `yaml:"drop_pending_updates"`                               // Сбрасывать ли накопленные обновления при регистрации
+	SecretToken    string `yaml:"-"`                                                  // Секрет для заголовка X-Telegram-Bot-Api-Secret-Token (из файла секретов)
+}
+
+// RuleSourceConfig описывает удалённый источник правил.
+// Источник отдаёт документ в формате файла правил (RulesFile) в YAML или JSON.
+type RuleSourceConfig struct {
+	Name            string        `yaml:"name" required:"true"` // Уникальное имя источника (лейбл source в метриках, ключ в секретах)
+	URL             string        `yaml:"url" required:"true"`  // Адрес документа с правилами (http или https)
+	Interval        time.Duration `yaml:"interval"`             // Период опроса (по умолчанию 1m)
+	Timeout         time.Duration `yaml:"timeout"`              // Таймаут одного запроса (по умолчанию 30s)
+	AuthHeader      string        `yaml:"auth_header"`          // Заголовок авторизации; значение — rule_sources.<name> в файле секретов
+	SHA256          string        `yaml:"sha256"`               // Ожидаемый SHA256 документа (hex); документ с другим хешем отклоняется
+	PublicKey       string        `yaml:"public_key"`           // Открытый ключ Ed25519 (base64) для проверки подписи документа
+	SignatureHeader string        `yaml:"signature_header"`     // Заголовок с подписью Ed25519 (base64), по умолчанию X-Signature-Ed25519
+	AuthValue       string        `yaml:"-"`                    // Значение заголовка авторизации (из файла секретов)
+}
+
+// Action — действие модерации, которое выполняет правило.
+// Бот должен быть администратором чата с нужными правами: delete — удаление сообщений,
+// restrict и ban — ограничение участников, pin — закрепление сообщений.
+type Action struct {
+	Type     string        `yaml:"type" required:"true" enum:"delete,restrict,ban,pin,warn"` // Вид действия
+	Duration time.Duration `yaml:"duration"`                                                 // Срок restrict и ban (0 — бессрочно)
+	DryRun   bool          `yaml:"dry_run"`                                                  // Только записать в лог и метрики, не выполняя
+}
+
+// ModerationConfig хранит настройки предупреждений (действие warn).
+// Счётчик ведётся для каждой пары чат — пользователь и сохраняется в файл.
+type ModerationConfig struct {
+	WarningsFile string           `yaml:"warnings_file" env-default:"data/warnings.json"` // Файл со счётчиками предупреждений
+	WarningsTTL  time.Duration    `yaml:"warnings_ttl"`                                   // Через сколько без новых предупреждений счётчик сбрасывается (0 — никогда)
+	Escalation   []EscalationStep `yaml:"escalation"`                                     // Что делать при достижении числа предупреждений
+}
+
+// EscalationStep — действие, которое выполняется, когда у пользователя набирается warnings предупреждений.
+// После последней ступени счётчик сбрасывается.
+type EscalationStep struct {
+	Warnings int           `yaml:"warnings" required:"true" min:"1"`         // Число предупреждений
+	Type     string        `yaml:"type" required:"true" enum:"restrict,ban"` // Действие
+	Duration time.Duration `yaml:"duration"`                                 // Срок (0 — бессрочно)
+	DryRun   bool          `yaml:"dry_run"`                                  // Только записать в лог и метрики
+}
+
+// StatsConfig хранит настройки статистики чатов: команды /stats и периодической сводки.
+// Счётчики срабатываний правил, участников и ответов сохраняются в файл раз в минуту и при остановке.
+type StatsConfig struct {
+	File    string       `yaml:"file" env-default:"data/stats.json"`   // Файл со счётчиками
+	Command bool         `yaml:"command" env-default:"true"`           // Отвечать на команду /stats
+	Top     int          `yaml:"top" env-default:"5" min:"1" max:"50"` // Сколько правил и участников показывать
+	Digest  DigestConfig `yaml:"digest"`                               // Периодическая сводка в чат администраторов
+}
+
+// ExperimentsConfig хранит настройки учёта A/B-экспериментов (experiment в правилах).
+// Исход ответа — реплай на сообщение бота или реакция на него в течение window.
+// Результаты сохраняются в файл раз в минуту и при остановке.
+type ExperimentsConfig struct {
+	File      string        `yaml:"file" env-default:"data/experiments.json"` // Файл с результатами
+	Window    time.Duration `yaml:"window" env-default:"30m"`                 // Сколько ждать реплая или реакции после ответа
+	Reactions bool          `yaml:"reactions" env-default:"true"`             // Учитывать реакции (в группах бот должен быть администратором)
+}
+
+// DigestConfig описывает периодическую сводку: статистику чатов, отправляемую в чат администраторов
+type DigestConfig struct {
+	Enabled  bool          `yaml:"enabled" env-default:"false"` // Отправлять ли сводку
+	Bot      string        `yaml:"bot"`                         // Бот, который отправляет сводку и чьи чаты в неё входят (по умолчанию первый)
+	ChatID   int64         `yaml:"chat_id"`                     // Чат администраторов
+	Chats    []int64       `yaml:"chats"`                       // Чаты в сводке (пусто — все, где правила срабатывали за интервал)
+	Interval time.Duration `yaml:"interval" env-default:"24h"`  // Период отправки
+}
+
+// SendQueueConfig хранит настройки очереди исходящих сообщений.
+// У каждого бота своя очередь; сообщения в один чат отправляются строго по порядку.
+// Лимиты по умолчанию соответствуют ограничениям Telegram: около 30 сообщений в секунду,
+// не больше одного сообщения в секунду в один чат и 20 сообщений в минуту в группу.
+type SendQueueConfig struct {
+	GlobalRate   int              `yaml:"global_rate" env-default:"30" min:"1"` // Сообщений в секунду для всех чатов бота
+	ChatRate     int              `yaml:"chat_rate" env-default:"1" min:"1"`    // Сообщений в секунду в один личный чат
+	GroupRate    int              `yaml:"group_rate" env-default:"20" min:"1"`  // Сообщений в минуту в одну группу
+	Size         int              `yaml:"size" env-default:"1000" min:"1"`      // Максимум сообщений в очереди бота; сверх него — dead letter
+	MaxAttempts  int              `yaml:"max_attempts" env-default:"5" min:"1"` // Попыток отправки до dead letter
+	RetryBackoff time.Duration    `yaml:"retry_backoff" env-default:"1s"`       // Пауза перед первым повтором; удваивается с каждой попыткой
+	MaxBackoff   time.Duration    `yaml:"max_backoff" env-default:"30s"`        // Максимальная пауза между повторами
+	MaxAge       time.Duration    `yaml:"max_age" env-default:"5m"`             // Сообщение, не доставленное за это время, уходит в dead letter
+	Breaker      BreakerConfig    `yaml:"breaker"`                              // Предохранитель на случай недоступности Bot API
+	DeadLetter   DeadLetterConfig `yaml:"dead_letter"`                          // Журнал недоставленных сообщений
+}
+
+// BreakerConfig хранит настройки предохранителя отправки: после failures сбоев подряд
+// отправка приостанавливается на cooldown, затем одно пробное сообщение проверяет, доступен ли Bot API.
+type BreakerConfig struct {
+	Failures int           `yaml:"failures" env-default:"5" min:"1"` // Сбоев подряд (сеть, 5xx) до размыкания
+	Cooldown time.Duration `yaml:"cooldown" env-default:"30s"`       // Пауза перед пробной отправкой
+}
+
+// DeadLetterConfig хранит настройки журнала недоставленных сообщений.
+// Журнал пишется по одной JSON-строке на сообщение и ротируется так же, как app.log.
+type DeadLetterConfig struct {
+	Enabled    bool   `yaml:"enabled" env-default:"true"`              // Включён ли журнал
+	Directory  string `yaml:"directory" env-default:"logs"`            // Директория журнала
+	Filename   string `yaml:"filename" env-default:"dead_letters.log"` // Имя файла журнала
+	MaxSize    int    `yaml:"max_size" env-default:"10"`               // Максимальный размер файла в МБ
+	MaxBackups int    `yaml:"max_backups" env-default:"5"`             // Количество резервных копий
+	MaxAge     int    `yaml:"max_age" env-default:"30"`                // Срок хранения записей в днях
+	Compress   bool   `yaml:"compress" env-default:"true"`             // Сжимать ли старые файлы
+}
+
+// I18nConfig хранит настройки выбора языка ответов и системных сообщений.
+// Язык выбирается по цепочке: настройка пользователя (users), настройка чата (chats),
+// language_code отправителя в Telegram, default_language.
+type I18nConfig struct {
+	DefaultLanguage string                       `yaml:"default_language" env-default:"ru"` // Язык по умолчанию (на нём написаны response правил)
+	Chats           map[int64]string             `yaml:"chats"`                             // Язык чата: ID чата → код языка
+	Users           map[int64]string             `yaml:"users"`                             // Язык пользователя: ID пользователя → код языка
+	Messages        map[string]map[string]string `yaml:"messages"`                          // Переопределение системных сообщений: код языка → ключ → текст
+	LangCommand     bool                         `yaml:"lang_command" env-default:"false"`  // Отвечать на команду /lang выбранным языком
+}
+
+// SenderPolicyConfig описывает, от каких отправителей бот не принимает сообщения.
+// Отброшенные сообщения не обрабатываются правилами и учитываются в bot_messages_dropped_total.
+type SenderPolicyConfig struct {
+	IgnoreBots       bool        `yaml:"ignore_bots" env-default:"true"`        // Игнорировать сообщения ботов (защита от переписки ботов друг с другом)
+	IgnoreForwards   bool        `yaml:"ignore_forwards" env-default:"true"`    // Игнорировать пересланные сообщения
+	IgnoreViaBot     bool        `yaml:"ignore_via_bot" env-default:"true"`     // Игнорировать сообщения, отправленные через inline-ботов (via_bot)
+	IgnoreSenderChat bool        `yaml:"ignore_sender_chat" env-default:"true"` // Игнорировать сообщения от имени чата (анонимные админы, посты каналов)
+	Users            AccessList  `yaml:"users"`                                 // Списки пользователей по ID
+	Chats            AccessList  `yaml:"chats"`                                 // Списки чатов по ID
+	Flood            FloodConfig `yaml:"flood"`                                 // Защита от флуда одного пользователя
+}
+
+// AccessList — списки разрешённых и запрещённых ID.
+// Запрет важнее разрешения; непустой allow пропускает только перечисленные ID.
+type AccessList struct {
+	Allow []int64 `yaml:"allow"` // Разрешённые ID (пусто — разрешены все)
+	Block []int64 `yaml:"block"` // Запрещённые ID
+}
+
+// FloodConfig хранит настройки обнаружения флуда: если пользователь отправил
+// больше messages сообщений за window, бот не отвечает ему в течение mute.
+type FloodConfig struct {
+	Enabled  bool          `yaml:"enabled" env-default:"false"`       // Включено ли обнаружение флуда
+	Messages int           `yaml:"messages" env-default:"10" min:"1"` // Сколько сообщений допускается за window
+	Window   time.Duration `yaml:"window" env-default:"1m"`           // Окно подсчёта сообщений
+	Mute     time.Duration `yaml:"mute" env-default:"10m"`            // На сколько бот перестаёт отвечать пользователю
 }
 
 // LogConfig хранит настройки логирования приложения
 type LogConfig struct {
-	Directory  string `yaml:"directory" env-default:"logs"`        // Директория для логов
-	Filename   string `yaml:"filename" env-default:"app.log"`      // Имя файла логов
-	MaxSize    int    `yaml:"max_size" env-default:"10"`           // Максимальный размер файла в МБ
-	MaxBackups int    `yaml:"max_backups" env-default:"1"`         // Количество резервных копий
-	MaxAge     int    `yaml:"max_age" env-default:"1"`             // Срок хранения логов в днях
-	Compress   bool   `yaml:"compress" env-default:"true"`         // Сжимать ли старые файлы
-	Level      string `yaml:"level" env-default:"info"`            // Уровень логирования (debug/info/warn/error)
-	Console    bool   `yaml:"console_enabled" env-default:"false"` // Вывод логов в консоль
+	Directory  string `yaml:"directory" env-default:"logs"`                                  // Директория для логов
+	Filename   string `yaml:"filename" env-default:"app.log"`                                // Имя файла логов
+	MaxSize    int    `yaml:"max_size" env-default:"10"`                                     // Максимальный размер файла в МБ
+	MaxBackups int    `yaml:"max_backups" env-default:"1"`                                   // Количество резервных копий
+	MaxAge     int    `yaml:"max_age" env-default:"1"`                                       // Срок хранения логов в днях
+	Compress   bool   `yaml:"compress" env-default:"true"`                                   // Сжимать ли старые файлы
+	Level      string `yaml:"level" env-default:"info" enum:"debug,info,warn,warning,error"` // Уровень логирования (debug/info/warn/error)
+	Console    bool   `yaml:"console_enabled" env-default:"false"`                           // Вывод логов в консоль
+	Redact     string `yaml:"redact" env-default:"none" enum:"none,hash,length,hidden"`      // Скрытие текста сообщений (none/hash/length/hidden)
+
+	ConsoleFormat string       `yaml:"console_format" env-default:"json" enum:"json,console"` // Формат консоли: json или console (человекочитаемый)
+	ConsoleLevel  string       `yaml:"console_level" enum:"debug,info,warn,warning,error"`    // Уровень консоли (пусто — общий level)
+	FileLevel     string       `yaml:"file_level" enum:"debug,info,warn,warning,error"`       // Уровень файла (пусто — общий level)
+	Syslog        SyslogConfig `yaml:"syslog"`                                                // Отправка логов в локальный syslog
+}
+
+// SyslogConfig хранит настройки приёмника логов syslog
+type SyslogConfig struct {
+	Enabled bool   `yaml:"enabled" env-default:"false"`                // Включена ли отправка в syslog
+	Address string `yaml:"address" env-default:"/dev/log"`             // Путь к unix-сокету syslog
+	Tag     string `yaml:"tag" env-default:"balabol"`                  // Тег (имя программы) в записях syslog
+	Level   string `yaml:"level" enum:"debug,info,warn,warning,error"` // Уровень syslog (пусто — общий level)
+}
+
+// AuditConfig хранит настройки журнала аудита ответов бота.
+// Журнал пишется отдельно от основного лога и ротируется так же, как app.log.
+type AuditConfig struct {
+	Enabled    bool   `yaml:"enabled" env-default:"false"`      // Включён ли журнал аудита
+	Directory  string `yaml:"directory" env-default:"logs"`     // Директория журнала
+	Filename   string `yaml:"filename" env-default:"audit.log"` // Имя файла журнала
+	MaxSize    int    `yaml:"max_size" env-default:"100"`       // Максимальный размер файла в МБ
+	MaxBackups int    `yaml:"max_backups" env-default:"30"`     // Количество резервных копий
+	MaxAge     int    `yaml:"max_age" env-default:"90"`         // Срок хранения записей в днях
+	Compress   bool   `yaml:"compress" env-default:"true"`      // Сжимать ли старые файлы
+}
+
+// TracingConfig хранит настройки трассировки OpenTelemetry
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled" env-default:"false"`                  // Включена ли трассировка
+	Endpoint    string  `yaml:"endpoint" env-default:"http://localhost:4318"` // URL коллектора OTLP/HTTP
+	ServiceName string  `yaml:"service_name" env-default:"balabol"`           // Имя сервиса в трассах
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1" min:"0" max:"1"` // Доля сэмплируемых трасс (0..1]
 }
 
 // CachedConfig хранит загруженный конфиг и хеши файлов
-// для отслеживания изменений и безопасного reload
+// для отслеживания изменений и безопасного reload.
+// Config — итоговая конфигурация: файлы плюс правила удалённых источников.
+// Для чтения из нескольких горутин используйте Current.
 type CachedConfig struct {
+	mu          sync.RWMutex
 	Config      *Config // Основная конфигурация
 	ConfigHash  string  // SHA256 хеш основного конфига
 	SecretsHash string  // SHA256 хеш файла секретов
+	RulesHash   string  // общий SHA256 хеш файлов правил (include и rules_dir)
+
+	local  *Config               // конфигурация из файлов, без правил удалённых источников
+	remote map[string]*RulesFile // последние успешно загруженные правила источников
 }
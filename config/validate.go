@@ -0,0 +1,423 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Проверка конфигурации по описанию структур Config.
+// Кроме тега yaml используются теги:
+//   - required:"true"   — поле обязательно и не может быть пустым
+//   - required_unless:"a,b" — поле обязательно и не может быть пустым, если не заданы поля a и b
+//   - enum:"a,b"        — допустимые значения (пустое значение разрешено, если поле не обязательное)
+//   - min:"1" max:"10"  — допустимый диапазон числа
+//   - format:"regex"    — значение должно компилироваться как регулярное выражение
+//   - format:"listen"   — адрес вида host:port с портом 1..65535
+//   - format:"url"      — абсолютный адрес http или https
+
+// Problem — одна ошибка конфигурации с позицией в файле
+type Problem struct {
+	Line    int    // номер строки (с 1), 0 — позиция неизвестна
+	Column  int    // номер столбца (с 1)
+	Path    string // путь к полю, например bots[0].rules[2].response
+	Message string // описание ошибки
+}
+
+// ValidationError содержит все ошибки, найденные в файле конфигурации
+type ValidationError struct {
+	File     string
+	Problems []Problem
+}
+
+// Error выводит каждую ошибку отдельной строкой в формате file:line:col: path: message
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d problem(s)", e.File, len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  ")
+		if p.Line > 0 {
+			fmt.Fprintf(&b, "%s:%d:%d: ", e.File, p.Line, p.Column)
+		} else {
+			fmt.Fprintf(&b, "%s: ", e.File)
+		}
+		if p.Path != "" {
+			b.WriteString(p.Path + ": ")
+		}
+		b.WriteString(p.Message)
+	}
+	return b.String()
+}
+
+// Validate строго проверяет файл конфигурации: неизвестные поля, типы значений,
+// обязательные поля, допустимые значения и диапазоны.
+// Возвращает *ValidationError со всеми найденными ошибками сразу.
+func Validate(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %w", err)
+	}
+	return validateYAML(path, data, reflect.TypeOf(Config{}), true)
+}
+
+// validateYAML проверяет YAML-документ data по структуре типа t.
+// name используется в сообщениях об ошибках вместо пути к файлу.
+// requireFields=false отключает проверку отсутствующих обязательных полей —
+// для файлов, где их могут подставить значения по умолчанию.
+func validateYAML(name string, data []byte, t reflect.Type, requireFields bool) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return &ValidationError{File: name, Problems: []Problem{{Message: err.Error()}}}
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	v := &validator{requireFields: requireFields}
+	v.walk(doc.Content[0], t, "")
+	if len(v.problems) == 0 {
+		return nil
+	}
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		if v.problems[i].Line != v.problems[j].Line {
+			return v.problems[i].Line < v.problems[j].Line
+		}
+		return v.problems[i].Column < v.problems[j].Column
+	})
+	return &ValidationError{File: name, Problems: v.problems}
+}
+
+// validator обходит дерево YAML параллельно с типом и накапливает ошибки
+type validator struct {
+	requireFields bool // проверять ли отсутствие обязательных полей
+	problems      []Problem
+}
+
+// add добавляет ошибку с позицией узла n
+func (v *validator) add(n *yaml.Node, path, format string, args ...any) {
+	v.problems = append(v.problems, Problem{
+		Line:    n.Line,
+		Column:  n.Column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// durationType — time.Duration задаётся в YAML строкой вида "10s"
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// walk проверяет узел n на соответствие типу t
+func (v *validator) walk(n *yaml.Node, t reflect.Type, path string) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!null" {
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == durationType:
+		if n.Kind != yaml.ScalarNode {
+			v.add(n, path, "expected duration, got %s", kindName(n))
+			return
+		}
+		if _, err := time.ParseDuration(n.Value); err != nil {
+			v.add(n, path, "invalid duration %q (examples: 10s, 1m30s)", n.Value)
+		}
+
+	case t.Kind() == reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			v.add(n, path, "expected mapping, got %s", kindName(n))
+			return
+		}
+		v.walkStruct(n, t, path)
+
+	case t.Kind() == reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			v.add(n, path, "expected list, got %s", kindName(n))
+			return
+		}
+		for i, item := range n.Content {
+			v.walk(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+
+	case t.Kind() == reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			v.add(n, path, "expected mapping, got %s", kindName(n))
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			v.walk(n.Content[i+1], t.Elem(), joinPath(path, n.Content[i].Value))
+		}
+
+	default:
+		if n.Kind != yaml.ScalarNode {
+			v.add(n, path, "expected %s, got %s", typeName(t), kindName(n))
+			return
+		}
+		if err := n.Decode(reflect.New(t).Interface()); err != nil {
+			v.add(n, path, "expected %s, got %q", typeName(t), n.Value)
+		}
+	}
+}
+
+// walkStruct проверяет поля mapping-узла по полям структуры
+func (v *validator) walkStruct(n *yaml.Node, t reflect.Type, path string) {
+	fields := yamlFields(t)
+	seen := make(map[string]bool, len(fields))
+	values := make(map[string]*yaml.Node, len(fields)) // значения заданных полей
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i], n.Content[i+1]
+
+		// Слияние через <<: *anchor — поля якоря проверяются как поля этой структуры
+		if key.Value == "<<" {
+			v.walk(val, t, path)
+			continue
+		}
+
+		f, ok := fieldByName(fields, key.Value)
+		if !ok {
+			msg := fmt.Sprintf("unknown field %q", key.Value)
+			if s := suggest(key.Value, fields); s != "" {
+				msg += fmt.Sprintf(" (did you mean %q?)", s)
+			}
+			v.add(key, path, "%s", msg)
+			continue
+		}
+		if seen[f.name] {
+			v.add(key, path, "duplicate field %q", key.Value)
+		}
+		seen[f.name] = true
+		values[f.name] = val
+
+		fieldPath := joinPath(path, f.name)
+		v.walk(val, f.Type, fieldPath)
+		v.checkField(val, f, fieldPath)
+	}
+
+	// Обязательные поля, которых нет в файле
+	for _, f := range fields {
+		if v.requireFields && f.Tag.Get("required") == "true" && !seen[f.name] {
+			v.add(n, path, "missing required field %q", f.name)
+		}
+	}
+
+	// Поля, обязательные при отсутствии других полей (response без experiment и actions)
+	for _, f := range fields {
+		others, ok := f.Tag.Lookup("required_unless")
+		if !ok || slices.ContainsFunc(strings.Split(others, ","), func(name string) bool { return !emptyNode(values[name]) }) {
+			continue
+		}
+		switch val := values[f.name]; {
+		case val == nil:
+			if v.requireFields {
+				v.add(n, path, "missing required field %q", f.name)
+			}
+		case emptyNode(val):
+			v.add(val, joinPath(path, f.name), "must not be empty")
+		}
+	}
+}
+
+// emptyNode сообщает, что значение поля не задано: узла нет, null, пустая строка, список или mapping
+func emptyNode(n *yaml.Node) bool {
+	if n == nil {
+		return true
+	}
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind == yaml.ScalarNode {
+		return n.Tag == "!!null" || n.Value == ""
+	}
+	return len(n.Content) == 0
+}
+
+// checkField проверяет ограничения из тегов поля для скалярного значения
+func (v *validator) checkField(n *yaml.Node, f yamlField, path string) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind != yaml.ScalarNode {
+		return
+	}
+	value := n.Value
+	if n.Tag == "!!null" {
+		value = ""
+	}
+
+	if value == "" {
+		if f.Tag.Get("required") == "true" {
+			v.add(n, path, "must not be empty")
+		}
+		return
+	}
+
+	if enum := f.Tag.Get("enum"); enum != "" {
+		allowed := strings.Split(enum, ",")
+		if !contains(allowed, value) {
+			v.add(n, path, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+		}
+	}
+
+	minTag, maxTag := f.Tag.Get("min"), f.Tag.Get("max")
+	if minTag != "" || maxTag != "" {
+		if num, err := strconv.ParseFloat(value, 64); err == nil {
+			lo, _ := strconv.ParseFloat(minTag, 64)
+			hi, _ := strconv.ParseFloat(maxTag, 64)
+			if (minTag != "" && num < lo) || (maxTag != "" && num > hi) {
+				v.add(n, path, "must be between %s and %s, got %s", minTag, maxTag, value)
+			}
+		}
+	}
+
+	switch f.Tag.Get("format") {
+	case "regex":
+		if _, err := regexp.Compile(value); err != nil {
+			v.add(n, path, "invalid regular expression: %v", err)
+		}
+	case "listen":
+		_, port, err := net.SplitHostPort(value)
+		if err != nil {
+			v.add(n, path, "invalid listen address %q, expected host:port", value)
+			break
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			v.add(n, path, "port must be between 1 and 65535, got %q", port)
+		}
+	case "url":
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.add(n, path, "invalid url %q, expected http(s)://host[:port]", value)
+		}
+	}
+}
+
+// yamlField — поле структуры с именем ключа YAML
+type yamlField struct {
+	reflect.StructField
+	name string
+}
+
+// yamlFields возвращает поля структуры, которые читаются из YAML.
+// Имя без тега yaml совпадает с именем поля в нижнем регистре, как в yaml.v3.
+func yamlFields(t reflect.Type) []yamlField {
+	var fields []yamlField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields = append(fields, yamlField{StructField: f, name: name})
+	}
+	return fields
+}
+
+// fieldByName ищет поле по ключу YAML
+func fieldByName(fields []yamlField, name string) (yamlField, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return yamlField{}, false
+}
+
+// suggest возвращает ближайшее по написанию имя поля, если опечатка небольшая
+func suggest(name string, fields []yamlField) string {
+	best, bestDist := "", 3
+	for _, f := range fields {
+		if d := editDistance(name, f.name); d < bestDist {
+			best, bestDist = f.name, d
+		}
+	}
+	return best
+}
+
+// editDistance — расстояние Левенштейна между строками
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// joinPath добавляет имя поля к пути
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// contains сообщает, есть ли значение в списке
+func contains(list []string, value string) bool {
+	for _, s := range list {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+// typeName — название типа для сообщений об ошибках
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// kindName — название вида узла YAML для сообщений об ошибках
+func kindName(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.SequenceNode:
+		return "list"
+	default:
+		return fmt.Sprintf("%q", n.Value)
+	}
+}
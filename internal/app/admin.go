@@ -0,0 +1,45 @@
+package app
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/st-kuptsov/balabol/config" // токен администратора из файла секретов
+	"go.uber.org/zap"                      // структурированное логирование
+)
+
+// requireAdmin пропускает к служебным обработчикам только запросы
+// с заголовком "Authorization: Bearer <admin_token>".
+// Токен читается из текущей конфигурации, поэтому его смена применяется без перезапуска.
+// Если admin_token не задан, служебные обработчики отключены.
+func requireAdmin(conf *config.CachedConfig, logger *zap.SugaredLogger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := conf.Current().AdminToken
+		if token == "" {
+			http.Error(w, "admin endpoints are disabled: admin_token is not set", http.StatusForbidden)
+			return
+		}
+
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			logger.Warnw("unauthorized admin request", "path", r.URL.Path, "remote", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logLevelHandler показывает (GET) и меняет (PUT) общий уровень логирования.
+// Формат запроса и ответа — как у zap.AtomicLevel: {"level":"debug"}.
+func logLevelHandler(level zap.AtomicLevel, logger *zap.SugaredLogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		before := level.Level()
+		level.ServeHTTP(w, r)
+		if after := level.Level(); after != before {
+			logger.Infow("log level changed", "from", before.String(), "to", after.String(), "remote", r.RemoteAddr)
+		}
+	})
+}
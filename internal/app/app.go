@@ -1,26 +1,34 @@
 package app
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"github.com/prometheus/client_golang/prometheus/promhttp" // обработчик метрик Prometheus
 	"github.com/st-kuptsov/balabol/config"                    // работа с конфигурацией
+	"github.com/st-kuptsov/balabol/internal/rulesource"       // удалённые источники правил
 	"github.com/st-kuptsov/balabol/internal/telegram"         // Telegram-бот
+	"github.com/st-kuptsov/balabol/pkg/audit"                 // журнал аудита ответов
+	"github.com/st-kuptsov/balabol/pkg/deadletter"            // журнал недоставленных сообщений
+	"github.com/st-kuptsov/balabol/pkg/experiments"           // результаты A/B-экспериментов
+	"github.com/st-kuptsov/balabol/pkg/lifecycle"             // запуск и остановка компонентов
 	logs "github.com/st-kuptsov/balabol/pkg/logs"             // кастомный логгер
 	"github.com/st-kuptsov/balabol/pkg/metrics"               // инициализация метрик
-	"go.uber.org/zap"                                         // структурированное логирование
+	"github.com/st-kuptsov/balabol/pkg/stats"                 // статистика чатов
+	"github.com/st-kuptsov/balabol/pkg/tracing"               // трассировка OpenTelemetry
+	"github.com/st-kuptsov/balabol/pkg/warnings"              // счётчики предупреждений модерации
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 )
 
 // Run запускает основную логику приложения.
+// configPath — путь к конфигурационному файлу,
 // version — версия приложения, передается для логирования.
-func Run(version string) error {
-	// Путь к конфигурационному файлу
-	configPath := "config/config.yaml"
+func Run(configPath, version string) error {
+	// Контекст приложения отменяется по SIGINT или SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Загружаем конфиг с контролем хеша (чтобы отслеживать изменения)
 	conf, err := config.LoadConfigWithHash(configPath)
@@ -28,8 +36,8 @@ func Run(version string) error {
 		return fmt.Errorf("loading config: %w", err)
 	}
 
-	// Создаём логгер согласно конфигурации
-	logger := logs.DefaultLogger(conf.Config.Logging)
+	// Создаём логгер согласно конфигурации (с возможностью пересборки при reload)
+	logger, logReloader := logs.ReloadableLogger(conf.Config.Logging)
 	logger.Infow("starting balabol",
 		"config", configPath,
 		"logLevel", conf.Config.Logging.Level,
@@ -37,75 +45,127 @@ func Run(version string) error {
 		"version", version,
 	)
 
-	// Инициализация метрик Prometheus
-	logger.Debug("initializing metrics server")
-	metrics.InitMetrics()                               // инициализация метрик приложения
-	startMetricsServer(conf.Config.ServicePort, logger) // запуск HTTP-сервера для метрик
-
-	// Инициализация Telegram-бота
-	logger.Debug("initializing telegram bot")
-	bot, err := telegram.NewBot(
-		conf.Config.Telegram.Token,
-		conf.Config.BotMode,
-		conf.Config.CleanFilter,
-		conf.Config.RemoveDup,
-		func() []config.Rule { return conf.Config.Rules }, // ленивый доступ к правилам
-		logger,
-	)
-	if err != nil {
-		return fmt.Errorf("telegram bot init: %w", err)
+	if conf.Config.DryRun {
+		logger.Warn("dry run: nothing will be sent to telegram")
 	}
-	logger.Info("telegram bot initialized")
-
-	// Запуск бота в отдельной горутине
-	go bot.Start()
 
-	// Канал для сигналов остановки приложения
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	// Правила, которые никогда не сработают после очистки текста
+	logLint(conf.Config, logger)
 
-	// Запуск горутины, которая периодически перезагружает конфиг при изменении
-	go reloadConfigLoop(configPath, conf, stop, logger)
+	// Трассировка OpenTelemetry (при выключенной — noop-провайдер)
+	tracer, err := tracing.NewProvider(ctx, conf.Config.Tracing, version)
+	if err != nil {
+		return fmt.Errorf("tracing init: %w", err)
+	}
 
-	// Основная блокировка: ждем сигнала остановки
-	<-stop
-	logger.Info("shutting down gracefully...")
-	bot.Stop() // остановка бота
-	return nil
-}
+	// Счётчики предупреждений переживают перезапуск; повреждённый файл — ошибка старта
+	warns, err := warnings.Open(conf.Config.Moderation.WarningsFile)
+	if err != nil {
+		return fmt.Errorf("moderation: %w", err)
+	}
+	chatStats, err := stats.Open(conf.Config.Stats.File)
+	if err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+	results, err := experiments.Open(conf.Config.Experiments.File)
+	if err != nil {
+		return fmt.Errorf("experiments: %w", err)
+	}
 
-// startMetricsServer запускает HTTP-сервер для Prometheus метрик
-func startMetricsServer(port int, logger *zap.SugaredLogger) {
-	go func() {
-		servicePort := fmt.Sprintf(":%d", port)
-		http.Handle("/metrics", promhttp.Handler()) // обработчик метрик
-		logger.Infow("metrics server started", "port", servicePort)
-		// Запуск HTTP сервера
-		if err := http.ListenAndServe(servicePort, nil); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			logger.Errorw("metrics server failed", "error", err)
-		}
-	}()
-}
+	// Инициализация метрик Prometheus
+	logger.Debug("initializing metrics server")
+	metrics.InitMetrics()                                           // инициализация метрик приложения
+	auditLog := audit.New(conf.Config.Audit)                        // журнал аудита ответов
+	deadLetters := deadletter.New(conf.Config.SendQueue.DeadLetter) // недоставленные сообщения
+	bots := newBotManager(conf, telegram.Deps{Audit: auditLog, Warnings: warns, Stats: chatStats, DeadLetters: deadLetters, Experiments: results}, logger)
+	status := newAppStatus(version, conf, bots)
+	tracked := &experimentsWorker{conf: conf, store: results, logger: logger}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler()) // обработчик метрик
+	status.Register(mux)                       // /healthz, /readyz, /status
+	mux.Handle("/experiments", tracked)        // итоги A/B-экспериментов
+	mux.Handle("/loglevel", requireAdmin(conf, logger, logLevelHandler(logReloader.Level(), logger)))
+	server := newHTTPServer(mux, logger)
+	sources := rulesource.NewManager(conf, nil, logger) // опрос удалённых источников правил (rule_sources)
+	collector := &statsWorker{conf: conf, bots: bots, store: chatStats, logger: logger}
 
-// reloadConfigLoop периодически (каждые 5 секунд) проверяет изменения конфигурации.
-// Если конфиг изменился, логгирует обновление и применяет новые правила.
-func reloadConfigLoop(path string, conf *config.CachedConfig, stop chan os.Signal, logger *zap.SugaredLogger) {
-	ticker := time.NewTicker(5 * time.Second) // тикер с интервалом 5 секунд
-	defer ticker.Stop()
+	// Периодическая перезагрузка конфига при изменении
+	r := &reloader{
+		path:    configPath,
+		conf:    conf,
+		bots:    bots,
+		sources: sources,
+		server:  server,
+		status:  status,
+		tracer:  tracer,
+		audit:   auditLog,
+		dead:    deadLetters,
+		warns:   warns,
+		stats:   chatStats,
+		results: results,
+		logs:    logReloader,
+		logger:  logger,
+	}
 
-	for {
-		select {
-		case <-stop: // сигнал на завершение
-			return
-		case <-ticker.C: // тикер срабатывает
-			changed, err := conf.ReloadWithMetrics(path) // проверка и перезагрузка конфига
-			if err != nil {
-				logger.Errorw("config reload failed", "error", err)
-				continue
-			}
-			if changed {
-				logger.Infow("config reloaded", "mode", conf.Config.BotMode, "rules_count", len(conf.Config.Rules))
+	// Компоненты запускаются по порядку и останавливаются в обратном:
+	// сначала перестают приходить изменения конфигурации и сообщения, затем
+	// сохраняется статистика, закрываются HTTP-сервер, журналы и трассировка.
+	sup := lifecycle.New(logger)
+	sup.Add(lifecycle.Component{
+		Name: "tracing",
+		Stop: tracer.Shutdown, // отправляем накопленные спаны перед выходом
+	})
+	sup.Add(lifecycle.Component{
+		Name: "audit",
+		Stop: func(context.Context) error { return auditLog.Close() },
+	})
+	sup.Add(lifecycle.Component{
+		Name: "dead_letters",
+		Stop: func(context.Context) error { return deadLetters.Close() }, // после ботов: туда пишется недоставленное при остановке
+	})
+	sup.Add(lifecycle.Component{
+		Name:  "http_server",
+		Start: func(context.Context) error { return server.Start(conf.Config.ServicePort) },
+		Run:   server.Run,
+		Stop:  server.Stop,
+	})
+	sup.Add(lifecycle.Component{
+		Name: "stats",
+		Run:  collector.Run,
+		Stop: collector.Stop, // последнее сохранение — после остановки ботов
+	})
+	sup.Add(lifecycle.Component{
+		Name: "experiments",
+		Run:  tracked.Run,
+		Stop: tracked.Stop, // после ботов: учтены их последние ответы
+	})
+	sup.Add(lifecycle.Component{
+		Name: "bots",
+		Start: func(ctx context.Context) error {
+			if _, err := bots.Sync(); err != nil {
+				// Успевшие запуститься боты останавливаем здесь: компонент не считается запущенным
+				_ = bots.StopAll(ctx)
+				return fmt.Errorf("telegram bot init: %w", err)
 			}
-		}
-	}
+			logger.Infow("telegram bots initialized", "count", len(conf.Config.Bots))
+			return nil
+		},
+		Stop:         bots.StopAll, // дожидается начатых обработчиков и очереди отправки
+		StartTimeout: botStartTimeout,
+		StopTimeout:  botStopTimeout,
+	})
+	sup.Add(lifecycle.Component{
+		Name:  "rule_sources",
+		Start: func(context.Context) error { sources.Sync(); return nil },
+		Stop:  func(context.Context) error { sources.StopAll(); return nil },
+	})
+	sup.Add(lifecycle.Component{
+		Name: "reloader",
+		Run:  r.Run,
+	})
+
+	// Работаем до SIGINT/SIGTERM или фатальной ошибки компонента
+	err = sup.Run(ctx)
+	_ = logger.Sync()
+	return err
 }
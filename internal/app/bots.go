@@ -0,0 +1,252 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config"            // настройки ботов
+	"github.com/st-kuptsov/balabol/internal/telegram" // Telegram-бот
+	"github.com/st-kuptsov/balabol/pkg/metrics"       // метрики Prometheus
+	"go.uber.org/zap"                                 // структурированное логирование
+)
+
+// Таймауты запуска и остановки ботов
+const (
+	botStartTimeout = 30 * time.Second // запуск всех ботов (getMe, регистрация webhook)
+	botStopTimeout  = 10 * time.Second // остановка бота с ожиданием начатых обработчиков и очереди отправки
+)
+
+// runningBot — запущенный бот и настройки Telegram, с которыми он создан
+type runningBot struct {
+	bot      *telegram.Bot
+	telegram config.TelegramConfig
+}
+
+// botStatus — состояние одного бота для /readyz и /status
+type botStatus struct {
+	Name     string    `json:"name"`
+	Username string    `json:"username,omitempty"`
+	Poller   string    `json:"poller"`
+	Mode     string    `json:"mode"`
+	Rules    int       `json:"rules_count"`
+	Running  bool      `json:"running"`
+	Ready    bool      `json:"ready"`
+	Error    string    `json:"error,omitempty"`
+	LastPoll time.Time `json:"last_poll"`
+}
+
+// botManager запускает и останавливает ботов из секции bots конфигурации.
+// Боты работают независимо: добавление, удаление или перезапуск одного не затрагивает остальных.
+type botManager struct {
+	syncMu sync.Mutex // не даёт Sync и StopAll выполняться одновременно
+	mu     sync.Mutex // защищает bots; не удерживается во время getMe и остановки ботов
+	conf   *config.CachedConfig
+	deps   telegram.Deps // общие компоненты, передаваемые каждому боту
+	logger *zap.SugaredLogger
+	bots   map[string]*runningBot // запущенные боты по имени
+}
+
+// newBotManager создаёт менеджер ботов поверх общей конфигурации
+func newBotManager(conf *config.CachedConfig, deps telegram.Deps, logger *zap.SugaredLogger) *botManager {
+	return &botManager{
+		conf:   conf,
+		deps:   deps,
+		logger: logger,
+		bots:   make(map[string]*runningBot),
+	}
+}
+
+// Sync приводит набор запущенных ботов в соответствие с конфигурацией:
+// запускает новых ботов, останавливает удалённых и перезапускает тех,
+// у кого изменились токен или настройки получения обновлений.
+// Правила, режим и очистка текста применяются без перезапуска.
+// Возвращает список выполненных действий вида "bot:<имя>:<действие>".
+// Ошибка запуска одного бота не мешает запуску остальных.
+func (m *botManager) Sync() ([]string, error) {
+	m.syncMu.Lock()
+	defer m.syncMu.Unlock()
+
+	cfg := m.conf.Current()
+	wanted := make(map[string]bool, len(cfg.Bots))
+	var actions []string
+	var errs []error
+	for _, botConf := range cfg.Bots {
+		wanted[botConf.Name] = true
+
+		action := "started"
+		running := m.running(botConf.Name)
+		if running != nil {
+			if running.telegram == botConf.Telegram {
+				continue
+			}
+			action = "restarted"
+		}
+
+		// Токен или поллер изменились — новый экземпляр создаётся (getMe) до остановки старого:
+		// при ошибке, например опечатке в токене, продолжает работать прежний бот
+		bot, err := m.create(botConf)
+		if err != nil {
+			if running != nil {
+				m.logger.Warnw("telegram bot restart failed, keeping the running instance", "bot", botConf.Name, "error", err)
+			}
+			errs = append(errs, fmt.Errorf("bot %q: %w", botConf.Name, err))
+			continue
+		}
+		if running != nil {
+			m.stop(botConf.Name)
+		}
+		m.run(botConf, bot)
+		actions = append(actions, "bot:"+botConf.Name+":"+action)
+	}
+
+	for _, name := range m.names() {
+		if !wanted[name] {
+			m.stop(name)
+			metrics.DeleteBot(name)
+			actions = append(actions, "bot:"+name+":stopped")
+		}
+	}
+
+	return actions, errors.Join(errs...)
+}
+
+// StopAll останавливает всех запущенных ботов, дожидаясь начатых обработчиков.
+// Боты останавливаются параллельно; ctx ограничивает общее время остановки.
+func (m *botManager) StopAll(ctx context.Context) error {
+	m.syncMu.Lock()
+	defer m.syncMu.Unlock()
+
+	// Боты убираются из списка сразу, а останавливаются без m.mu, чтобы /readyz и /status не ждали остановки
+	m.mu.Lock()
+	bots := m.bots
+	m.bots = make(map[string]*runningBot)
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 0, len(bots))
+	var errsMu sync.Mutex
+	for name, running := range bots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := running.bot.Shutdown(ctx); err != nil {
+				errsMu.Lock()
+				errs = append(errs, fmt.Errorf("bot %q: %w", name, err))
+				errsMu.Unlock()
+				return
+			}
+			m.logger.Infow("telegram bot stopped", "bot", name)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// create создаёт бота (getMe) без запуска получения обновлений. Вызывается под m.syncMu без m.mu.
+// Webhook-сервер поднимается только в run, поэтому новый экземпляр можно создать, пока работает старый.
+func (m *botManager) create(botConf config.BotConfig) (*telegram.Bot, error) {
+	name := botConf.Name
+	logger := m.logger.With("bot", name)
+
+	logger.Debug("initializing telegram bot")
+	return telegram.NewBot(
+		botConf,
+		func() *config.BotConfig { return m.conf.Current().Bot(name) }, // актуальные настройки бота
+		m.deps,
+		logger,
+	)
+}
+
+// run добавляет созданного бота в список и запускает получение обновлений. Вызывается под m.syncMu.
+func (m *botManager) run(botConf config.BotConfig, bot *telegram.Bot) {
+	m.mu.Lock()
+	m.bots[botConf.Name] = &runningBot{bot: bot, telegram: botConf.Telegram}
+	m.mu.Unlock()
+	go bot.Start()
+	m.logger.Infow("telegram bot started", "bot", botConf.Name, "poller", botConf.Telegram.Poller, "username", bot.Me.Username)
+}
+
+// stop останавливает бота, дожидаясь начатых обработчиков не дольше botStopTimeout.
+// Вызывается под m.syncMu. Бот сразу убирается из списка, а останавливается без m.mu.
+func (m *botManager) stop(name string) {
+	m.mu.Lock()
+	running := m.bots[name]
+	delete(m.bots, name)
+	m.mu.Unlock()
+	if running == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), botStopTimeout)
+	defer cancel()
+	if err := running.bot.Shutdown(ctx); err != nil {
+		m.logger.Warnw("telegram bot stop timed out", "bot", name, "error", err)
+	}
+	m.logger.Infow("telegram bot stopped", "bot", name)
+}
+
+// running возвращает запущенного бота с его настройками Telegram или nil
+func (m *botManager) running(name string) *runningBot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bots[name]
+}
+
+// names возвращает имена запущенных ботов
+func (m *botManager) names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Collect(maps.Keys(m.bots))
+}
+
+// Bot возвращает запущенного бота по имени или nil
+func (m *botManager) Bot(name string) *telegram.Bot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if running, ok := m.bots[name]; ok {
+		return running.bot
+	}
+	return nil
+}
+
+// Status возвращает состояние всех ботов из текущей конфигурации.
+// Бот, который есть в конфигурации, но не запущен, считается не готовым.
+func (m *botManager) Status() []botStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cfg := m.conf.Current()
+	statuses := make([]botStatus, 0, len(cfg.Bots))
+	for _, botConf := range cfg.Bots {
+		st := botStatus{
+			Name:   botConf.Name,
+			Poller: botConf.Telegram.Poller,
+			Mode:   botConf.BotMode,
+			Rules:  len(botConf.Rules),
+		}
+
+		running, ok := m.bots[botConf.Name]
+		if !ok {
+			st.Error = "bot is not running"
+			statuses = append(statuses, st)
+			continue
+		}
+
+		st.Running = true
+		st.Username = running.bot.Me.Username
+		st.LastPoll = running.bot.LastPoll()
+		if err := running.bot.Ready(); err != nil {
+			st.Error = err.Error()
+		} else {
+			st.Ready = true
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
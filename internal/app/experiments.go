@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config"          // окно исхода экспериментов
+	"github.com/st-kuptsov/balabol/pkg/experiments" // результаты экспериментов
+	"go.uber.org/zap"                               // структурированное логирование
+)
+
+// experimentsWorker периодически сохраняет результаты экспериментов
+// и отдаёт их по HTTP (/experiments)
+type experimentsWorker struct {
+	conf   *config.CachedConfig // текущая конфигурация (секция experiments)
+	store  *experiments.Store   // результаты экспериментов
+	logger *zap.SugaredLogger
+}
+
+// Run раз в statsInterval удаляет ответы с истёкшим окном исхода и сохраняет результаты до отмены ctx.
+// Последнее сохранение выполняет Stop — после остановки ботов.
+func (w *experimentsWorker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			w.store.Prune(now, w.conf.Current().Experiments.Window)
+			if err := w.store.Flush(); err != nil {
+				w.logger.Errorw("experiments save failed", "error", err)
+			}
+		}
+	}
+}
+
+// Stop записывает накопленные результаты в файл
+func (w *experimentsWorker) Stop(context.Context) error {
+	return w.store.Flush()
+}
+
+// ServeHTTP возвращает итоги экспериментов в JSON; параметр bot оставляет эксперименты одного бота
+func (w *experimentsWorker) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	report := w.store.Report(r.URL.Query().Get("bot"), time.Now(), w.conf.Current().Experiments.Window)
+	if report == nil {
+		report = []experiments.Result{}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(rw)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+}
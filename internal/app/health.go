@@ -0,0 +1,145 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config" // работа с конфигурацией
+)
+
+// appStatus собирает состояние приложения для /healthz, /readyz и /status
+type appStatus struct {
+	version string               // версия приложения, переданная в Run
+	started time.Time            // время запуска процесса
+	conf    *config.CachedConfig // текущая конфигурация
+	bots    *botManager          // запущенные боты
+
+	mu          sync.Mutex
+	lastReload  time.Time // время последнего reload (успешного или нет)
+	reloadError string    // ошибка последнего reload, пусто при успехе
+}
+
+// newAppStatus создаёт состояние приложения с текущим временем запуска
+func newAppStatus(version string, conf *config.CachedConfig, bots *botManager) *appStatus {
+	return &appStatus{
+		version: version,
+		started: time.Now(),
+		conf:    conf,
+		bots:    bots,
+	}
+}
+
+// RecordReload запоминает время и результат reload конфигурации
+func (s *appStatus) RecordReload(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastReload = time.Now()
+	s.reloadError = ""
+	if err != nil {
+		s.reloadError = err.Error()
+	}
+}
+
+// Register добавляет служебные обработчики в mux
+func (s *appStatus) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/status", s.handleStatus)
+}
+
+// handleHealthz отвечает 200, пока процесс жив и обслуживает HTTP
+func (s *appStatus) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// handleReadyz отвечает 200, если конфиг загружен и все боты получают обновления,
+// иначе 503 с перечнем причин
+func (s *appStatus) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	if err := s.ready(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprintf(w, "not ready: %v\n", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// ready проверяет готовность приложения
+func (s *appStatus) ready() error {
+	if s.conf.Current() == nil {
+		return errors.New("config is not loaded")
+	}
+
+	var errs []error
+	for _, st := range s.bots.Status() {
+		if !st.Ready {
+			errs = append(errs, fmt.Errorf("bot %q: %s", st.Name, st.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// statusResponse — тело ответа /status
+type statusResponse struct {
+	Version    string       `json:"version"`
+	Uptime     string       `json:"uptime"`
+	StartedAt  time.Time    `json:"started_at"`
+	ConfigHash string       `json:"config_hash"`
+	LastReload reloadStatus `json:"last_reload"`
+	BotMode    string       `json:"bot_mode"`
+	DryRun     bool         `json:"dry_run"`
+	RulesCount int          `json:"rules_count"`
+	Ready      bool         `json:"ready"`
+	Bots       []botStatus  `json:"bots"`
+}
+
+// reloadStatus — результат последнего reload конфигурации
+type reloadStatus struct {
+	Time   *time.Time `json:"time"`            // nil, если reload ещё не было
+	Result string     `json:"result"`          // "ok", "error" или "none"
+	Error  string     `json:"error,omitempty"` // текст ошибки
+}
+
+// handleStatus возвращает состояние приложения в JSON
+func (s *appStatus) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	cfg := s.conf.Current()
+
+	resp := statusResponse{
+		Version:    s.version,
+		Uptime:     time.Since(s.started).Round(time.Second).String(),
+		StartedAt:  s.started,
+		ConfigHash: s.conf.Hash(),
+		BotMode:    cfg.BotMode,
+		DryRun:     cfg.DryRun,
+		RulesCount: len(cfg.Rules),
+		Ready:      true,
+		Bots:       s.bots.Status(),
+	}
+	for _, st := range resp.Bots {
+		resp.Ready = resp.Ready && st.Ready
+	}
+
+	s.mu.Lock()
+	resp.LastReload = reloadStatus{Result: "none"}
+	if !s.lastReload.IsZero() {
+		t := s.lastReload
+		resp.LastReload.Time = &t
+		resp.LastReload.Result = "ok"
+		if s.reloadError != "" {
+			resp.LastReload.Result = "error"
+			resp.LastReload.Error = s.reloadError
+		}
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(resp)
+}
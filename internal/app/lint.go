@@ -0,0 +1,27 @@
+package app
+
+import (
+	"github.com/st-kuptsov/balabol/config"   // линтер правил
+	"github.com/st-kuptsov/balabol/pkg/i18n" // системные сообщения
+	"go.uber.org/zap"                        // структурированное логирование
+)
+
+// logLint выводит в лог замечания линтера правил и неизвестные ключи i18n.messages.
+// Замечания не мешают запуску: правило, которое никогда не сработает, — ошибка
+// конфигурации, но не повод останавливать остальных ботов.
+func logLint(cfg *config.Config, logger *zap.SugaredLogger) {
+	for _, issue := range cfg.Lint() {
+		logger.Warnw("rule lint",
+			"bot", issue.Bot,
+			"rule", issue.Rule,
+			"text", issue.Text,
+			"pattern", issue.Pattern,
+			"source", issue.Source,
+			"severity", issue.Severity,
+			"problem", issue.Message,
+		)
+	}
+	for _, key := range i18n.UnknownMessages(cfg.I18n) {
+		logger.Warnw("unknown i18n message", "message", key)
+	}
+}
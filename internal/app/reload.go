@@ -0,0 +1,182 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config"              // работа с конфигурацией
+	"github.com/st-kuptsov/balabol/internal/rulesource" // удалённые источники правил
+	"github.com/st-kuptsov/balabol/pkg/audit"           // журнал аудита ответов
+	"github.com/st-kuptsov/balabol/pkg/deadletter"      // журнал недоставленных сообщений
+	"github.com/st-kuptsov/balabol/pkg/experiments"     // результаты экспериментов
+	logs "github.com/st-kuptsov/balabol/pkg/logs"       // кастомный логгер
+	"github.com/st-kuptsov/balabol/pkg/stats"           // статистика чатов
+	"github.com/st-kuptsov/balabol/pkg/tracing"         // трассировка OpenTelemetry
+	"github.com/st-kuptsov/balabol/pkg/warnings"        // счётчики предупреждений модерации
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap" // структурированное логирование
+)
+
+// reloader периодически проверяет конфигурацию и применяет изменения
+// к работающим компонентам: логгеру, HTTP-серверу и ботам.
+type reloader struct {
+	path    string               // путь к основному конфигу
+	conf    *config.CachedConfig // текущая конфигурация
+	bots    *botManager          // запущенные боты
+	sources *rulesource.Manager  // удалённые источники правил
+	server  *httpServer          // HTTP-сервер метрик
+	status  *appStatus           // состояние приложения для /status
+	tracer  *tracing.Provider    // провайдер трассировки
+	audit   *audit.Log           // журнал аудита ответов
+	dead    *deadletter.Log      // журнал недоставленных сообщений
+	warns   *warnings.Store      // счётчики предупреждений модерации
+	stats   *stats.Store         // статистика чатов
+	results *experiments.Store   // результаты экспериментов
+	logs    *logs.Reloader       // пересборка логгера
+	logger  *zap.SugaredLogger
+}
+
+// Run периодически (каждые 5 секунд) проверяет изменения конфигурации до отмены ctx.
+// Если конфиг изменился, применяет его и логгирует, какие компоненты были перезапущены.
+func (r *reloader) Run(ctx context.Context) error {
+	ticker := time.NewTicker(5 * time.Second) // тикер с интервалом 5 секунд
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done(): // остановка приложения
+			return nil
+		case <-ticker.C: // тикер срабатывает
+			r.reload()
+		}
+	}
+}
+
+// reload выполняет одну проверку конфигурации.
+// Спан config.reload создаётся только если конфиг изменился или произошла ошибка,
+// чтобы не засорять трассы проверками без изменений.
+func (r *reloader) reload() {
+	start := time.Now()
+	old := r.conf.Current()
+	changed, err := r.conf.ReloadWithMetrics(r.path) // проверка и перезагрузка конфига
+	if err == nil && !changed {
+		return
+	}
+
+	_, span := tracing.Tracer().Start(context.Background(), "config.reload", trace.WithTimestamp(start))
+	defer span.End()
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		r.status.RecordReload(err)
+		r.logger.Errorw("config reload failed", "error", err)
+		return
+	}
+
+	cur := r.conf.Current()
+	logLint(cur, r.logger)
+	restarted, err := r.apply(old, cur)
+	span.SetAttributes(attribute.StringSlice("restarted", restarted))
+	r.status.RecordReload(err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		r.logger.Errorw("config applied with errors", "restarted", restarted, "error", err)
+		return
+	}
+	r.logger.Infow("config reloaded",
+		"restarted", restarted,
+		"bots", len(cur.Bots),
+		"rules_count", len(cur.Rules),
+	)
+}
+
+// apply применяет новую конфигурацию к компонентам, которые читают настройки
+// только при старте. Правила, режим и очистка текста читаются ботами на лету.
+// Возвращает список перезапущенных компонентов.
+func (r *reloader) apply(old, cur *config.Config) ([]string, error) {
+	restarted := []string{}
+	var errs []error
+
+	// Логгер пересобирается первым, чтобы остальные сообщения ушли в новые выходы
+	// Недоступный syslog не мешает применению остальных настроек
+	reloaded, err := r.logs.Reload(cur.Logging)
+	if err != nil {
+		r.logger.Warnw("log sink unavailable", "error", err)
+	}
+	if reloaded {
+		restarted = append(restarted, "logger")
+	}
+
+	// Журнал аудита переоткрывается при смене файла или ротации
+	if r.audit.Reload(cur.Audit) {
+		restarted = append(restarted, "audit")
+	}
+	if r.dead.Reload(cur.SendQueue.DeadLetter) {
+		restarted = append(restarted, "dead_letters")
+	}
+
+	// Файл предупреждений перечитывается при смене moderation.warnings_file
+	reopened, err := r.warns.Reload(cur.Moderation.WarningsFile)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if reopened {
+		restarted = append(restarted, "warnings")
+	}
+
+	// Файл статистики перечитывается при смене stats.file
+	reopened, err = r.stats.Reload(cur.Stats.File)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if reopened {
+		restarted = append(restarted, "stats")
+	}
+
+	// Файл результатов экспериментов перечитывается при смене experiments.file
+	reopened, err = r.results.Reload(cur.Experiments.File)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if reopened {
+		restarted = append(restarted, "experiments")
+	}
+
+	// Новые настройки трассировки требуют пересоздания экспортёра
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reapplied, err := r.tracer.Apply(ctx, cur.Tracing)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if reapplied {
+		restarted = append(restarted, "tracing")
+	}
+
+	// Смена порта требует перезапуска HTTP-сервера. Rebind сравнивает с портом, который сервер
+	// действительно слушает, поэтому порт, не открывшийся в прошлый раз, пробуется снова
+	rebound, err := r.server.Rebind(cur.ServicePort)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if rebound {
+		restarted = append(restarted, "http_server")
+	}
+
+	// Боты с новым токеном или поллером пересоздаются, новые запускаются, удалённые останавливаются
+	actions, err := r.bots.Sync()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	restarted = append(restarted, actions...)
+
+	// Источники правил с изменёнными настройками перезапускаются
+	restarted = append(restarted, r.sources.Sync()...)
+
+	return restarted, errors.Join(errs...)
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap" // структурированное логирование
+)
+
+// httpStopTimeout — сколько ждать завершения текущих запросов при смене порта
+const httpStopTimeout = 5 * time.Second
+
+// httpServer — служебный HTTP-сервер приложения (метрики Prometheus и др.).
+// Умеет перезапускаться на другом порту без пересоздания обработчиков.
+type httpServer struct {
+	mu      sync.Mutex
+	handler http.Handler
+	logger  *zap.SugaredLogger
+	srv     *http.Server // текущий сервер, nil если остановлен
+	port    int          // порт текущего сервера
+	failed  chan error   // ошибка обслуживания запросов (не считая штатной остановки)
+}
+
+// newHTTPServer создаёт остановленный сервер с заданными обработчиками
+func newHTTPServer(handler http.Handler, logger *zap.SugaredLogger) *httpServer {
+	return &httpServer{handler: handler, logger: logger, failed: make(chan error, 1)}
+}
+
+// Start открывает порт и запускает обслуживание запросов в отдельной горутине.
+// Ошибка занятого порта возвращается сразу, а не теряется в горутине.
+func (s *httpServer) Start(port int) error {
+	ln, err := listen(port)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.serve(ln, port)
+	return nil
+}
+
+// Rebind перезапускает сервер на новом порту, если порт изменился.
+// Новый порт открывается до остановки старого сервера: если он занят,
+// старый сервер продолжает работать, а Rebind возвращает ошибку.
+// Возвращает true, если сервер был перезапущен.
+func (s *httpServer) Rebind(port int) (bool, error) {
+	s.mu.Lock()
+	current := s.port
+	s.mu.Unlock()
+
+	if port == current {
+		return false, nil
+	}
+	ln, err := listen(port)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	old := s.srv
+	s.serve(ln, port)
+	s.mu.Unlock()
+
+	if old != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), httpStopTimeout)
+		defer cancel()
+		if err := old.Shutdown(ctx); err != nil {
+			s.logger.Warnw("http server shutdown failed", "port", current, "error", err)
+		}
+		s.logger.Infow("http server stopped", "port", current)
+	}
+	return true, nil
+}
+
+// listen открывает TCP-порт для HTTP-сервера
+func listen(port int) (net.Listener, error) {
+	addr := fmt.Sprintf(":%d", port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s: %w", addr, err)
+	}
+	return ln, nil
+}
+
+// serve запускает новый сервер на открытом порту ln и делает его текущим.
+// Вызывается под s.mu.
+func (s *httpServer) serve(ln net.Listener, port int) {
+	srv := &http.Server{Handler: s.handler, ReadHeaderTimeout: 10 * time.Second}
+	s.srv = srv
+	s.port = port
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Errorw("http server failed", "error", err)
+			select {
+			case s.failed <- err:
+			default:
+			}
+		}
+	}()
+	s.logger.Infow("http server started", "port", fmt.Sprintf(":%d", port))
+}
+
+// Run ждёт отмены ctx или ошибки обслуживания запросов.
+// Ошибка фатальна для приложения: без сервера недоступны метрики и проверки состояния.
+func (s *httpServer) Run(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-s.failed:
+		return fmt.Errorf("http server: %w", err)
+	}
+}
+
+// Stop останавливает сервер, дожидаясь завершения текущих запросов до отмены ctx
+func (s *httpServer) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.srv == nil {
+		return nil
+	}
+	err := s.srv.Shutdown(ctx)
+	s.srv = nil
+	s.port = 0
+	s.logger.Info("http server stopped")
+	return err
+}
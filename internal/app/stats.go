@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config"      // настройки сводки
+	"github.com/st-kuptsov/balabol/pkg/metrics" // метрики Prometheus
+	"github.com/st-kuptsov/balabol/pkg/stats"   // статистика чатов
+	"go.uber.org/zap"                           // структурированное логирование
+)
+
+// statsInterval — как часто статистика сохраняется в файл и проверяется время сводки
+const statsInterval = time.Minute
+
+// statsWorker периодически сохраняет статистику чатов и отправляет сводку в чат администраторов
+type statsWorker struct {
+	conf   *config.CachedConfig // текущая конфигурация (секция stats.digest)
+	bots   *botManager          // запущенные боты; сводку отправляет бот stats.digest.bot
+	store  *stats.Store         // статистика чатов
+	logger *zap.SugaredLogger
+}
+
+// Run сохраняет статистику и проверяет время сводки раз в statsInterval до отмены ctx.
+// Последнее сохранение выполняет Stop — после остановки ботов, чтобы учесть их последние сообщения.
+func (w *statsWorker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			w.digest(now)
+			w.flush()
+		}
+	}
+}
+
+// Stop записывает накопленную статистику в файл
+func (w *statsWorker) Stop(context.Context) error {
+	return w.store.Flush()
+}
+
+// flush записывает накопленную статистику в файл
+func (w *statsWorker) flush() {
+	if err := w.store.Flush(); err != nil {
+		w.logger.Errorw("stats save failed", "error", err)
+	}
+}
+
+// digest отправляет сводку, если с предыдущей прошло stats.digest.interval.
+// Первая сводка отправляется через интервал после включения, а не сразу при старте.
+func (w *statsWorker) digest(now time.Time) {
+	cfg := w.conf.Current()
+	conf := cfg.Stats.Digest
+	if !conf.Enabled {
+		return
+	}
+
+	last := w.store.LastDigest(conf.Bot)
+	if last.IsZero() {
+		w.store.SetLastDigest(conf.Bot, now)
+		return
+	}
+	if now.Sub(last) < conf.Interval {
+		return
+	}
+
+	bot := w.bots.Bot(conf.Bot)
+	settings := cfg.Bot(conf.Bot)
+	if bot == nil || settings == nil {
+		w.logger.Warnw("stats digest skipped: bot is not running", "bot", conf.Bot)
+		return
+	}
+	chats, err := bot.SendDigest(settings, w.store, last, now)
+	if err != nil {
+		// Время сводки не сдвигается: попробуем снова на следующем тике
+		metrics.ErrorsTotal.WithLabelValues(conf.Bot, "digest").Inc()
+		w.logger.Errorw("stats digest failed", "bot", conf.Bot, "error", err)
+		return
+	}
+	w.store.SetLastDigest(conf.Bot, now)
+	w.logger.Infow("stats digest sent", "bot", conf.Bot, "chats", chats)
+}
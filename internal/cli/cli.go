@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Коды завершения, на которые могут опираться скрипты и CI
+const (
+	ExitOK            = 0 // команда выполнена успешно
+	ExitError         = 1 // ошибка во время работы
+	ExitUsage         = 2 // неверные аргументы командной строки
+	ExitInvalidConfig = 3 // конфигурация не прошла проверку
+)
+
+// DefaultConfigPath — путь к конфигурации, если не задан ни флаг, ни переменная окружения
+const DefaultConfigPath = "config/config.yaml"
+
+// ConfigEnv — переменная окружения с путём к конфигурации
+const ConfigEnv = "BALABOL_CONFIG"
+
+// usage — справка по командам
+const usage = `Usage: balabol [--config path] <command> [flags]
+
+Commands:
+  run            run the bots (default)
+  validate       check the configuration and exit
+  version        print version and build info
+  print-config   print the effective configuration with secrets masked
+  rules list     list the rules of every bot
+  rules export   export rules as csv, json or yaml
+  rules import   check rules from csv, json or yaml and print a rules file
+  lint           find rules that can never match after text cleaning
+  experiments    show A/B experiment results (--format json for scripts)
+  replay         run a Telegram Desktop chat export (result.json) through the rules
+  schema         print the JSON Schema of config.yaml (--rules: of a rules file)
+
+Config path: --config flag, then $BALABOL_CONFIG, then config/config.yaml.
+
+Exit codes: 0 ok, 1 runtime error, 2 usage error, 3 invalid configuration
+(for lint: rule errors, or any finding with --strict).
+`
+
+// env — окружение выполнения команды
+type env struct {
+	version    string
+	configPath string
+	stdout     io.Writer
+	stderr     io.Writer
+}
+
+// command — подкоманда CLI
+type command struct {
+	name string
+	run  func(e *env, args []string) int
+}
+
+// commands — доступные подкоманды
+var commands = []command{
+	{name: "run", run: runCmd},
+	{name: "validate", run: validateCmd},
+	{name: "version", run: versionCmd},
+	{name: "print-config", run: printConfigCmd},
+	{name: "rules", run: rulesCmd},
+	{name: "lint", run: lintCmd},
+	{name: "experiments", run: experimentsCmd},
+	{name: "replay", run: replayCmd},
+	{name: "schema", run: schemaCmd},
+}
+
+// Main разбирает аргументы командной строки и выполняет подкоманду.
+//
+// Параметры:
+// - args: аргументы без имени программы
+// - version: версия приложения (подставляется через ldflags)
+// - stdout, stderr: потоки вывода
+//
+// Возвращает код завершения процесса.
+func Main(args []string, version string, stdout, stderr io.Writer) int {
+	e := &env{version: version, stdout: stdout, stderr: stderr}
+
+	// Глобальные флаги до имени подкоманды
+	fs := e.flagSet("balabol")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	args = fs.Args()
+
+	// Без подкоманды — запуск ботов
+	name := "run"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd.run(e, args)
+		}
+	}
+
+	fmt.Fprintf(stderr, "unknown command %q\n\n%s", name, usage)
+	return ExitUsage
+}
+
+// flagSet создаёт набор флагов с общим флагом --config.
+// Флаг принимается и до, и после имени подкоманды.
+func (e *env) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() { fmt.Fprint(e.stderr, usage) }
+	fs.Func("config", "path to config.yaml (default $"+ConfigEnv+" or "+DefaultConfigPath+")", func(path string) error {
+		e.configPath = path
+		return nil
+	})
+	return fs
+}
+
+// parseInterspersed разбирает флаги, стоящие и до, и после позиционных аргументов
+// (пакет flag останавливается на первом аргументе без "-").
+// Возвращает позиционные аргументы по порядку.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// config возвращает путь к конфигурации: флаг, переменная окружения или путь по умолчанию
+func (e *env) config() string {
+	if e.configPath != "" {
+		return e.configPath
+	}
+	if path := os.Getenv(ConfigEnv); path != "" {
+		return path
+	}
+	return DefaultConfigPath
+}
+
+// usageExit возвращает код завершения для ошибки разбора флагов
+func usageExit(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	return ExitUsage
+}
@@ -0,0 +1,478 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config"          // загрузка и проверка конфигурации
+	"github.com/st-kuptsov/balabol/internal/app"    // запуск ботов
+	"github.com/st-kuptsov/balabol/pkg/experiments" // результаты экспериментов
+	"gopkg.in/yaml.v3"                              // вывод итоговой конфигурации
+)
+
+// secretMask заменяет значения секретов в выводе print-config
+const secretMask = "********"
+
+// runCmd запускает ботов и работает до сигнала остановки
+func runCmd(e *env, args []string) int {
+	fs := e.flagSet("run")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+
+	if err := app.Run(e.config(), e.version); err != nil {
+		fmt.Fprintf(e.stderr, "application failed: %v\n", err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+// validateCmd загружает конфигурацию так же, как при запуске, и сообщает о результате
+func validateCmd(e *env, args []string) int {
+	fs := e.flagSet("validate")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+
+	cfg, err := config.GetConfig(e.config())
+	if err != nil {
+		return e.invalidConfig(err)
+	}
+
+	rules := 0
+	for _, b := range cfg.Bots {
+		rules += len(b.Rules)
+	}
+	fmt.Fprintf(e.stdout, "config %s is valid: %d bot(s), %d rule(s)\n", e.config(), len(cfg.Bots), rules)
+	return ExitOK
+}
+
+// versionCmd выводит версию и сведения о сборке
+func versionCmd(e *env, args []string) int {
+	fs := e.flagSet("version")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+
+	fmt.Fprintf(e.stdout, "balabol %s\n", e.version)
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ExitOK
+	}
+	fmt.Fprintf(e.stdout, "go: %s\n", info.GoVersion)
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision", "vcs.time", "vcs.modified", "GOOS", "GOARCH":
+			fmt.Fprintf(e.stdout, "%s: %s\n", s.Key, s.Value)
+		}
+	}
+	return ExitOK
+}
+
+// printConfigCmd выводит итоговую конфигурацию (с наследованием в bots и значениями
+// по умолчанию) в формате YAML. Токены заменяются маской.
+func printConfigCmd(e *env, args []string) int {
+	fs := e.flagSet("print-config")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+
+	cfg, err := config.GetConfig(e.config())
+	if err != nil {
+		return e.invalidConfig(err)
+	}
+
+	masked := *cfg
+	masked.Telegram.Token = mask(masked.Telegram.Token)
+	masked.Bots = make([]config.BotConfig, len(cfg.Bots))
+	for i, b := range cfg.Bots {
+		b.Telegram.Token = mask(b.Telegram.Token)
+		masked.Bots[i] = b
+	}
+
+	enc := yaml.NewEncoder(e.stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(&masked); err != nil {
+		fmt.Fprintf(e.stderr, "print config: %v\n", err)
+		return ExitError
+	}
+	_ = enc.Close()
+	return ExitOK
+}
+
+// invalidConfig выводит ошибку загрузки конфигурации и возвращает код ExitInvalidConfig.
+// Ошибки проверки уже содержат путь к файлу и позиции.
+func (e *env) invalidConfig(err error) int {
+	var verr *config.ValidationError
+	if errors.As(err, &verr) {
+		fmt.Fprintf(e.stderr, "invalid config %v\n", err)
+	} else {
+		fmt.Fprintf(e.stderr, "invalid config %s: %v\n", e.config(), err)
+	}
+	return ExitInvalidConfig
+}
+
+// mask скрывает непустой секрет
+func mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return secretMask
+}
+
+// rulesUsage — справка по группе команд rules
+const rulesUsage = `usage: balabol rules list [--bot name]
+       balabol rules export [--bot name] [--format csv|json|yaml] [-o file]
+       balabol rules import [--bot name | --base file] [--format csv|json|yaml] [--strategy merge|replace] [-o file] file
+`
+
+// rulesCmd обрабатывает группу команд rules
+func rulesCmd(e *env, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(e.stderr, rulesUsage)
+		return ExitUsage
+	}
+	switch args[0] {
+	case "list":
+		return rulesListCmd(e, args[1:])
+	case "export":
+		return rulesExportCmd(e, args[1:])
+	case "import":
+		return rulesImportCmd(e, args[1:])
+	}
+	fmt.Fprint(e.stderr, rulesUsage)
+	return ExitUsage
+}
+
+// rulesListCmd выводит таблицу правил всех ботов или одного бота
+func rulesListCmd(e *env, args []string) int {
+	fs := e.flagSet("rules list")
+	bot := fs.String("bot", "", "show rules of this bot only")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+
+	cfg, err := config.GetConfig(e.config())
+	if err != nil {
+		return e.invalidConfig(err)
+	}
+	if *bot != "" && cfg.Bot(*bot) == nil {
+		fmt.Fprintf(e.stderr, "bot %q not found\n", *bot)
+		return ExitUsage
+	}
+
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "BOT\t#\tTEXT\tPATTERN\tRESPONSE\tSOURCE\tSHADOW")
+	for _, b := range cfg.Bots {
+		if *bot != "" && b.Name != *bot {
+			continue
+		}
+		for i, r := range b.Rules {
+			shadow := ""
+			if r.Shadow {
+				shadow = "yes"
+			}
+			response := r.Response
+			if r.Experiment != nil {
+				response = experimentSummary(r.Experiment)
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", b.Name, i+1, oneLine(r.Text), oneLine(r.Pattern), oneLine(response), source(r), shadow)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return ExitError
+	}
+	return ExitOK
+}
+
+// rulesExportCmd выгружает правила в CSV, JSON или YAML.
+// Без --bot выгружаются корневые правила (секция rules и файлы правил без bot),
+// с --bot — все действующие правила бота.
+func rulesExportCmd(e *env, args []string) int {
+	fs := e.flagSet("rules export")
+	bot := fs.String("bot", "", "export the effective rules of this bot")
+	format := fs.String("format", "", "output format: csv, json or yaml (default: by -o extension, else yaml)")
+	out := fs.String("o", "", "write to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	if *format == "" {
+		*format = config.RulesFormat(*out)
+	}
+	if *format == "" {
+		*format = config.RulesFormatYAML
+	}
+
+	cfg, err := config.GetConfig(e.config())
+	if err != nil {
+		return e.invalidConfig(err)
+	}
+	rules, ok := e.configRules(cfg, *bot)
+	if !ok {
+		return ExitUsage
+	}
+
+	var buf bytes.Buffer
+	if err := config.ExportRules(&buf, *format, rules); err != nil {
+		fmt.Fprintf(e.stderr, "export rules: %v\n", err)
+		return ExitUsage
+	}
+	return e.writeOutput(*out, buf.Bytes())
+}
+
+// rulesImportCmd читает правила из CSV, JSON или YAML, проверяет каждое правило,
+// сообщает о конфликтах с существующими правилами и выводит чистый YAML-файл правил.
+// Существующие правила берутся из файла --base или из конфигурации (корневые или бота --bot).
+// Конфликты не останавливают импорт: они выводятся в stderr для проверки.
+func rulesImportCmd(e *env, args []string) int {
+	fs := e.flagSet("rules import")
+	bot := fs.String("bot", "", "compare with the effective rules of this bot")
+	base := fs.String("base", "", "compare with and merge into this rules file instead of the config")
+	format := fs.String("format", "", "input format: csv, json or yaml (default: by file extension)")
+	strategy := fs.String("strategy", config.ImportMerge, "merge: keep existing rules and update them by text; replace: output imported rules only")
+	out := fs.String("o", "", "write the rules file here instead of stdout")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(files) != 1 {
+		fmt.Fprint(e.stderr, rulesUsage)
+		return ExitUsage
+	}
+	if *bot != "" && *base != "" {
+		fmt.Fprintln(e.stderr, "--bot and --base are mutually exclusive")
+		return ExitUsage
+	}
+	path := files[0]
+	if *format == "" {
+		*format = config.RulesFormat(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "import rules: %v\n", err)
+		return ExitError
+	}
+	imported, err := config.ImportRules(path, data, *format)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "invalid rules %v\n", err)
+		return ExitInvalidConfig
+	}
+
+	// Существующие правила для сравнения и слияния
+	var (
+		existing []config.Rule
+		fileBot  string // поле bot базового файла сохраняется в результате
+	)
+	if *base != "" {
+		data, err := os.ReadFile(*base)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(e.stderr, "import rules: %v\n", err)
+			return ExitError
+		}
+		if err == nil {
+			rf, err := config.ParseRulesFile(*base, data)
+			if err != nil {
+				fmt.Fprintf(e.stderr, "invalid rules %v\n", err)
+				return ExitInvalidConfig
+			}
+			existing, fileBot = rf.Rules, rf.Bot
+		}
+	} else {
+		cfg, err := config.GetConfig(e.config())
+		if err != nil {
+			return e.invalidConfig(err)
+		}
+		rules, ok := e.configRules(cfg, *bot)
+		if !ok {
+			return ExitUsage
+		}
+		existing = rules
+	}
+
+	merged, conflicts, err := config.MergeRules(existing, imported, *strategy)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "import rules: %v\n", err)
+		return ExitUsage
+	}
+	for _, c := range conflicts {
+		fmt.Fprintf(e.stderr, "conflict: %s\n", c)
+	}
+	fmt.Fprintf(e.stderr, "imported %d rule(s) from %s (%s), %d conflict(s), %d rule(s) in result\n",
+		len(imported), path, *strategy, len(conflicts), len(merged))
+
+	var buf bytes.Buffer
+	if err := config.WriteRulesFile(&buf, fileBot, merged); err != nil {
+		fmt.Fprintf(e.stderr, "import rules: %v\n", err)
+		return ExitError
+	}
+	return e.writeOutput(*out, buf.Bytes())
+}
+
+// configRules возвращает корневые правила конфигурации или правила бота.
+// Если бот не найден, печатает ошибку и возвращает ok=false.
+func (e *env) configRules(cfg *config.Config, bot string) (rules []config.Rule, ok bool) {
+	if bot == "" {
+		return cfg.Rules, true
+	}
+	b := cfg.Bot(bot)
+	if b == nil {
+		fmt.Fprintf(e.stderr, "bot %q not found\n", bot)
+		return nil, false
+	}
+	return b.Rules, true
+}
+
+// writeOutput пишет результат команды в файл или, если путь пуст, в stdout
+func (e *env) writeOutput(path string, data []byte) int {
+	if path == "" {
+		_, _ = e.stdout.Write(data)
+		return ExitOK
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		fmt.Fprintf(e.stderr, "write %s: %v\n", path, err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+// source возвращает файл, из которого загружено правило
+func source(r config.Rule) string {
+	if r.Source == "" {
+		return "config"
+	}
+	return r.Source
+}
+
+// oneLine заменяет переводы строк, чтобы не ломать таблицу
+func oneLine(s string) string {
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+// lintCmd выводит замечания линтера правил.
+// Ошибки (правило не может сработать или срабатывает на всё) дают код ExitInvalidConfig,
+// предупреждения — только с флагом --strict.
+func lintCmd(e *env, args []string) int {
+	fs := e.flagSet("lint")
+	strict := fs.Bool("strict", false, "fail on warnings too")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+
+	cfg, err := config.GetConfig(e.config())
+	if err != nil {
+		return e.invalidConfig(err)
+	}
+
+	issues := cfg.Lint()
+	failed := false
+	for _, issue := range issues {
+		fmt.Fprintln(e.stdout, issue.String())
+		if issue.Severity == config.LintError || *strict {
+			failed = true
+		}
+	}
+	if failed {
+		return ExitInvalidConfig
+	}
+	if len(issues) == 0 {
+		fmt.Fprintln(e.stdout, "no problems found")
+	}
+	return ExitOK
+}
+
+// experimentSummary описывает эксперимент в колонке RESPONSE: имя и варианты
+func experimentSummary(exp *config.Experiment) string {
+	names := make([]string, len(exp.Variants))
+	for i, v := range exp.Variants {
+		names[i] = v.Name
+	}
+	return fmt.Sprintf("experiment %q: %s", exp.Name, strings.Join(names, ", "))
+}
+
+// experimentsCmd выводит итоги A/B-экспериментов из файла experiments.file.
+// Работающий бот сохраняет результаты раз в минуту, поэтому последние исходы могут ещё не попасть в файл.
+func experimentsCmd(e *env, args []string) int {
+	fs := e.flagSet("experiments")
+	bot := fs.String("bot", "", "show experiments of this bot only")
+	format := fs.String("format", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(e.stderr, "unknown format %q (want table or json)\n", *format)
+		return ExitUsage
+	}
+
+	cfg, err := config.GetConfig(e.config())
+	if err != nil {
+		return e.invalidConfig(err)
+	}
+	if *bot != "" && cfg.Bot(*bot) == nil {
+		fmt.Fprintf(e.stderr, "bot %q not found\n", *bot)
+		return ExitUsage
+	}
+	store, err := experiments.Open(cfg.Experiments.File)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "experiments: %v\n", err)
+		return ExitError
+	}
+	report := store.Report(*bot, time.Now(), cfg.Experiments.Window)
+
+	if *format == "json" {
+		if report == nil {
+			report = []experiments.Result{}
+		}
+		enc := json.NewEncoder(e.stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return ExitError
+		}
+		return ExitOK
+	}
+
+	if len(report) == 0 {
+		fmt.Fprintln(e.stdout, "no experiment results yet")
+		return ExitOK
+	}
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "BOT\tEXPERIMENT\tVARIANT\tEXPOSURES\tREPLIES\tREACTIONS\tENGAGED\tPENDING\tRATE")
+	for _, res := range report {
+		for _, v := range res.Variants {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%.1f%%\n",
+				res.Bot, oneLine(res.Experiment), oneLine(v.Name), v.Exposures, v.Replies, v.Reactions, v.Engaged, v.Pending, v.Rate*100)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return ExitError
+	}
+	return ExitOK
+}
+
+// schemaCmd выводит JSON Schema файла config.yaml или файла правил для подсказок в редакторе
+func schemaCmd(e *env, args []string) int {
+	fs := e.flagSet("schema")
+	rules := fs.Bool("rules", false, "print the schema of a rules file (include, rules_dir)")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+
+	schemaFn := config.JSONSchema
+	if *rules {
+		schemaFn = config.RulesFileJSONSchema
+	}
+	schema, err := schemaFn()
+	if err != nil {
+		fmt.Fprintf(e.stderr, "schema: %v\n", err)
+		return ExitError
+	}
+	fmt.Fprintln(e.stdout, string(schema))
+	return ExitOK
+}
@@ -0,0 +1,329 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/st-kuptsov/balabol/config"          // загрузка конфигурации
+	"github.com/st-kuptsov/balabol/internal/replay" // прогон выгрузки чата через правила
+	"go.uber.org/zap"                               // логгер для проверки правил
+)
+
+// replayUsage — справка по команде replay
+const replayUsage = `usage: balabol replay [--bot name] [--compare config.yaml] [--format text|csv] [--samples n] [-o file] result.json
+`
+
+// sampleWidth — сколько символов текста сообщения выводится в примерах
+const sampleWidth = 100
+
+// replayCmd прогоняет выгрузку чата Telegram Desktop (result.json) через очистку текста
+// и правила бота и выводит срабатывания правил, долю ответов, примеры сообщений
+// и сообщения, на которых сработало несколько правил.
+// С --compare выгрузка прогоняется и через правила второй конфигурации, и выводятся различия.
+func replayCmd(e *env, args []string) int {
+	fs := e.flagSet("replay")
+	bot := fs.String("bot", "", "use the rules of this bot (default: the first bot)")
+	compare := fs.String("compare", "", "also replay with this config file and show the differences")
+	format := fs.String("format", "text", "output format: text or csv")
+	samples := fs.Int("samples", 3, "sample messages per rule, collision and change")
+	out := fs.String("o", "", "write to this file instead of stdout")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(files) != 1 {
+		fmt.Fprint(e.stderr, replayUsage)
+		return ExitUsage
+	}
+	if *format != "text" && *format != "csv" {
+		fmt.Fprintf(e.stderr, "unknown format %q (want text or csv)\n", *format)
+		return ExitUsage
+	}
+	if *samples < 0 {
+		fmt.Fprintln(e.stderr, "--samples must not be negative")
+		return ExitUsage
+	}
+
+	base, code := e.replayBot(e.config(), *bot)
+	if base == nil {
+		return code
+	}
+	var other *config.BotConfig
+	if *compare != "" {
+		if other, code = e.replayBot(*compare, base.Name); other == nil {
+			return code
+		}
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		fmt.Fprintf(e.stderr, "replay: %v\n", err)
+		return ExitError
+	}
+	export, err := replay.ReadExport(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(e.stderr, "replay: %v\n", err)
+		return ExitError
+	}
+
+	logger := zap.NewNop().Sugar()
+	rep := replay.Run(export.Messages, base, *samples, logger)
+	var otherRep *replay.Report
+	var cmp *replay.Comparison
+	if other != nil {
+		otherRep = replay.Run(export.Messages, other, *samples, logger)
+		cmp = replay.Compare(rep, otherRep, *samples)
+	}
+
+	var buf bytes.Buffer
+	if *format == "csv" {
+		err = writeReplayCSV(&buf, rep, otherRep, cmp)
+	} else {
+		err = writeReplayText(&buf, export, rep, otherRep, cmp)
+	}
+	if err != nil {
+		fmt.Fprintf(e.stderr, "replay: %v\n", err)
+		return ExitError
+	}
+	return e.writeOutput(*out, buf.Bytes())
+}
+
+// replayBot загружает конфигурацию path и возвращает настройки бота name (пусто — первого бота).
+// При ошибке печатает её и возвращает nil и код завершения.
+func (e *env) replayBot(path, name string) (*config.BotConfig, int) {
+	cfg, err := config.GetConfig(path)
+	if err != nil {
+		return nil, e.invalidConfig(fmt.Errorf("%s: %w", path, err))
+	}
+	if name == "" {
+		return &cfg.Bots[0], ExitOK
+	}
+	b := cfg.Bot(name)
+	if b == nil {
+		fmt.Fprintf(e.stderr, "bot %q not found in %s\n", name, path)
+		return nil, ExitUsage
+	}
+	return b, ExitOK
+}
+
+// writeReplayText выводит отчёт replay в виде таблиц
+func writeReplayText(w io.Writer, export *replay.Export, rep, other *replay.Report, cmp *replay.Comparison) error {
+	withChat := len(export.Chats) > 1 // в выгрузке аккаунта у примеров указывается чат
+	fmt.Fprintf(w, "bot %s: %d message(s) from %d chat(s), skipped %d service, %d media, %d empty\n",
+		rep.Bot, rep.Messages, len(export.Chats), export.Service, export.Media, export.Empty)
+	writeReplaySummary(w, "", rep)
+	if other != nil {
+		writeReplaySummary(w, "compare: ", other)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if cmp == nil {
+		fmt.Fprintln(tw, "RULE\tSOURCE\tSHADOW\tHITS\tSHARE")
+		for _, r := range rep.Rules {
+			shadow := ""
+			if r.Shadow {
+				shadow = "yes"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.1f%%\n", oneLine(r.Text), sourceName(r.Source), shadow, r.Hits, rep.Share(r)*100)
+		}
+	} else {
+		fmt.Fprintln(tw, "RULE\tBASE\tCOMPARE\tDELTA")
+		for _, r := range cmp.Rules {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%+d\n", oneLine(r.Text), hitsCell(r.Base, r.InBase), hitsCell(r.Other, r.InOther), r.Other-r.Base)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if cmp != nil {
+		fmt.Fprintf(w, "\nchanged: %d message(s) match different rules\n", cmp.Changed)
+		for _, c := range cmp.Changes {
+			fmt.Fprintf(w, "  %s\n    before: %s\n    after:  %s\n", sampleLine(c.Sample, withChat), ruleList(c.Before), ruleList(c.After))
+		}
+		return nil
+	}
+
+	fmt.Fprintln(w, "\nsamples:")
+	for _, r := range rep.Rules {
+		if len(r.Samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %s\n", oneLine(r.Text))
+		for _, s := range r.Samples {
+			fmt.Fprintf(w, "    %s\n", sampleLine(s, withChat))
+		}
+	}
+
+	fmt.Fprintln(w, "\ncollisions:")
+	if len(rep.Collisions) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+	for _, c := range rep.Collisions {
+		fmt.Fprintf(w, "  %s: %d message(s)\n", ruleList(c.Rules), c.Count)
+		for _, s := range c.Samples {
+			fmt.Fprintf(w, "    %s\n", sampleLine(s, withChat))
+		}
+	}
+	return nil
+}
+
+// writeReplaySummary выводит строку с долей сообщений, на которые сработали правила и ответил бы бот
+func writeReplaySummary(w io.Writer, prefix string, rep *replay.Report) {
+	collided := 0
+	for _, c := range rep.Collisions {
+		collided += c.Count
+	}
+	fmt.Fprintf(w, "%smatched %d (%.1f%%), would reply to %d (%.1f%%), %d message(s) match several rules\n",
+		prefix, rep.Matched, rep.MatchRate()*100, rep.Replied, rep.ReplyRate()*100, collided)
+}
+
+// writeReplayCSV выводит отчёт replay в CSV: строки итогов (messages, matched, replied),
+// строки правил (rule), сочетаний правил (collision) и, с --compare, число изменившихся сообщений (changed).
+// Доли — от 0 до 1; примеры сообщений разделяются " | ".
+func writeReplayCSV(w io.Writer, rep, other *replay.Report, cmp *replay.Comparison) error {
+	cw := csv.NewWriter(w)
+	header := []string{"kind", "rule", "source", "shadow", "hits", "share"}
+	if other != nil {
+		header = append(header, "compare_hits", "compare_share")
+	}
+	header = append(header, "samples")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	// row записывает строку; base и compare — число и доля сообщений в двух конфигурациях
+	// (nil — пустые колонки)
+	row := func(kind, rule, source, shadow string, base, compare []string, samples []replay.Sample) {
+		if base == nil {
+			base = []string{"", ""}
+		}
+		rec := append([]string{kind, rule, source, shadow}, base...)
+		if other != nil {
+			if compare == nil {
+				compare = []string{"", ""}
+			}
+			rec = append(rec, compare...)
+		}
+		texts := make([]string, len(samples))
+		for i, s := range samples {
+			texts[i] = oneLine(s.Text)
+		}
+		_ = cw.Write(append(rec, strings.Join(texts, " | ")))
+	}
+	count := func(n, total int) []string {
+		return []string{strconv.Itoa(n), shareCell(n, total)}
+	}
+
+	// Итоги
+	for _, t := range []struct {
+		kind string
+		hits func(*replay.Report) int
+	}{
+		{"messages", func(r *replay.Report) int { return r.Messages }},
+		{"matched", func(r *replay.Report) int { return r.Matched }},
+		{"replied", func(r *replay.Report) int { return r.Replied }},
+	} {
+		var compare []string
+		if other != nil {
+			compare = count(t.hits(other), other.Messages)
+		}
+		row(t.kind, "", "", "", count(t.hits(rep), rep.Messages), compare, nil)
+	}
+
+	// Правила: без --compare — правила бота, с --compare — правила обеих конфигураций
+	if cmp == nil {
+		for _, r := range rep.Rules {
+			row("rule", r.Text, sourceName(r.Source), strconv.FormatBool(r.Shadow), count(r.Hits, rep.Messages), nil, r.Samples)
+		}
+	} else {
+		rules := make(map[string]replay.RuleResult)
+		for _, r := range other.Rules {
+			rules[r.Text] = r
+		}
+		for _, r := range rep.Rules {
+			rules[r.Text] = r
+		}
+		for _, d := range cmp.Rules {
+			r := rules[d.Text]
+			var base, compare []string
+			if d.InBase {
+				base = count(d.Base, rep.Messages)
+			}
+			if d.InOther {
+				compare = count(d.Other, other.Messages)
+			}
+			row("rule", d.Text, sourceName(r.Source), strconv.FormatBool(r.Shadow), base, compare, r.Samples)
+		}
+	}
+
+	for _, c := range rep.Collisions {
+		row("collision", strings.Join(c.Rules, " + "), "", "", count(c.Count, rep.Messages), nil, c.Samples)
+	}
+	if cmp != nil {
+		changed := make([]replay.Sample, len(cmp.Changes))
+		for i, c := range cmp.Changes {
+			changed[i] = c.Sample
+		}
+		row("changed", "", "", "", count(cmp.Changed, rep.Messages), nil, changed)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// sourceName возвращает файл, из которого загружено правило (как source)
+func sourceName(src string) string {
+	if src == "" {
+		return "config"
+	}
+	return src
+}
+
+// hitsCell выводит число срабатываний или "-", если правила нет в конфигурации
+func hitsCell(hits int, present bool) string {
+	if !present {
+		return "-"
+	}
+	return strconv.Itoa(hits)
+}
+
+// shareCell выводит долю n от total для CSV
+func shareCell(n, total int) string {
+	if total == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(float64(n)/float64(total), 'f', 4, 64)
+}
+
+// ruleList выводит список сработавших правил
+func ruleList(rules []string) string {
+	if len(rules) == 0 {
+		return "(none)"
+	}
+	return oneLine(strings.Join(rules, " + "))
+}
+
+// sampleLine выводит пример сообщения: ID, отправителя и начало текста
+func sampleLine(s replay.Sample, withChat bool) string {
+	text := []rune(oneLine(s.Text))
+	if len(text) > sampleWidth {
+		text = append(text[:sampleWidth], '…')
+	}
+	line := fmt.Sprintf("#%d %s", s.ID, string(text))
+	if s.From != "" {
+		line = fmt.Sprintf("#%d %s: %s", s.ID, s.From, string(text))
+	}
+	if withChat {
+		line = s.Chat + " " + line
+	}
+	return line
+}
@@ -0,0 +1,134 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Message — текстовое сообщение из выгрузки чата
+type Message struct {
+	ID   int    // ID сообщения в чате
+	Chat string // название чата
+	From string // имя отправителя
+	Text string // текст сообщения (форматирование отброшено)
+}
+
+// Export — сообщения из выгрузки Telegram Desktop
+type Export struct {
+	Chats    []string  // названия чатов в выгрузке
+	Messages []Message // текстовые сообщения, которые бот получил бы как текст
+	Service  int       // служебные сообщения (вступления, закрепы и т.п.)
+	Media    int       // фото, файлы, стикеры, опросы: их подпись бот как текст не получает
+	Empty    int       // сообщения без текста
+}
+
+// exportChat — чат в файле result.json
+type exportChat struct {
+	Name     string          `json:"name"`
+	Messages []exportMessage `json:"messages"`
+}
+
+// exportFile — файл result.json: выгрузка одного чата или всего аккаунта (chats.list)
+type exportFile struct {
+	exportChat
+	Chats *struct {
+		List []exportChat `json:"list"`
+	} `json:"chats"`
+}
+
+// exportMessage — сообщение в файле result.json
+type exportMessage struct {
+	ID        int             `json:"id"`
+	Type      string          `json:"type"` // message или service
+	From      *string         `json:"from"`
+	Text      exportText      `json:"text"`
+	Photo     string          `json:"photo"`
+	File      string          `json:"file"`
+	MediaType string          `json:"media_type"`
+	Poll      json.RawMessage `json:"poll"`
+	Location  json.RawMessage `json:"location_information"`
+	Contact   json.RawMessage `json:"contact_information"`
+}
+
+// exportText — текст сообщения: строка или массив из строк и фрагментов с форматированием
+// ({"type": "bold", "text": "..."}, ссылки, упоминания и т.п.)
+type exportText string
+
+// UnmarshalJSON склеивает фрагменты текста в одну строку
+func (t *exportText) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '[' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*t = exportText(s)
+		return nil
+	}
+
+	var parts []json.RawMessage
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, part := range parts {
+		var s string
+		if err := json.Unmarshal(part, &s); err == nil {
+			b.WriteString(s)
+			continue
+		}
+		var entity struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(part, &entity); err != nil {
+			return fmt.Errorf("text entity: %w", err)
+		}
+		b.WriteString(entity.Text)
+	}
+	*t = exportText(b.String())
+	return nil
+}
+
+// ReadExport читает выгрузку чата Telegram Desktop в формате JSON (result.json).
+// Поддерживаются выгрузка одного чата и выгрузка всего аккаунта.
+// Сообщения с медиа пропускаются: бот отвечает только на текстовые сообщения.
+func ReadExport(r io.Reader) (*Export, error) {
+	var f exportFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("telegram export: %w", err)
+	}
+
+	chats := []exportChat{f.exportChat}
+	if f.Messages == nil && f.Chats != nil {
+		chats = f.Chats.List
+	}
+	if len(chats) == 0 || (len(chats) == 1 && chats[0].Messages == nil) {
+		return nil, errors.New("telegram export: no messages found (expected result.json of a JSON export)")
+	}
+
+	ex := &Export{}
+	for _, chat := range chats {
+		ex.Chats = append(ex.Chats, chat.Name)
+		for _, m := range chat.Messages {
+			switch {
+			case m.Type != "message":
+				ex.Service++
+			case m.Photo != "" || m.File != "" || m.MediaType != "" || m.Poll != nil || m.Location != nil || m.Contact != nil:
+				ex.Media++
+			case strings.TrimSpace(string(m.Text)) == "":
+				ex.Empty++
+			default:
+				msg := Message{ID: m.ID, Chat: chat.Name, Text: string(m.Text)}
+				if m.From != nil {
+					msg.From = *m.From
+				}
+				ex.Messages = append(ex.Messages, msg)
+			}
+		}
+	}
+	return ex, nil
+}
@@ -0,0 +1,85 @@
+package replay
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExportTextUnmarshal(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		json string
+		want string
+	}{
+		{"plain string", `"привет"`, "привет"},
+		{"empty string", `""`, ""},
+		{"only strings", `["при", "вет"]`, "привет"},
+		{"mixed entities", `["Смотри ", {"type": "bold", "text": "жирный"}, " и ", {"type": "mention", "text": "@balabol"}]`, "Смотри жирный и @balabol"},
+		{"entity without text", `["до", {"type": "custom_emoji", "document_id": "1"}, "после"]`, "допосле"},
+		{"empty array", `[]`, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got exportText
+			if err := json.Unmarshal([]byte(tc.json), &got); err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("text = %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	var got exportText
+	if err := json.Unmarshal([]byte(`["текст", 42]`), &got); err == nil {
+		t.Errorf("number in text array: want error, got %q", got)
+	}
+}
+
+func TestReadExport(t *testing.T) {
+	f, err := os.Open("testdata/result.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	ex, err := ReadExport(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ex.Chats, []string{"Флудилка"}) {
+		t.Errorf("chats = %q, want [Флудилка]", ex.Chats)
+	}
+	want := []Message{
+		{ID: 2, Chat: "Флудилка", From: "Аня", Text: "Привет всем"},
+		{ID: 3, Chat: "Флудилка", From: "Боря", Text: "Смотри жирный и https://example.com!"},
+		{ID: 8, Chat: "Флудилка", Text: "от удалённого аккаунта"},
+	}
+	if !slices.Equal(ex.Messages, want) {
+		t.Errorf("messages = %+v, want %+v", ex.Messages, want)
+	}
+	// Фото с подписью, стикер и опрос — медиа; пустой текст и служебные сообщения считаются отдельно
+	if ex.Media != 3 || ex.Service != 2 || ex.Empty != 1 {
+		t.Errorf("media, service, empty = %d, %d, %d; want 3, 2, 1", ex.Media, ex.Service, ex.Empty)
+	}
+}
+
+func TestReadExportAccount(t *testing.T) {
+	const account = `{"chats": {"list": [
+		{"name": "Первый", "messages": [{"id": 1, "type": "message", "from": "Аня", "text": "раз"}]},
+		{"name": "Второй", "messages": [{"id": 1, "type": "message", "from": "Боря", "text": "два"}]}
+	]}}`
+	ex, err := ReadExport(strings.NewReader(account))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ex.Chats, []string{"Первый", "Второй"}) || len(ex.Messages) != 2 || ex.Messages[1].Chat != "Второй" {
+		t.Errorf("export = %+v, want messages from both chats", ex)
+	}
+
+	if _, err := ReadExport(strings.NewReader(`{"name": "html export"}`)); err == nil || !strings.Contains(err.Error(), "no messages found") {
+		t.Errorf("export without messages: err = %v, want no messages found", err)
+	}
+}
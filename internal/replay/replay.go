@@ -0,0 +1,216 @@
+package replay
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/st-kuptsov/balabol/config"            // правила и настройки бота
+	"github.com/st-kuptsov/balabol/internal/telegram" // очистка текста и проверка правил
+	"go.uber.org/zap"                                 // структурированное логирование
+)
+
+// shadowMark отмечает теневые правила в списках сработавших правил
+const shadowMark = " (shadow)"
+
+// Sample — пример сообщения в отчёте
+type Sample struct {
+	Chat string // название чата
+	ID   int    // ID сообщения в чате
+	From string // имя отправителя
+	Text string // исходный текст
+}
+
+// RuleResult — срабатывания одного правила
+type RuleResult struct {
+	Text    string   // описание правила (text)
+	Source  string   // файл правила; пусто — config.yaml
+	Shadow  bool     // теневое правило
+	Hits    int      // сообщения, на которых сработало правило
+	Samples []Sample // первые сообщения, на которых сработало правило
+}
+
+// Collision — сочетание правил, сработавших на одном сообщении
+type Collision struct {
+	Rules   []string // правила в порядке конфигурации; теневые — с пометкой (shadow)
+	Count   int      // число таких сообщений
+	Samples []Sample // первые такие сообщения
+}
+
+// Report — итоги прогона выгрузки через правила бота
+type Report struct {
+	Bot        string
+	Messages   int          // проверенные сообщения
+	Matched    int          // сообщения, на которых сработало хотя бы одно обычное правило
+	Replied    int          // сообщения, на которые бот ответил бы
+	Rules      []RuleResult // в порядке правил бота
+	Collisions []Collision  // по убыванию числа сообщений
+
+	messages []Message  // проверенные сообщения — для Compare
+	matches  [][]string // сработавшие правила каждого сообщения — для Compare
+}
+
+// MatchRate возвращает долю сообщений, на которых сработало хотя бы одно обычное правило
+func (r *Report) MatchRate() float64 {
+	return share(r.Matched, r.Messages)
+}
+
+// ReplyRate возвращает долю сообщений, на которые бот ответил бы
+func (r *Report) ReplyRate() float64 {
+	return share(r.Replied, r.Messages)
+}
+
+// Share возвращает долю проверенных сообщений, на которых сработало правило
+func (r *Report) Share(res RuleResult) float64 {
+	return share(res.Hits, r.Messages)
+}
+
+// Run прогоняет сообщения выгрузки через очистку текста и правила бота.
+//
+// Параметры:
+// - messages: текстовые сообщения выгрузки
+// - settings: настройки бота (правила, режим, очистка текста)
+// - samples: сколько примеров сообщений сохранять для каждого правила и сочетания
+// - logger: логгер для отладки
+//
+// Возвращает отчёт со срабатываниями правил и сочетаниями правил на одном сообщении.
+func Run(messages []Message, settings *config.BotConfig, samples int, logger *zap.SugaredLogger) *Report {
+	rep := &Report{
+		Bot:      settings.Name,
+		Messages: len(messages),
+		Rules:    make([]RuleResult, len(settings.Rules)),
+		messages: messages,
+		matches:  make([][]string, len(messages)),
+	}
+	for i, r := range settings.Rules {
+		rep.Rules[i] = RuleResult{Text: r.Text, Source: r.Source, Shadow: r.Shadow}
+	}
+
+	collisions := make(map[string]*Collision)
+	for i, m := range messages {
+		ev := telegram.Evaluate(m.Text, settings, logger)
+		if len(ev.Rules) > 0 {
+			rep.Matched++
+		}
+		if ev.Reply {
+			rep.Replied++
+		}
+
+		// Обычные и теневые правила в порядке конфигурации
+		matched := slices.Concat(ev.Rules, ev.Shadow)
+		slices.Sort(matched)
+		names := make([]string, len(matched))
+		for j, idx := range matched {
+			res := &rep.Rules[idx]
+			res.Hits++
+			res.Samples = addSample(res.Samples, m, samples)
+			names[j] = res.Text
+			if res.Shadow {
+				names[j] += shadowMark
+			}
+		}
+		rep.matches[i] = names
+
+		if len(names) < 2 {
+			continue
+		}
+		key := strings.Join(names, "\x00")
+		c := collisions[key]
+		if c == nil {
+			c = &Collision{Rules: names}
+			collisions[key] = c
+		}
+		c.Count++
+		c.Samples = addSample(c.Samples, m, samples)
+	}
+
+	for _, c := range collisions {
+		rep.Collisions = append(rep.Collisions, *c)
+	}
+	sort.Slice(rep.Collisions, func(i, j int) bool {
+		a, b := rep.Collisions[i], rep.Collisions[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return strings.Join(a.Rules, "\x00") < strings.Join(b.Rules, "\x00")
+	})
+	return rep
+}
+
+// RuleDiff — срабатывания правила в двух конфигурациях
+type RuleDiff struct {
+	Text            string
+	Base, Other     int  // сообщения, на которых сработало правило
+	InBase, InOther bool // правило есть в конфигурации
+}
+
+// Change — сообщение, на котором сработали разные правила
+type Change struct {
+	Sample
+	Before []string // правила в базовой конфигурации
+	After  []string // правила в сравниваемой конфигурации
+}
+
+// Comparison — различия прогона одной выгрузки с двумя конфигурациями
+type Comparison struct {
+	Rules   []RuleDiff // правила базовой конфигурации, затем новые правила
+	Changed int        // сообщения, на которых сработали разные правила
+	Changes []Change   // первые такие сообщения
+}
+
+// Compare сравнивает отчёты по одной выгрузке с базовой (base) и новой (other) конфигурацией.
+// Правила сопоставляются по описанию (text); samples — сколько изменившихся сообщений сохранить.
+func Compare(base, other *Report, samples int) *Comparison {
+	cmp := &Comparison{}
+	index := make(map[string]int)
+	for _, r := range base.Rules {
+		if i, ok := index[r.Text]; ok {
+			cmp.Rules[i].Base += r.Hits
+			continue
+		}
+		index[r.Text] = len(cmp.Rules)
+		cmp.Rules = append(cmp.Rules, RuleDiff{Text: r.Text, Base: r.Hits, InBase: true})
+	}
+	for _, r := range other.Rules {
+		i, ok := index[r.Text]
+		if !ok {
+			i = len(cmp.Rules)
+			index[r.Text] = i
+			cmp.Rules = append(cmp.Rules, RuleDiff{Text: r.Text})
+		}
+		cmp.Rules[i].Other += r.Hits
+		cmp.Rules[i].InOther = true
+	}
+
+	for i, m := range base.messages {
+		if i >= len(other.matches) || slices.Equal(base.matches[i], other.matches[i]) {
+			continue
+		}
+		cmp.Changed++
+		if len(cmp.Changes) < samples {
+			cmp.Changes = append(cmp.Changes, Change{Sample: sample(m), Before: base.matches[i], After: other.matches[i]})
+		}
+	}
+	return cmp
+}
+
+// addSample добавляет сообщение к примерам, пока их меньше limit
+func addSample(samples []Sample, m Message, limit int) []Sample {
+	if len(samples) >= limit {
+		return samples
+	}
+	return append(samples, sample(m))
+}
+
+// sample возвращает пример сообщения для отчёта
+func sample(m Message) Sample {
+	return Sample{Chat: m.Chat, ID: m.ID, From: m.From, Text: m.Text}
+}
+
+// share возвращает долю n от total
+func share(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
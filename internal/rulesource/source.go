@@ -0,0 +1,289 @@
+package rulesource
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config"      // настройки источников и разбор правил
+	"github.com/st-kuptsov/balabol/pkg/metrics" // метрики Prometheus
+	"go.uber.org/zap"                           // структурированное логирование
+)
+
+// maxDocumentSize — предельный размер документа с правилами
+const maxDocumentSize = 10 << 20
+
+// Результаты загрузки для метрики bot_rule_source_fetch_total
+const (
+	resultUpdated     = "updated"
+	resultNotModified = "not_modified"
+	resultError       = "error"
+)
+
+// Manager опрашивает удалённые источники правил из rule_sources
+// и подставляет загруженные правила в общую конфигурацию.
+// При ошибке загрузки продолжают действовать последние успешно загруженные правила.
+type Manager struct {
+	mu      sync.Mutex
+	conf    *config.CachedConfig
+	client  *http.Client
+	logger  *zap.SugaredLogger
+	sources map[string]*source // запущенные опросы по имени источника
+}
+
+// NewManager создаёт менеджер источников правил.
+// client может быть nil — тогда используется http.DefaultClient.
+func NewManager(conf *config.CachedConfig, client *http.Client, logger *zap.SugaredLogger) *Manager {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Manager{
+		conf:    conf,
+		client:  client,
+		logger:  logger,
+		sources: make(map[string]*source),
+	}
+}
+
+// Sync приводит набор опрашиваемых источников в соответствие с конфигурацией:
+// запускает новые, перезапускает изменённые и останавливает удалённые.
+// Возвращает список действий вида "rule_source:<имя>:<действие>".
+func (m *Manager) Sync() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cfg := m.conf.Current()
+	wanted := make(map[string]bool, len(cfg.RuleSources))
+	var actions []string
+	for _, sc := range cfg.RuleSources {
+		wanted[sc.Name] = true
+
+		action := "started"
+		if running, ok := m.sources[sc.Name]; ok {
+			if running.conf == sc {
+				continue
+			}
+			// Правила остаются в конфигурации до следующей успешной загрузки
+			running.stop()
+			action = "restarted"
+		}
+
+		s := newSource(sc, m.conf, m.client, m.logger.With("source", sc.Name))
+		m.sources[sc.Name] = s
+		go s.run()
+		actions = append(actions, "rule_source:"+sc.Name+":"+action)
+	}
+
+	for name, s := range m.sources {
+		if !wanted[name] {
+			s.stop()
+			delete(m.sources, name)
+			_ = m.conf.SetRemoteRules(name, nil)
+			metrics.DeleteRuleSource(name)
+			actions = append(actions, "rule_source:"+name+":stopped")
+		}
+	}
+	return actions
+}
+
+// StopAll останавливает опрос всех источников
+func (m *Manager) StopAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, s := range m.sources {
+		s.stop()
+		delete(m.sources, name)
+	}
+}
+
+// source — опрос одного удалённого источника
+type source struct {
+	conf   config.RuleSourceConfig
+	cache  *config.CachedConfig
+	client *http.Client
+	logger *zap.SugaredLogger
+
+	cancel context.CancelFunc
+	ctx    context.Context
+	done   chan struct{}
+
+	etag         string // ETag последнего успешного ответа
+	lastModified string // Last-Modified последнего успешного ответа
+}
+
+// newSource создаёт остановленный опрос источника
+func newSource(conf config.RuleSourceConfig, cache *config.CachedConfig, client *http.Client, logger *zap.SugaredLogger) *source {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &source{
+		conf:   conf,
+		cache:  cache,
+		client: client,
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+}
+
+// run загружает правила сразу и затем каждые interval до остановки
+func (s *source) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.conf.Interval)
+	defer ticker.Stop()
+	for {
+		s.poll()
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop останавливает опрос и дожидается завершения текущего запроса
+func (s *source) stop() {
+	s.cancel()
+	<-s.done
+}
+
+// poll выполняет одну загрузку и обновляет метрики
+func (s *source) poll() {
+	name := s.conf.Name
+	updated, rules, err := s.fetch()
+	switch {
+	case err != nil:
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		metrics.RuleSourceFetchTotal.WithLabelValues(name, resultError).Inc()
+		metrics.RuleSourceUp.WithLabelValues(name).Set(0)
+		s.logger.Warnw("rule source fetch failed, keeping last good rules", "url", s.conf.URL, "error", err)
+		return
+	case updated:
+		metrics.RuleSourceFetchTotal.WithLabelValues(name, resultUpdated).Inc()
+		metrics.RuleSourceRules.WithLabelValues(name).Set(float64(rules))
+		s.logger.Infow("rule source updated", "url", s.conf.URL, "rules_count", rules)
+		s.lint()
+	default:
+		metrics.RuleSourceFetchTotal.WithLabelValues(name, resultNotModified).Inc()
+	}
+	metrics.RuleSourceUp.WithLabelValues(name).Set(1)
+	metrics.RuleSourceLastSuccess.WithLabelValues(name).SetToCurrentTime()
+}
+
+// lint выводит в лог замечания линтера к правилам источника, как при загрузке конфигурации.
+// Правила источника меняются между reload, поэтому проверяются после каждого обновления.
+func (s *source) lint() {
+	for _, issue := range s.cache.Current().Lint() {
+		if issue.Source != s.conf.Name {
+			continue
+		}
+		s.logger.Warnw("rule lint",
+			"bot", issue.Bot,
+			"rule", issue.Rule,
+			"text", issue.Text,
+			"pattern", issue.Pattern,
+			"severity", issue.Severity,
+			"problem", issue.Message,
+		)
+	}
+}
+
+// fetch запрашивает документ с учётом ETag и Last-Modified,
+// проверяет его и подставляет правила в конфигурацию.
+// Возвращает updated=false, если документ не изменился (304).
+func (s *source) fetch() (updated bool, rules int, err error) {
+	ctx, cancel := context.WithTimeout(s.ctx, s.conf.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.conf.URL, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	if s.lastModified != "" {
+		req.Header.Set("If-Modified-Since", s.lastModified)
+	}
+	if s.conf.AuthHeader != "" {
+		if s.conf.AuthValue == "" {
+			return false, 0, fmt.Errorf("auth value for header %s is not set in secrets", s.conf.AuthHeader)
+		}
+		req.Header.Set(s.conf.AuthHeader, s.conf.AuthValue)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return false, 0, nil
+	default:
+		return false, 0, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1))
+	if err != nil {
+		return false, 0, fmt.Errorf("read body: %w", err)
+	}
+	if len(body) > maxDocumentSize {
+		return false, 0, fmt.Errorf("document is larger than %d bytes", maxDocumentSize)
+	}
+	if err := s.verify(body, resp.Header); err != nil {
+		return false, 0, err
+	}
+
+	rf, err := config.ParseRulesFile(s.conf.Name, bytes.TrimSpace(body))
+	if err != nil {
+		return false, 0, err
+	}
+	if err := s.cache.SetRemoteRules(s.conf.Name, rf); err != nil {
+		return false, 0, err
+	}
+
+	// Условные заголовки запоминаем только после того, как правила применены
+	s.etag = resp.Header.Get("ETag")
+	s.lastModified = resp.Header.Get("Last-Modified")
+	return true, len(rf.Rules), nil
+}
+
+// verify проверяет контрольную сумму и подпись документа, если они заданы
+func (s *source) verify(body []byte, header http.Header) error {
+	if s.conf.SHA256 != "" {
+		sum := sha256.Sum256(body)
+		if got := hex.EncodeToString(sum[:]); got != s.conf.SHA256 {
+			return fmt.Errorf("checksum mismatch: got sha256 %s", got)
+		}
+	}
+
+	if s.conf.PublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(s.conf.PublicKey)
+		if err != nil {
+			return fmt.Errorf("decode public key: %w", err)
+		}
+		sig, err := base64.StdEncoding.DecodeString(header.Get(s.conf.SignatureHeader))
+		if err != nil || len(sig) == 0 {
+			return fmt.Errorf("missing or malformed %s header", s.conf.SignatureHeader)
+		}
+		if !ed25519.Verify(key, body, sig) {
+			return errors.New("signature verification failed")
+		}
+	}
+	return nil
+}
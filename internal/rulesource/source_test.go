@@ -0,0 +1,264 @@
+package rulesource
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/st-kuptsov/balabol/config"
+	"github.com/st-kuptsov/balabol/internal/telegramtest"
+	"go.uber.org/zap"
+)
+
+// Документы с правилами, которые отдаёт тестовый источник
+const (
+	docV1 = "rules:\n  - text: remote-v1\n    pattern: 'один'\n    response: первый\n"
+	docV2 = "rules:\n  - text: remote-v2\n    pattern: 'два'\n    response: второй\n"
+)
+
+// origin — тестовый HTTP-источник правил: отдаёт текущий документ с ETag
+// и отвечает 304 на запрос с совпадающим If-None-Match
+type origin struct {
+	mu       sync.Mutex
+	doc      string      // текущий документ
+	etag     string      // ETag текущего документа
+	status   int         // если не 0 — ответ с этим статусом вместо документа
+	header   http.Header // дополнительные заголовки ответа
+	requests []*http.Request
+}
+
+func (o *origin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.requests = append(o.requests, r)
+	if o.status != 0 {
+		w.WriteHeader(o.status)
+		return
+	}
+	if o.etag != "" && r.Header.Get("If-None-Match") == o.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	for k, v := range o.header {
+		w.Header()[k] = v
+	}
+	if o.etag != "" {
+		w.Header().Set("ETag", o.etag)
+	}
+	_, _ = w.Write([]byte(o.doc))
+}
+
+// set заменяет документ, его ETag и статус ответа
+func (o *origin) set(doc, etag string, status int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.doc, o.etag, o.status = doc, etag, status
+}
+
+// lastRequest возвращает последний запрос к источнику
+func (o *origin) lastRequest() *http.Request {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.requests[len(o.requests)-1]
+}
+
+// newTestSource загружает конфигурацию с одним источником правил remote,
+// дополнительные поля которого задаются строками extra, и создаёт опрос этого источника
+func newTestSource(t *testing.T, url string, extra ...string) (*source, *config.CachedConfig) {
+	t.Helper()
+	conf := "rule_sources:\n" +
+		"  - name: remote\n" +
+		"    url: \"{url}\"\n"
+	for _, line := range extra {
+		conf += "    " + line + "\n"
+	}
+	conf += "rules:\n" +
+		"  - text: local\n" +
+		"    pattern: 'привет'\n" +
+		"    response: здравствуй\n"
+	path := telegramtest.WriteConfig(t, conf, "{url}", url)
+
+	cache, err := config.LoadConfigWithHash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := cache.Current().Source("remote")
+	s := newSource(*sc, cache, http.DefaultClient, zap.NewNop().Sugar())
+	t.Cleanup(s.cancel)
+	return s, cache
+}
+
+// ruleTexts возвращает описания правил первого бота текущей конфигурации
+func ruleTexts(cache *config.CachedConfig) []string {
+	var texts []string
+	for _, r := range cache.Current().Bots[0].Rules {
+		texts = append(texts, r.Text)
+	}
+	return texts
+}
+
+// wantRules проверяет набор правил первого бота
+func wantRules(t *testing.T, cache *config.CachedConfig, want ...string) {
+	t.Helper()
+	if got := ruleTexts(cache); !slices.Equal(got, want) {
+		t.Fatalf("rules = %q, want %q", got, want)
+	}
+}
+
+func TestFetchNotModified(t *testing.T) {
+	o := &origin{}
+	o.set(docV1, `"v1"`, 0)
+	srv := httptest.NewServer(o)
+	defer srv.Close()
+	s, cache := newTestSource(t, srv.URL)
+
+	updated, rules, err := s.fetch()
+	if err != nil || !updated || rules != 1 {
+		t.Fatalf("first fetch = %v, %d, %v; want updated with 1 rule", updated, rules, err)
+	}
+	wantRules(t, cache, "local", "remote-v1")
+	if h := o.lastRequest().Header.Get("If-None-Match"); h != "" {
+		t.Errorf("first request sent If-None-Match %q", h)
+	}
+
+	// Документ не изменился: условный запрос получает 304, правила остаются
+	updated, _, err = s.fetch()
+	if err != nil || updated {
+		t.Fatalf("second fetch = %v, %v; want not modified", updated, err)
+	}
+	if h := o.lastRequest().Header.Get("If-None-Match"); h != `"v1"` {
+		t.Errorf("second request If-None-Match = %q, want %q", h, `"v1"`)
+	}
+	wantRules(t, cache, "local", "remote-v1")
+
+	// Новый документ с новым ETag применяется
+	o.set(docV2, `"v2"`, 0)
+	updated, _, err = s.fetch()
+	if err != nil || !updated {
+		t.Fatalf("third fetch = %v, %v; want updated", updated, err)
+	}
+	wantRules(t, cache, "local", "remote-v2")
+}
+
+func TestFetchKeepsLastGoodRules(t *testing.T) {
+	o := &origin{}
+	o.set(docV1, `"v1"`, 0)
+	srv := httptest.NewServer(o)
+	defer srv.Close()
+	s, cache := newTestSource(t, srv.URL)
+
+	if _, _, err := s.fetch(); err != nil {
+		t.Fatal(err)
+	}
+	wantRules(t, cache, "local", "remote-v1")
+
+	for _, tc := range []struct {
+		name   string
+		doc    string
+		status int
+		want   string
+	}{
+		{"http error", "", http.StatusInternalServerError, "unexpected status"},
+		{"invalid yaml", "rules: [", 0, "remote"},
+		{"unknown field", "rules:\n  - text: x\n    pattern: x\n    respons: y\n", 0, "respons"},
+		{"invalid regex", "rules:\n  - text: x\n    pattern: '('\n    response: y\n", 0, "pattern"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o.set(tc.doc, `"bad"`, tc.status)
+			updated, _, err := s.fetch()
+			if err == nil || updated {
+				t.Fatalf("fetch = %v, %v; want error", updated, err)
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error %q does not mention %q", err, tc.want)
+			}
+			wantRules(t, cache, "local", "remote-v1")
+		})
+	}
+
+	// После ошибок условный запрос по-прежнему использует ETag последнего применённого документа
+	o.set(docV1, `"v1"`, 0)
+	updated, _, err := s.fetch()
+	if err != nil || updated {
+		t.Fatalf("fetch after errors = %v, %v; want not modified", updated, err)
+	}
+}
+
+func TestFetchChecksum(t *testing.T) {
+	o := &origin{}
+	o.set(docV1, "", 0)
+	srv := httptest.NewServer(o)
+	defer srv.Close()
+
+	sum := sha256.Sum256([]byte(docV1))
+	s, cache := newTestSource(t, srv.URL, "sha256: "+hex.EncodeToString(sum[:]))
+
+	if _, _, err := s.fetch(); err != nil {
+		t.Fatal(err)
+	}
+	wantRules(t, cache, "local", "remote-v1")
+
+	o.set(docV2, "", 0)
+	_, _, err := s.fetch()
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("fetch = %v, want checksum mismatch", err)
+	}
+	wantRules(t, cache, "local", "remote-v1")
+}
+
+func TestFetchSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(key ed25519.PrivateKey, doc string) http.Header {
+		return http.Header{config.DefaultSignatureHeader: {base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(doc)))}}
+	}
+
+	o := &origin{}
+	o.set(docV1, "", 0)
+	o.header = sign(priv, docV1)
+	srv := httptest.NewServer(o)
+	defer srv.Close()
+	s, cache := newTestSource(t, srv.URL, "public_key: "+base64.StdEncoding.EncodeToString(pub))
+
+	if _, _, err := s.fetch(); err != nil {
+		t.Fatal(err)
+	}
+	wantRules(t, cache, "local", "remote-v1")
+
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		want   string
+	}{
+		{"other key", sign(otherPriv, docV2), "signature verification failed"},
+		{"signature of another document", sign(priv, docV1), "signature verification failed"},
+		{"missing signature", nil, "missing or malformed"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o.set(docV2, "", 0)
+			o.mu.Lock()
+			o.header = tc.header
+			o.mu.Unlock()
+
+			_, _, err := s.fetch()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("fetch = %v, want %q", err, tc.want)
+			}
+			wantRules(t, cache, "local", "remote-v1")
+		})
+	}
+}
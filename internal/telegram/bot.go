@@ -1,34 +1,116 @@
 package telegram
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
 	"go.uber.org/zap" // структурированное логирование
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/st-kuptsov/balabol/config"      // конфигурация приложения и правила
-	"github.com/st-kuptsov/balabol/pkg/metrics" // метрики Prometheus
-	tb "gopkg.in/telebot.v3"                    // библиотека для Telegram-бота
+	"github.com/st-kuptsov/balabol/config"          // конфигурация приложения и правила
+	"github.com/st-kuptsov/balabol/pkg/audit"       // журнал аудита ответов
+	"github.com/st-kuptsov/balabol/pkg/deadletter"  // недоставленные сообщения
+	"github.com/st-kuptsov/balabol/pkg/experiments" // результаты A/B-экспериментов
+	"github.com/st-kuptsov/balabol/pkg/i18n"        // выбор языка ответа
+	"github.com/st-kuptsov/balabol/pkg/metrics"     // метрики Prometheus
+	"github.com/st-kuptsov/balabol/pkg/redact"      // скрытие текста сообщений
+	"github.com/st-kuptsov/balabol/pkg/stats"       // статистика чатов
+	"github.com/st-kuptsov/balabol/pkg/tracing"     // трассировка OpenTelemetry
+	"github.com/st-kuptsov/balabol/pkg/warnings"    // счётчики предупреждений
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	tb "gopkg.in/telebot.v3" // библиотека для Telegram-бота
 )
 
+// pollGrace — допустимая задержка сверх таймаута long polling,
+// после которой бот считается не получающим обновления
+const pollGrace = 30 * time.Second
+
+// Bot — Telegram-бот вместе с состоянием получения обновлений
+type Bot struct {
+	*tb.Bot
+	*pollHealth
+	telegram config.TelegramConfig // настройки, с которыми создан бот
+	handlers *inflight             // выполняющиеся обработчики (дожидаемся при Shutdown)
+	out      *outbox               // очередь исходящих сообщений (опустошается при Shutdown)
+}
+
+// Ready сообщает, готов ли бот обрабатывать сообщения:
+// для long polling — был ли недавно успешный getUpdates,
+// для webhook — зарегистрирован ли webhook в Telegram.
+func (b *Bot) Ready() error {
+	if b.telegram.Poller == config.PollerWebhook {
+		if !b.registered.Load() {
+			return errors.New("webhook is not registered")
+		}
+		return nil
+	}
+
+	last := b.LastPoll()
+	if last.IsZero() {
+		return errors.New("no successful poll yet")
+	}
+	if age := time.Since(last); age > b.telegram.LongPolling.Timeout+pollGrace {
+		return fmt.Errorf("last successful poll %s ago", age.Round(time.Second))
+	}
+	return nil
+}
+
+// Deps — общие для всех ботов компоненты приложения
+type Deps struct {
+	Audit       *audit.Log         // журнал аудита ответов
+	Warnings    *warnings.Store    // счётчики предупреждений модерации
+	Stats       *stats.Store       // статистика чатов для /stats и сводки
+	DeadLetters *deadletter.Log    // недоставленные сообщения очереди отправки
+	Experiments *experiments.Store // результаты A/B-экспериментов
+}
+
 // NewBot создаёт и настраивает Telegram-бота.
 //
 // Параметры:
-// - token: токен Telegram-бота
-// - mode: режим работы бота (например, first_last)
-// - cleanFilter: фильтр символов для очистки текста
-// - removeDup: удалять ли повторяющиеся буквы
-// - rulesFn: функция, возвращающая текущий список правил
+// - botConf: настройки, с которыми создаётся бот (имя, токен, способ получения обновлений)
+// - settingsFn: функция, возвращающая актуальные настройки бота при каждом сообщении
+// - deps: общие компоненты приложения (журнал аудита, счётчики предупреждений, статистика, dead letter, эксперименты)
 // - logger: экземпляр структурированного логгера
 //
+// Правила, режим и очистка текста читаются через settingsFn, поэтому применяются без перезапуска.
+// Все сообщения бота отправляются через очередь send_queue.
+//
 // Возвращает:
-// - указатель на tb.Bot
+// - указатель на Bot (бот уже прошёл авторизацию через getMe)
 // - ошибку, если инициализация не удалась
-func NewBot(token, mode, cleanFilter string, removeDup bool, rulesFn func() []config.Rule, logger *zap.SugaredLogger) (*tb.Bot, error) {
-	// Настройки Telegram-бота: токен и длинный polling
+func NewBot(botConf config.BotConfig, settingsFn func() *config.BotConfig, deps Deps, logger *zap.SugaredLogger) (*Bot, error) {
+	name := botConf.Name
+	health := &pollHealth{}
+	// Учёт вариантов экспериментов: доставленные ответы и их исходы
+	outcomes := &outcomeTracker{bot: name, store: deps.Experiments, settings: settingsFn}
+
+	// Поставщик обновлений: long polling или webhook; реплаи и реакции учитываются до обработчиков
+	poller, err := newPoller(botConf.Telegram, health, outcomes.observe, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Настройки Telegram-бота: адрес Bot API, токен, поллер, инструментированный HTTP-клиент и обработчик ошибок.
+	// OnError вызывается без контекста для ошибок поллера и с контекстом для ошибок обработчиков.
+	// Synchronous: обработчики запускает в горутинах middleware inflight, чтобы их можно было дождаться.
 	pref := tb.Settings{
-		Token:  token,
-		Poller: &tb.LongPoller{Timeout: 10 * time.Second},
+		URL:         botConf.Telegram.APIURL,
+		Token:       botConf.Telegram.Token,
+		Poller:      poller,
+		Client:      newAPIClient(name, botConf.Telegram.LongPolling.Timeout),
+		Synchronous: true,
+		OnError: func(err error, c tb.Context) {
+			stage := "handler"
+			if c == nil {
+				stage = "poller"
+			}
+			metrics.ErrorsTotal.WithLabelValues(name, stage).Inc()
+			logger.Errorw("telegram bot error", "stage", stage, "error", err)
+		},
 	}
 
 	// Создаём бота
@@ -37,49 +119,240 @@ func NewBot(token, mode, cleanFilter string, removeDup bool, rulesFn func() []co
 		return nil, err
 	}
 
+	// Очередь исходящих сообщений; лимиты и повторы читаются из актуальных настроек
+	out := newOutbox(name, bot, func() *config.BotConfig {
+		if settings := settingsFn(); settings != nil {
+			return settings
+		}
+		return &botConf
+	}, deps.DeadLetters, logger)
+
+	// Учёт выполняющихся обработчиков для Shutdown; добавляется первым, чтобы охватить остальные middleware
+	handlers := &inflight{}
+	bot.Use(handlers.middleware(pref.OnError))
+	// Один спан на каждое обновление; middleware должно быть добавлено до Handle
+	bot.Use(traceUpdates(name))
+	// Фильтр отправителей: боты, пересылки, списки доступа и флуд (sender_policy)
+	bot.Use(filterSenders(name, settingsFn, newFloodGuard(), logger))
+	// Действия модерации из правил (actions)
+	mod := newModerator(name, deps.Warnings, out)
+
 	// Обработчик входящих текстовых сообщений
 	bot.Handle(tb.OnText, func(c tb.Context) error {
 		start := time.Now() // для метрик времени обработки
 
+		// Задержка между отправкой сообщения и началом обработки
+		metrics.UpdateLag.WithLabelValues(name).Observe(start.Sub(c.Message().Time()).Seconds())
+
+		// Текущие настройки бота; nil — бот удалён из конфигурации и скоро будет остановлен
+		settings := settingsFn()
+		if settings == nil {
+			return nil
+		}
+
+		// Контекст спана обновления; trace_id попадает во все строки лога этого сообщения
+		ctx := updateContext(c)
+		log := logger
+		if fields := tracing.LogFields(ctx); fields != nil {
+			log = logger.With(fields...)
+		}
+		tracer := tracing.Tracer()
+
 		// Очистка текста: убираем лишние символы и дубликаты
-		text := cleanText(strings.TrimSpace(c.Message().Text), cleanFilter, removeDup, logger)
+		_, span := tracer.Start(ctx, "cleanText")
+		raw := strings.TrimSpace(c.Message().Text)
+		text := cleanText(raw, settings.CleanRe(), settings.RemoveDuplicates(), settings.Redact, log)
+		span.End()
 		chatID := strconv.FormatInt(c.Chat().ID, 10)
 
 		// Увеличиваем общий счетчик сообщений
-		metrics.MessagesTotal.WithLabelValues(chatID).Inc()
+		metrics.MessagesTotal.WithLabelValues(name, chatID).Inc()
 
 		// Если текст пустой после очистки — учитываем как "no match"
 		if text == "" {
-			metrics.NoMatchTotal.Inc()
-			metrics.ObserveProcessing(start)
+			metrics.NoMatchTotal.WithLabelValues(name).Inc()
+			metrics.ObserveProcessing(name, start)
 			return nil
 		}
 
-		// Получаем текущие правила через функцию rulesFn
-		rules := rulesFn()
-		hits := MatchRules(text, rules, mode, logger)
+		// Проверяем текст по текущим правилам бота
+		_, span = tracer.Start(ctx, "MatchRules")
+		hits := MatchRules(text, settings.Rules, settings.BotMode, settings.Redact, log)
+		span.SetAttributes(
+			attribute.Int("rules.count", len(settings.Rules)),
+			attribute.Int("hits.count", len(hits)),
+		)
+		span.End()
+
+		// Теневые правила только учитываются: ответ и действия строятся по остальным
+		hits, shadowHits := splitShadow(hits)
+		for _, h := range shadowHits {
+			metrics.RuleShadowHitsTotal.WithLabelValues(name, h.ruleText).Inc()
+			log.Infow("shadow rule matched", "chat_id", chatID, "rule", h.ruleText, "position", h.pos)
+		}
 
 		// Если нет совпадений — учитываем как "no match"
 		if len(hits) == 0 {
-			metrics.NoMatchTotal.Inc()
-			metrics.ObserveProcessing(start)
+			metrics.NoMatchTotal.WithLabelValues(name).Inc()
+			metrics.ObserveProcessing(name, start)
 			return nil
 		}
 
-		// Формируем ответ бота и обновляем метрики
+		// Формируем ответ бота на языке отправителя и обновляем метрики
+		_, span = tracer.Start(ctx, "buildResponse")
+		lang := senderLanguage(c, settings.I18n)
+		choices := pickVariants(c, settings.Rules, hits) // варианты правил с экспериментами
 		replies := make([]string, 0, len(hits))
+		replyLang := "" // язык ответа: первого ответа, вошедшего в текст
 		for _, h := range hits {
-			replies = append(replies, h.resp)
-			metrics.RuleHitsTotal.WithLabelValues(h.ruleText).Inc()
+			resp, respLang := settings.Rules[h.ruleIdx].ResponseFor(lang.Chain)
+			if h.variant != nil {
+				resp, respLang = h.variant.ResponseFor(lang.Chain)
+			}
+			// Правило только с действиями модерации ответа не даёт
+			if resp != "" {
+				replies = append(replies, resp)
+				if replyLang == "" {
+					// Без перевода отправляется response, написанный на языке по умолчанию
+					replyLang = cmp.Or(respLang, settings.I18n.DefaultLanguage)
+				}
+			}
+			metrics.RuleHitsTotal.WithLabelValues(name, h.ruleText).Inc()
 		}
 
 		reply := strings.Join(replies, ". ") // объединяем все ответы в один текст
-		metrics.RepliesTotal.Inc()
-		metrics.ObserveProcessing(start) // фиксируем длительность обработки
+		span.SetAttributes(attribute.String("lang", replyLang), attribute.String("lang.requested", lang.Lang), attribute.String("lang.source", lang.Source))
+		span.End()
+		if reply != "" {
+			metrics.RepliesTotal.WithLabelValues(name, replyLang).Inc()
+		}
+		metrics.ObserveProcessing(name, start) // фиксируем длительность обработки
+
+		// Отправляем ответ пользователю через очередь и дожидаемся доставки: действия модерации
+		// должны выполняться после ответа. Ошибка учитывается здесь, а не в OnError,
+		// чтобы отличать сбои отправки от прочих ошибок обработчика
+		var sendErr error
+		if reply != "" {
+			_, span = tracer.Start(ctx, "telegram.Reply", trace.WithSpanKind(trace.SpanKindClient))
+			var sent *tb.Message
+			sent, sendErr = out.send(c.Chat(), kindReply, reply, replyTo(c))
+			outcomes.expose(sent, choices)
+			if sendErr != nil {
+				span.RecordError(sendErr)
+				span.SetStatus(codes.Error, sendErr.Error())
+				metrics.ErrorsTotal.WithLabelValues(name, "reply").Inc()
+				log.Errorw("reply failed", "chat_id", chatID, "error", redact.Error(settings.Redact, sendErr, raw, text))
+			}
+			span.End()
+		}
+
+		// Действия модерации выполняются после ответа: delete удаляет исходное сообщение.
+		// В режиме dry_run все действия только записываются в лог
+		var actions []audit.Action
+		if todo := collectActions(settings.Rules, hits); len(todo) > 0 {
+			if settings.DryRun {
+				for i := range todo {
+					todo[i].DryRun = true
+				}
+			}
+			_, span = tracer.Start(ctx, "moderation")
+			actions = mod.apply(c, settings, lang, todo, log)
+			span.SetAttributes(attribute.Int("actions.count", len(actions)))
+			span.End()
+		}
+
+		// Статистика чата для /stats и сводки
+		if deps.Stats != nil {
+			deps.Stats.Record(statsMessage(name, c, hits, reply != "" && sendErr == nil && !settings.DryRun), time.Now())
+		}
 
-		// Отправляем ответ пользователю
-		return c.Reply(reply, &tb.SendOptions{ReplyTo: c.Message()})
+		// Запись в журнал аудита: почему и что ответил или сделал бот
+		if deps.Audit.Enabled() && (reply != "" || len(actions) > 0) {
+			rec := auditRecord(name, c, text, append(hits, shadowHits...), reply, cmp.Or(replyLang, lang.Lang), actions, sendErr, settings.Redact)
+			rec.DryRun = settings.DryRun
+			if err := deps.Audit.Write(rec); err != nil {
+				metrics.ErrorsTotal.WithLabelValues(name, "audit").Inc()
+				log.Errorw("audit write failed", "error", err)
+			}
+		}
+		return nil
 	})
 
-	return bot, nil
+	// Команда /lang: какой язык бот выбрал для отправителя (включается i18n.lang_command)
+	bot.Handle("/lang", func(c tb.Context) error {
+		settings := settingsFn()
+		if settings == nil || !settings.I18n.LangCommand {
+			return nil
+		}
+		lang := senderLanguage(c, settings.I18n)
+		source := i18n.SourceName(settings.I18n, lang.Chain, lang.Source)
+		_, err := out.send(c.Chat(), kindCommand, i18n.Text(settings.I18n, lang.Chain, i18n.MsgLangCurrent, lang.Lang, source), replyTo(c))
+		return err
+	})
+
+	// Команда /stats: самые частые правила, активные участники и число ответов в этом чате
+	bot.Handle("/stats", func(c tb.Context) error {
+		settings := settingsFn()
+		if settings == nil || !settings.Stats.Command || deps.Stats == nil {
+			return nil
+		}
+		lang := senderLanguage(c, settings.I18n)
+		sum := deps.Stats.Summary(name, c.Chat().ID, settings.Stats.Top, time.Now())
+		title := i18n.Text(settings.I18n, lang.Chain, i18n.MsgStatsTitle)
+		_, err := out.send(c.Chat(), kindCommand, title+"\n\n"+formatSummary(settings.I18n, lang.Chain, sum), replyTo(c))
+		return err
+	})
+
+	return &Bot{Bot: bot, pollHealth: health, telegram: botConf.Telegram, handlers: handlers, out: out}, nil
+}
+
+// replyTo возвращает параметры ответа на сообщение c. Ответ уходит и тогда,
+// когда исходное сообщение уже удалено (например, действием delete), пока ответ ждал в очереди.
+func replyTo(c tb.Context) *tb.SendOptions {
+	return &tb.SendOptions{ReplyTo: c.Message(), AllowWithoutReply: true}
+}
+
+// senderLanguage выбирает язык ответа для отправителя сообщения
+func senderLanguage(c tb.Context, conf config.I18nConfig) i18n.Choice {
+	var userID int64
+	var code string
+	if sender := c.Sender(); sender != nil {
+		userID, code = sender.ID, sender.LanguageCode
+	}
+	var chatID int64
+	if chat := c.Chat(); chat != nil {
+		chatID = chat.ID
+	}
+	return i18n.Resolve(conf, chatID, userID, code)
+}
+
+// auditRecord собирает запись аудита об ответе на сообщение.
+// Текст сообщения и ошибка отправки скрываются согласно redactMode.
+func auditRecord(bot string, c tb.Context, text string, hits []hit, reply, lang string, actions []audit.Action, sendErr error, redactMode string) audit.Record {
+	rec := audit.Record{
+		Time:      time.Now(),
+		Bot:       bot,
+		ChatID:    c.Chat().ID,
+		MessageID: c.Message().ID,
+		Text:      redact.Text(redactMode, text),
+		Hits:      make([]audit.Hit, 0, len(hits)),
+		Reply:     reply,
+		Lang:      lang,
+		Actions:   actions,
+	}
+	if sender := c.Sender(); sender != nil {
+		rec.UserID = sender.ID
+		rec.Username = sender.Username
+	}
+	for _, h := range hits {
+		ah := audit.Hit{Rule: h.ruleText, Pattern: h.ruleName, Position: h.pos, Shadow: h.shadow}
+		if h.choice != nil {
+			ah.Experiment, ah.Variant = h.choice.Experiment, h.choice.Variant
+		}
+		rec.Hits = append(rec.Hits, ah)
+	}
+	if sendErr != nil {
+		rec.Error = redact.Error(redactMode, sendErr, c.Message().Text, text)
+	}
+	return rec
 }
@@ -0,0 +1,146 @@
+package telegram_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/st-kuptsov/balabol/config"
+	"github.com/st-kuptsov/balabol/internal/telegram"
+	"github.com/st-kuptsov/balabol/internal/telegramtest"
+	"github.com/st-kuptsov/balabol/pkg/metrics"
+	"go.uber.org/zap"
+	tb "gopkg.in/telebot.v3"
+)
+
+// testToken — токен бота в фейковом Bot API
+const testToken = "4242:TEST"
+
+// blockedUser — пользователь из sender_policy.users.block тестовой конфигурации
+const blockedUser = 13
+
+// testConfig — конфигурация тестового бота (см. telegramtest.Server.WriteConfig)
+const testConfig = `secrets: "{secrets}"
+telegram:
+  api_url: "{api_url}"
+  long_polling:
+    timeout: 1s
+sender_policy:
+  users:
+    block: [13]
+rules:
+  - text: "Приветствие"
+    pattern: '(?i)привет'
+    response: "Здравствуй"
+  - text: "Тень"
+    pattern: '(?i)тень'
+    response: "Этого ответа быть не должно"
+    shadow: true
+`
+
+// startBot загружает тестовую конфигурацию с адресом фейкового Bot API,
+// создаёт бота через NewBot и запускает его. Возвращает бота и его настройки.
+func startBot(t *testing.T, api *telegramtest.Server) (*telegram.Bot, *config.BotConfig) {
+	t.Helper()
+	settings := telegramtest.LoadBot(t, api.WriteConfig(t, testToken, testConfig))
+	bot, err := telegram.NewBot(*settings, func() *config.BotConfig { return settings }, telegram.Deps{}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go bot.Start()
+	return bot, settings
+}
+
+// stopBot останавливает бота, дожидаясь начатых обработчиков
+func stopBot(t *testing.T, bot *telegram.Bot) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bot.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBotRepliesToMatchingMessage(t *testing.T) {
+	api := telegramtest.NewServer()
+	defer api.Close()
+	fake := api.Bot(testToken)
+
+	bot, _ := startBot(t, api)
+	defer stopBot(t, bot)
+
+	msg := fake.PushText(telegramtest.Group(-100), telegramtest.User(7), "Привет всем")
+	calls, err := fake.WaitCalls("sendMessage", 1, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := calls[0].Params["chat_id"]; got != "-100" {
+		t.Errorf("chat_id = %q, want -100", got)
+	}
+	sent := fake.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d message(s), want 1", len(sent))
+	}
+	if sent[0].Text != "Здравствуй" {
+		t.Errorf("reply text = %q, want %q", sent[0].Text, "Здравствуй")
+	}
+	if sent[0].ReplyTo == nil || sent[0].ReplyTo.ID != msg.ID {
+		t.Errorf("reply is not a reply to message %d: reply_to = %+v", msg.ID, sent[0].ReplyTo)
+	}
+}
+
+func TestBotSendsNothingForDroppedAndShadowMessages(t *testing.T) {
+	api := telegramtest.NewServer()
+	defer api.Close()
+	fake := api.Bot(testToken)
+
+	bot, settings := startBot(t, api)
+	defer stopBot(t, bot)
+	blocked := metrics.DroppedTotal.WithLabelValues(settings.Name, "user_blocked")
+	fromBot := metrics.DroppedTotal.WithLabelValues(settings.Name, "bot")
+	shadowHits := metrics.RuleShadowHitsTotal.WithLabelValues(settings.Name, "Тень")
+	blockedBefore, fromBotBefore, shadowBefore := testutil.ToFloat64(blocked), testutil.ToFloat64(fromBot), testutil.ToFloat64(shadowHits)
+
+	chat := telegramtest.Group(-200)
+	otherBot := &tb.User{ID: 99, IsBot: true, FirstName: "Other Bot"}
+	fake.PushText(chat, telegramtest.User(blockedUser), "привет")    // sender_policy.users.block
+	fake.PushText(chat, otherBot, "привет")                          // sender_policy.ignore_bots
+	fake.PushText(chat, telegramtest.User(7), "тень")                // только теневое правило
+	last := fake.PushText(chat, telegramtest.User(7), "привет тень") // обычное и теневое правило
+
+	if _, err := fake.WaitCalls("sendMessage", 1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// Отброшенные и теневые сообщения ничего не отправляют, поэтому их обработку видно только по метрикам
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(blocked)-blockedBefore < 1 || testutil.ToFloat64(fromBot)-fromBotBefore < 1 ||
+		testutil.ToFloat64(shadowHits)-shadowBefore < 2 {
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sent := fake.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d message(s), want only the reply to the last message", len(sent))
+	}
+	if sent[0].ReplyTo == nil || sent[0].ReplyTo.ID != last.ID {
+		t.Errorf("reply_to = %+v, want message %d", sent[0].ReplyTo, last.ID)
+	}
+	if sent[0].Text != "Здравствуй" {
+		t.Errorf("reply text = %q, want the response of the live rule only", sent[0].Text)
+	}
+
+	if got := testutil.ToFloat64(blocked) - blockedBefore; got != 1 {
+		t.Errorf("user_blocked drops = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(fromBot) - fromBotBefore; got != 1 {
+		t.Errorf("bot drops = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(shadowHits) - shadowBefore; got != 2 {
+		t.Errorf("shadow hits = %v, want 2", got)
+	}
+}
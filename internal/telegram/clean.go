@@ -2,33 +2,50 @@ package telegram
 
 import (
 	"go.uber.org/zap" // структурированное логирование
+	"go.uber.org/zap/zapcore"
 	"regexp"
 	"strings"
 	"unicode"
+
+	"github.com/st-kuptsov/balabol/pkg/redact" // скрытие текста сообщений в логах
 )
 
+// spaceRe находит последовательности пробельных символов
+var spaceRe = regexp.MustCompile(`\s+`)
+
 // cleanText очищает входной текст перед обработкой ботом.
 // Параметры:
 // - input: исходный текст
-// - cleanFilter: регулярное выражение для удаления нежелательных символов
+// - cleanRe: скомпилированное регулярное выражение для удаления нежелательных символов
 // - removeDup: флаг удаления повторяющихся букв и дубликатов слов
+// - redactMode: режим скрытия текста в отладочных логах (log_settings.redact)
 // - logger: логгер для отладки
 //
 // Функция возвращает "очищенный" текст.
-func cleanText(input, cleanFilter string, removeDup bool, logger *zap.SugaredLogger) string {
-	logger.Debugw("cleanText Input", "input", input)
-	for i, r := range input {
-		logger.Debugw("Character", "index", i, "unicode", string(r), "code", r)
+func cleanText(input string, cleanRe *regexp.Regexp, removeDup bool, redactMode string, logger *zap.SugaredLogger) string {
+	debug := logger.Desugar().Core().Enabled(zapcore.DebugLevel)
+
+	if debug {
+		logger.Debugw("cleanText Input", "input", redact.Text(redactMode, input))
+	}
+	// Посимвольный вывод раскрывает текст целиком и дорог, поэтому только при debug и без скрытия
+	if debug && !redact.Enabled(redactMode) {
+		for i, r := range input {
+			logger.Debugw("Character", "index", i, "unicode", string(r), "code", r)
+		}
 	}
 
-	// Удаляем символы, не подходящие под cleanFilter
-	re := regexp.MustCompile(cleanFilter)
-	cleaned := re.ReplaceAllString(input, "")
-	logger.Debugw("After clean_filter", "result", cleaned)
+	// Удаляем символы, не подходящие под clean_filter
+	cleaned := cleanRe.ReplaceAllString(input, "")
+	if debug {
+		logger.Debugw("After clean_filter", "result", redact.Text(redactMode, cleaned))
+	}
 
 	// Приведение текста к нижнему регистру
 	cleaned = strings.ToLower(cleaned)
-	logger.Debugw("After ToLower", "result", cleaned)
+	if debug {
+		logger.Debugw("After ToLower", "result", redact.Text(redactMode, cleaned))
+	}
 
 	// Если нужно удалять дубликаты букв и слов
 	if removeDup {
@@ -61,13 +78,16 @@ func cleanText(input, cleanFilter string, removeDup bool, logger *zap.SugaredLog
 	}
 
 	// Нормализация пробелов: заменяем несколько пробелов на один
-	reSpace := regexp.MustCompile(`\s+`)
-	cleaned = reSpace.ReplaceAllString(cleaned, " ")
-	logger.Debugw("After normalize spaces", "result", cleaned)
+	cleaned = spaceRe.ReplaceAllString(cleaned, " ")
+	if debug {
+		logger.Debugw("After normalize spaces", "result", redact.Text(redactMode, cleaned))
+	}
 
 	// Убираем пробелы в начале и конце
 	cleaned = strings.TrimSpace(cleaned)
-	logger.Debugw("Final", "result", cleaned)
+	if debug {
+		logger.Debugw("Final", "result", redact.Text(redactMode, cleaned))
+	}
 
 	return cleaned
 }
@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	tb "gopkg.in/telebot.v3" // библиотека для Telegram-бота
+)
+
+// inflight считает выполняющиеся обработчики, чтобы при остановке бота дождаться их завершения.
+// Бот создаётся с Synchronous: обработчик запускается в горутине этим middleware,
+// поэтому счётчик увеличивается в цикле обновлений и не может отстать от ожидания.
+type inflight struct {
+	wg sync.WaitGroup
+}
+
+// middleware запускает обработчик в отдельной горутине и учитывает её.
+// Ошибка обработчика передаётся в onError, как это делает telebot для асинхронных обработчиков.
+func (f *inflight) middleware(onError func(error, tb.Context)) tb.MiddlewareFunc {
+	return func(next tb.HandlerFunc) tb.HandlerFunc {
+		return func(c tb.Context) error {
+			f.wg.Add(1)
+			go func() {
+				defer f.wg.Done()
+				if err := next(c); err != nil {
+					onError(err, c)
+				}
+			}()
+			return nil
+		}
+	}
+}
+
+// wait дожидается завершения всех обработчиков или отмены ctx
+func (f *inflight) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("handlers still running: %w", ctx.Err())
+	}
+}
+
+// Shutdown останавливает получение обновлений, дожидается завершения начатых обработчиков
+// и отправки сообщений из очереди. Остановка telebot отменяет выполняющиеся запросы к Bot API,
+// поэтому ожидающий getUpdates прерывается сразу, а прерванную отправку очередь повторяет.
+// Если ctx отменён раньше, неотправленные сообщения записываются в dead letter.
+// Возвращает ошибку, если бот не остановился до отмены ctx.
+func (b *Bot) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		b.Bot.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		return errors.Join(fmt.Errorf("poller did not stop: %w", ctx.Err()), b.out.close(ctx))
+	}
+	// Обработчики ждут доставки своих ответов, поэтому очередь закрывается после них;
+	// если они не успели, close прерывает отправку, и обработчики получают ошибку
+	return errors.Join(b.handlers.wait(ctx), b.out.close(ctx))
+}
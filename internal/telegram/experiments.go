@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"slices"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config"          // настройки экспериментов
+	"github.com/st-kuptsov/balabol/pkg/experiments" // результаты экспериментов
+	"github.com/st-kuptsov/balabol/pkg/metrics"     // метрики Prometheus
+	tb "gopkg.in/telebot.v3"                        // библиотека для Telegram-бота
+)
+
+// outcomeTracker учитывает ответы бота с вариантами экспериментов
+// и засчитывает им реплаи и реакции участников
+type outcomeTracker struct {
+	bot      string
+	store    *experiments.Store       // nil — эксперименты не учитываются
+	settings func() *config.BotConfig // актуальные настройки бота (окно исхода, реакции)
+}
+
+// pickVariants выбирает варианты экспериментов для правил hits и возвращает их для учёта доставки.
+// Вариант закрепляется за чатом или отправителем (experiment.sticky).
+// Правило может дать несколько совпадений (режим all, начало и конец в first_last),
+// но вариант выбирается один раз на правило, а каждая пара эксперимент/вариант возвращается один раз,
+// чтобы одно сообщение не учитывалось в результатах эксперимента дважды.
+func pickVariants(c tb.Context, rules []config.Rule, hits []hit) []experiments.Choice {
+	var userID int64
+	if sender := c.Sender(); sender != nil {
+		userID = sender.ID
+	}
+	var choices []experiments.Choice
+	picked := make(map[int]*config.Variant) // выбранные варианты по индексу правила
+	for i := range hits {
+		exp := rules[hits[i].ruleIdx].Experiment
+		if exp == nil {
+			continue
+		}
+		v, ok := picked[hits[i].ruleIdx]
+		if !ok {
+			v = exp.Pick(c.Chat().ID, userID)
+			picked[hits[i].ruleIdx] = v
+		}
+		choice := experiments.Choice{Experiment: exp.Name, Variant: v.Name}
+		hits[i].variant = v
+		hits[i].choice = &choice
+		if !slices.Contains(choices, choice) {
+			choices = append(choices, choice)
+		}
+	}
+	return choices
+}
+
+// expose учитывает доставленный ответ sent, собранный из вариантов choices
+func (t *outcomeTracker) expose(sent *tb.Message, choices []experiments.Choice) {
+	if t.store == nil || sent == nil || len(choices) == 0 {
+		return
+	}
+	t.store.Expose(t.bot, sent.Chat.ID, sent.ID, choices, time.Now())
+	for _, c := range choices {
+		metrics.ExperimentExposuresTotal.WithLabelValues(t.bot, c.Experiment, c.Variant).Inc()
+	}
+}
+
+// observe проверяет, не является ли обновление реплаем или реакцией на ответ бота с экспериментом.
+// Вызывается для каждого обновления до обработчиков, поэтому учитываются реплаи любого вида
+// (текст, стикер, медиа); реплаи и реакции ботов не учитываются.
+func (t *outcomeTracker) observe(upd *tb.Update) {
+	if t.store == nil {
+		return
+	}
+	settings := t.settings()
+	if settings == nil {
+		return
+	}
+
+	var (
+		chatID    int64
+		messageID int
+		outcome   string
+		at        time.Time
+	)
+	switch {
+	case upd.Message != nil && upd.Message.ReplyTo != nil:
+		m := upd.Message
+		if m.Sender != nil && m.Sender.IsBot {
+			return
+		}
+		chatID, messageID, outcome, at = m.Chat.ID, m.ReplyTo.ID, experiments.OutcomeReply, m.Time()
+	case upd.MessageReaction != nil && settings.Experiments.Reactions:
+		r := upd.MessageReaction
+		// Снятие реакции исходом не считается
+		if len(r.NewReaction) == 0 || (r.User != nil && r.User.IsBot) {
+			return
+		}
+		chatID, messageID, outcome, at = r.Chat.ID, r.MessageID, experiments.OutcomeReaction, r.Time()
+	default:
+		return
+	}
+
+	for _, c := range t.store.Outcome(t.bot, chatID, messageID, outcome, at, settings.Experiments.Window) {
+		metrics.ExperimentOutcomesTotal.WithLabelValues(t.bot, c.Experiment, c.Variant, outcome).Inc()
+	}
+}
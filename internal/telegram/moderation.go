@@ -0,0 +1,296 @@
+package telegram
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config"       // действия модерации
+	"github.com/st-kuptsov/balabol/pkg/audit"    // запись действий в журнал аудита
+	"github.com/st-kuptsov/balabol/pkg/i18n"     // текст предупреждения
+	"github.com/st-kuptsov/balabol/pkg/metrics"  // метрики Prometheus
+	"github.com/st-kuptsov/balabol/pkg/warnings" // счётчики предупреждений
+	"go.uber.org/zap"                            // структурированное логирование
+	tb "gopkg.in/telebot.v3"                     // библиотека для Telegram-бота
+)
+
+// Результаты действий модерации (лейбл result метрики bot_moderation_actions_total)
+const (
+	resultDone        = "done"        // действие выполнено
+	resultDryRun      = "dry_run"     // dry_run: действие только записано в лог
+	resultNoRights    = "no_rights"   // у бота нет нужных прав администратора
+	resultFailed      = "failed"      // ошибка Bot API
+	resultUnsupported = "unsupported" // действие невозможно в этом чате (личные сообщения, нет отправителя)
+)
+
+// rightsTTL — сколько кэшируются права бота в чате
+const rightsTTL = time.Minute
+
+// actionOrder — порядок выполнения действий: удаление последним,
+// чтобы закрепление и ответ успели сослаться на сообщение
+var actionOrder = []string{config.ActionPin, config.ActionWarn, config.ActionRestrict, config.ActionBan, config.ActionDelete}
+
+// moderator выполняет действия модерации одного бота
+type moderator struct {
+	bot   string          // имя бота для метрик и ключей счётчиков
+	store *warnings.Store // счётчики предупреждений (nil — warn только в лог)
+	out   *outbox         // очередь для текста предупреждения
+
+	mu     sync.Mutex
+	rights map[int64]cachedRights // права бота по чатам
+}
+
+// cachedRights — права бота в чате на момент fetched
+type cachedRights struct {
+	member  *tb.ChatMember
+	fetched time.Time
+}
+
+// newModerator создаёт исполнителя действий модерации
+func newModerator(bot string, store *warnings.Store, out *outbox) *moderator {
+	return &moderator{bot: bot, store: store, out: out, rights: make(map[int64]cachedRights)}
+}
+
+// collectActions собирает действия сработавших правил без повторов, в порядке actionOrder.
+// Из одинаковых действий restrict и ban берётся самый долгий срок (0 — бессрочно);
+// настоящее действие важнее dry_run.
+func collectActions(rules []config.Rule, hits []hit) []config.Action {
+	byType := make(map[string]config.Action)
+	for _, h := range hits {
+		for _, a := range rules[h.ruleIdx].Actions {
+			prev, ok := byType[a.Type]
+			if !ok {
+				byType[a.Type] = a
+				continue
+			}
+			if prev.Duration != 0 && (a.Duration == 0 || a.Duration > prev.Duration) {
+				prev.Duration = a.Duration
+			}
+			prev.DryRun = prev.DryRun && a.DryRun
+			byType[a.Type] = prev
+		}
+	}
+
+	actions := make([]config.Action, 0, len(byType))
+	for _, t := range actionOrder {
+		if a, ok := byType[t]; ok {
+			actions = append(actions, a)
+		}
+	}
+	return actions
+}
+
+// apply выполняет действия над сообщением c.
+// Ошибки и нехватка прав учитываются в bot_errors_total{stage="moderation"} и не прерывают остальные действия.
+// Возвращает выполненные действия для журнала аудита.
+func (m *moderator) apply(c tb.Context, settings *config.BotConfig, lang i18n.Choice, actions []config.Action, log *zap.SugaredLogger) []audit.Action {
+	var done []audit.Action
+	for _, a := range actions {
+		if a.Type == config.ActionWarn {
+			done = append(done, m.warn(c, settings, lang, a, log)...)
+			continue
+		}
+		done = append(done, m.run(c, a, "", log))
+	}
+	return done
+}
+
+// warn выдаёт предупреждение: увеличивает счётчик, отвечает текстом предупреждения
+// и при достижении ступени эскалации ограничивает или исключает отправителя
+func (m *moderator) warn(c tb.Context, settings *config.BotConfig, lang i18n.Choice, a config.Action, log *zap.SugaredLogger) []audit.Action {
+	sender := c.Sender()
+	if !isGroup(c.Chat()) || sender == nil {
+		return []audit.Action{m.record(a, resultUnsupported, "")}
+	}
+	conf := settings.Moderation
+	chatID := c.Chat().ID
+
+	// В dry_run счётчик не меняется: показываем, каким было бы предупреждение
+	var count int
+	if a.DryRun || m.store == nil {
+		count = 1
+		if m.store != nil {
+			count = m.store.Count(m.bot, chatID, sender.ID) + 1
+		}
+	} else {
+		var err error
+		count, err = m.store.Add(m.bot, chatID, sender.ID, conf.WarningsTTL, time.Now())
+		if err != nil {
+			metrics.ErrorsTotal.WithLabelValues(m.bot, "moderation").Inc()
+			log.Errorw("warnings save failed", "error", err)
+		}
+	}
+	max := conf.MaxWarnings()
+	detail := strconv.Itoa(count)
+	if max > 0 {
+		detail = fmt.Sprintf("%d/%d", count, max)
+	}
+
+	result := resultDone
+	if a.DryRun {
+		result = resultDryRun
+		log.Infow("moderation action (dry run)", "action", a.Type, "user_id", strconv.FormatInt(sender.ID, 10), "warnings", detail)
+	} else {
+		text := i18n.Text(settings.I18n, lang.Chain, i18n.MsgWarnIssued, mention(sender), count)
+		if max > 0 {
+			text = i18n.Text(settings.I18n, lang.Chain, i18n.MsgWarnIssuedOf, mention(sender), count, max)
+		}
+		if _, err := m.out.send(c.Chat(), kindWarn, text, replyTo(c)); err != nil {
+			result = resultFailed
+			metrics.ErrorsTotal.WithLabelValues(m.bot, "moderation").Inc()
+			log.Errorw("moderation action failed", "action", a.Type, "error", err)
+		}
+	}
+	done := []audit.Action{m.record(a, result, detail)}
+
+	// Эскалация: ограничение или исключение при достижении порога
+	step, last := conf.Step(count)
+	if step == nil {
+		return done
+	}
+	escalated := config.Action{Type: step.Type, Duration: step.Duration, DryRun: step.DryRun || a.DryRun}
+	rec := m.run(c, escalated, "escalation "+detail, log)
+	done = append(done, rec)
+	if last && rec.Result == resultDone && m.store != nil {
+		if err := m.store.Reset(m.bot, chatID, sender.ID); err != nil {
+			metrics.ErrorsTotal.WithLabelValues(m.bot, "moderation").Inc()
+			log.Errorw("warnings save failed", "error", err)
+		}
+	}
+	return done
+}
+
+// run проверяет права и выполняет действие delete, restrict, ban или pin.
+// detail дополняет запись аудита (например, причину эскалации).
+func (m *moderator) run(c tb.Context, a config.Action, detail string, log *zap.SugaredLogger) audit.Action {
+	chat, sender, msg := c.Chat(), c.Sender(), c.Message()
+	if detail == "" && a.Duration > 0 {
+		detail = a.Duration.String()
+	}
+	needsSender := a.Type == config.ActionRestrict || a.Type == config.ActionBan
+	if !isGroup(chat) || (needsSender && sender == nil) {
+		return m.record(a, resultUnsupported, detail)
+	}
+
+	// Права проверяются и в dry_run: так видно, что действие не сработает
+	ok, err := m.canDo(c.Bot(), chat, a.Type)
+	if err != nil {
+		metrics.ErrorsTotal.WithLabelValues(m.bot, "moderation").Inc()
+		log.Errorw("moderation rights check failed", "action", a.Type, "error", err)
+		return m.record(a, resultFailed, detail)
+	}
+	if !ok {
+		metrics.ErrorsTotal.WithLabelValues(m.bot, "moderation").Inc()
+		log.Warnw("missing admin rights for moderation action", "action", a.Type, "chat_id", strconv.FormatInt(chat.ID, 10))
+		return m.record(a, resultNoRights, detail)
+	}
+
+	if a.DryRun {
+		log.Infow("moderation action (dry run)", "action", a.Type, "detail", detail)
+		return m.record(a, resultDryRun, detail)
+	}
+
+	switch a.Type {
+	case config.ActionDelete:
+		err = c.Bot().Delete(msg)
+	case config.ActionPin:
+		err = c.Bot().Pin(msg, tb.Silent)
+	case config.ActionRestrict:
+		err = c.Bot().Restrict(chat, &tb.ChatMember{User: sender, Rights: tb.NoRights(), RestrictedUntil: until(a.Duration)})
+	case config.ActionBan:
+		err = c.Bot().Ban(chat, &tb.ChatMember{User: sender, RestrictedUntil: until(a.Duration)})
+	}
+	if err != nil {
+		// Права могли измениться: перечитаем их при следующем действии
+		m.forget(chat.ID)
+		metrics.ErrorsTotal.WithLabelValues(m.bot, "moderation").Inc()
+		log.Errorw("moderation action failed", "action", a.Type, "error", err)
+		return m.record(a, resultFailed, detail)
+	}
+	log.Infow("moderation action", "action", a.Type, "detail", detail)
+	return m.record(a, resultDone, detail)
+}
+
+// record учитывает результат действия в метриках и возвращает запись аудита
+func (m *moderator) record(a config.Action, result, detail string) audit.Action {
+	metrics.ModerationActionsTotal.WithLabelValues(m.bot, a.Type, result).Inc()
+	return audit.Action{Type: a.Type, Result: result, Detail: detail}
+}
+
+// canDo проверяет, есть ли у бота право на действие в чате.
+// Создатель чата может всё, администратор — то, что разрешено его правами.
+func (m *moderator) canDo(bot *tb.Bot, chat *tb.Chat, action string) (bool, error) {
+	member, err := m.member(bot, chat)
+	if err != nil {
+		return false, err
+	}
+	switch member.Role {
+	case tb.Creator:
+		return true, nil
+	case tb.Administrator:
+	default:
+		return false, nil
+	}
+
+	switch action {
+	case config.ActionDelete:
+		return member.CanDeleteMessages, nil
+	case config.ActionPin:
+		// В супергруппах право на закрепление задаётся явно, в обычных группах есть у всех админов
+		return member.CanPinMessages || chat.Type == tb.ChatGroup, nil
+	case config.ActionRestrict, config.ActionBan:
+		return member.CanRestrictMembers, nil
+	}
+	return true, nil
+}
+
+// member возвращает права бота в чате из кэша или из getChatMember
+func (m *moderator) member(bot *tb.Bot, chat *tb.Chat) (*tb.ChatMember, error) {
+	m.mu.Lock()
+	cached, ok := m.rights[chat.ID]
+	m.mu.Unlock()
+	if ok && time.Since(cached.fetched) < rightsTTL {
+		return cached.member, nil
+	}
+
+	member, err := bot.ChatMemberOf(chat, bot.Me)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.rights[chat.ID] = cachedRights{member: member, fetched: time.Now()}
+	m.mu.Unlock()
+	return member, nil
+}
+
+// forget удаляет права чата из кэша
+func (m *moderator) forget(chatID int64) {
+	m.mu.Lock()
+	delete(m.rights, chatID)
+	m.mu.Unlock()
+}
+
+// isGroup сообщает, возможна ли модерация в чате
+func isGroup(chat *tb.Chat) bool {
+	return chat != nil && (chat.Type == tb.ChatGroup || chat.Type == tb.ChatSuperGroup)
+}
+
+// until переводит срок действия в until_date Bot API (0 — бессрочно)
+func until(d time.Duration) int64 {
+	if d <= 0 {
+		return tb.Forever()
+	}
+	return time.Now().Add(d).Unix()
+}
+
+// mention возвращает обращение к пользователю для текста предупреждения
+func mention(u *tb.User) string {
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	if u.LastName != "" {
+		return u.FirstName + " " + u.LastName
+	}
+	return u.FirstName
+}
@@ -0,0 +1,487 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config"         // настройки очереди
+	"github.com/st-kuptsov/balabol/pkg/deadletter" // журнал недоставленных сообщений
+	"github.com/st-kuptsov/balabol/pkg/metrics"    // метрики Prometheus
+	"go.uber.org/zap"                              // структурированное логирование
+	tb "gopkg.in/telebot.v3"                       // библиотека для Telegram-бота
+)
+
+// Виды исходящих сообщений (поле kind журнала dead letter)
+const (
+	kindReply   = "reply"   // ответ на сообщение по правилам
+	kindWarn    = "warn"    // текст предупреждения модерации
+	kindCommand = "command" // ответ на команду /lang или /stats
+	kindDigest  = "digest"  // сводка статистики
+)
+
+// Классы ошибок отправки
+const (
+	sendRateLimited = iota // 429: повтор через retry_after
+	sendTransient          // сеть или 5xx: повтор с паузой, учитывается предохранителем
+	sendRejected           // прочие 4xx: повтор не поможет
+)
+
+// probeWait — как часто остальные чаты проверяют предохранитель, пока идёт пробная отправка
+const probeWait = time.Second
+
+var (
+	errShutdown  = errors.New("send queue stopped")
+	errQueueFull = errors.New("send queue is full")
+	errExpired   = errors.New("message expired in send queue")
+
+	// apiErrorCode — код ошибки в тексте "telegram: <описание> (<код>)" у ошибок, которые telebot не распознал
+	apiErrorCode = regexp.MustCompile(`\((\d{3})\)$`)
+)
+
+// outMessage — сообщение в очереди
+type outMessage struct {
+	chat     *tb.Chat
+	kind     string
+	text     string
+	opts     *tb.SendOptions
+	queued   time.Time   // когда поставлено в очередь
+	attempts int         // сделано попыток отправки
+	lastErr  error       // последняя ошибка отправки
+	sent     *tb.Message // доставленное сообщение
+	result   chan error  // итог отправки (буфер на одно значение)
+}
+
+// chatQueue — очередь сообщений одного чата. Сообщения отправляет по порядку одна горутина.
+type chatQueue struct {
+	items []*outMessage
+	next  time.Time // раньше этого момента в чат не отправляем: интервал чата или retry_after
+}
+
+// outbox — очередь исходящих сообщений бота.
+// Сообщения в один чат отправляются по порядку, с интервалом send_queue.chat_rate (group_rate для групп),
+// все чаты вместе — не чаще send_queue.global_rate в секунду.
+// Ответ 429 откладывает чат на retry_after, сбои сети и 5xx повторяются с растущей паузой,
+// а при серии сбоев предохранитель приостанавливает отправку во все чаты.
+// Недоставленные сообщения записываются в журнал dead letter.
+type outbox struct {
+	bot    string
+	api    *tb.Bot
+	conf   func() *config.BotConfig // актуальные настройки бота (send_queue, dry_run)
+	dead   *deadletter.Log
+	logger *zap.SugaredLogger
+
+	mu       sync.Mutex
+	chats    map[int64]*chatQueue
+	depth    int       // сообщений в очереди и в отправке
+	nextSend time.Time // глобальный лимит: раньше этого момента не отправляем
+	closed   bool      // новые сообщения не принимаются, очередь дорабатывает
+	stopped  bool      // ожидания прерваны, оставшиеся сообщения записаны в dead letter
+	stop     chan struct{}
+	workers  sync.WaitGroup
+	breaker  breaker
+}
+
+// newOutbox создаёт очередь исходящих сообщений бота api
+func newOutbox(bot string, api *tb.Bot, conf func() *config.BotConfig, dead *deadletter.Log, logger *zap.SugaredLogger) *outbox {
+	return &outbox{
+		bot:    bot,
+		api:    api,
+		conf:   conf,
+		dead:   dead,
+		logger: logger,
+		chats:  make(map[int64]*chatQueue),
+		stop:   make(chan struct{}),
+	}
+}
+
+// send ставит сообщение в очередь и дожидается итога: доставки или записи в dead letter.
+// Ожидание ограничено send_queue.max_age и остановкой бота.
+// В режиме dry_run сообщение только записывается в лог и считается доставленным.
+//
+// Параметры:
+// - chat: получатель
+// - kind: вид сообщения для журнала dead letter (kindReply, kindWarn, ...)
+// - text: текст сообщения
+// - opts: параметры отправки (ответ на сообщение и т.п.), может быть nil
+//
+// Возвращает:
+// - доставленное сообщение (nil в режиме dry_run)
+// - nil, если сообщение доставлено, иначе последнюю ошибку Bot API или причину отказа
+func (o *outbox) send(chat *tb.Chat, kind, text string, opts *tb.SendOptions) (*tb.Message, error) {
+	if o.conf().DryRun {
+		o.logger.Infow("message not sent (dry run)", "chat_id", strconv.FormatInt(chat.ID, 10), "kind", kind)
+		return nil, nil
+	}
+	if opts == nil {
+		opts = &tb.SendOptions{}
+	}
+	m := &outMessage{chat: chat, kind: kind, text: text, opts: opts, queued: time.Now(), result: make(chan error, 1)}
+	o.enqueue(m)
+	if err := <-m.result; err != nil {
+		return nil, err
+	}
+	return m.sent, nil
+}
+
+// enqueue добавляет сообщение в очередь чата и при необходимости запускает её обработку
+func (o *outbox) enqueue(m *outMessage) {
+	conf := o.conf().SendQueue
+
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		o.fail(m, deadletter.ReasonShutdown, errShutdown)
+		return
+	}
+	if o.depth >= conf.Size {
+		o.mu.Unlock()
+		o.fail(m, deadletter.ReasonQueueFull, errQueueFull)
+		return
+	}
+	q, running := o.chats[m.chat.ID]
+	if !running {
+		q = &chatQueue{}
+		o.chats[m.chat.ID] = q
+		o.workers.Add(1)
+	}
+	q.items = append(q.items, m)
+	o.depth++
+	metrics.SendQueueDepth.WithLabelValues(o.bot).Set(float64(o.depth))
+	o.mu.Unlock()
+
+	if !running {
+		go o.drain(m.chat.ID, q)
+	}
+}
+
+// drain отправляет сообщения чата по порядку. Горутина завершается, когда очередь чата пуста
+// и интервал после последней отправки истёк: так следующее сообщение не нарушит лимит чата.
+func (o *outbox) drain(chatID int64, q *chatQueue) {
+	defer o.workers.Done()
+	for {
+		o.mu.Lock()
+		now := time.Now()
+		if len(q.items) == 0 && (o.closed || !now.Before(q.next)) {
+			delete(o.chats, chatID)
+			o.mu.Unlock()
+			return
+		}
+		if wait := q.next.Sub(now); wait > 0 {
+			o.mu.Unlock()
+			o.sleep(wait)
+			continue
+		}
+		m := q.items[0]
+		q.items = q.items[1:]
+		o.mu.Unlock()
+
+		o.attempt(q, m)
+	}
+}
+
+// attempt делает одну попытку отправить сообщение m и решает его судьбу:
+// доставлено, вернуть в начало очереди чата для повтора или записать в dead letter
+func (o *outbox) attempt(q *chatQueue, m *outMessage) {
+	conf := o.conf().SendQueue
+	now := time.Now()
+
+	if now.Sub(m.queued) > conf.MaxAge {
+		o.finish(m, deadletter.ReasonExpired, errors.Join(errExpired, m.lastErr))
+		return
+	}
+
+	// Предохранитель разомкнут: ждём, не расходуя попытку
+	if wait := o.breaker.allow(now, conf.Breaker); wait > 0 {
+		o.retry(q, m, now.Add(wait))
+		return
+	}
+
+	// Глобальный лимит: занимаем ближайшее свободное окно
+	o.mu.Lock()
+	slot := now
+	if o.nextSend.After(slot) {
+		slot = o.nextSend
+	}
+	o.nextSend = slot.Add(conf.GlobalInterval())
+	o.mu.Unlock()
+	if !o.sleep(time.Until(slot)) {
+		o.retry(q, m, slot)
+		return
+	}
+
+	// Лимит чата зависит от его вида; ID групп и каналов отрицательные,
+	// так что тип чата не нужен (у сводки он неизвестен)
+	sent, err := o.api.Send(m.chat, m.text, m.opts)
+	m.attempts++
+	now = time.Now()
+	if err == nil {
+		o.reachable()
+		metrics.SendQueueWait.WithLabelValues(o.bot).Observe(now.Sub(m.queued).Seconds())
+		o.setNext(q, now.Add(conf.ChatInterval(m.chat.ID < 0)))
+		o.release()
+		m.sent = sent
+		m.result <- nil
+		return
+	}
+	m.lastErr = err
+
+	class, retryAfter := classifySend(err)
+	var wait time.Duration
+	switch class {
+	case sendRejected:
+		// Telegram ответил — API доступен, но это сообщение отправить нельзя
+		o.reachable()
+		o.setNext(q, now.Add(conf.ChatInterval(m.chat.ID < 0)))
+		o.finish(m, deadletter.ReasonRejected, err)
+		return
+	case sendRateLimited:
+		o.reachable()
+		wait = retryAfter
+		if wait <= 0 {
+			wait = backoff(conf, m.attempts)
+		}
+		if m.attempts < conf.MaxAttempts {
+			metrics.SendRetriesTotal.WithLabelValues(o.bot, "rate_limited").Inc()
+		}
+	case sendTransient:
+		if o.breaker.failure(now, conf.Breaker) {
+			metrics.SendCircuitOpen.WithLabelValues(o.bot).Set(1)
+			o.logger.Warnw("send circuit breaker opened", "cooldown", conf.Breaker.Cooldown.String(), "error", err)
+		}
+		wait = backoff(conf, m.attempts)
+		if m.attempts < conf.MaxAttempts {
+			metrics.SendRetriesTotal.WithLabelValues(o.bot, "transient").Inc()
+		}
+	}
+
+	if m.attempts >= conf.MaxAttempts {
+		o.setNext(q, now.Add(wait))
+		o.finish(m, deadletter.ReasonExhausted, err)
+		return
+	}
+	o.logger.Debugw("send retry scheduled", "chat_id", strconv.FormatInt(m.chat.ID, 10), "attempt", m.attempts, "wait", wait.String(), "error", err)
+	o.retry(q, m, now.Add(wait))
+}
+
+// reachable учитывает ответ Bot API предохранителем и замыкает его, если он был разомкнут
+func (o *outbox) reachable() {
+	if o.breaker.success() {
+		metrics.SendCircuitOpen.WithLabelValues(o.bot).Set(0)
+		o.logger.Infow("send circuit breaker closed")
+	}
+}
+
+// retry возвращает сообщение в начало очереди чата и откладывает чат до момента next.
+// Если очередь уже остановлена, сообщение записывается в dead letter.
+func (o *outbox) retry(q *chatQueue, m *outMessage, next time.Time) {
+	o.mu.Lock()
+	if o.stopped {
+		o.mu.Unlock()
+		o.finish(m, deadletter.ReasonShutdown, errors.Join(errShutdown, m.lastErr))
+		return
+	}
+	q.items = append([]*outMessage{m}, q.items...)
+	if next.After(q.next) {
+		q.next = next
+	}
+	o.mu.Unlock()
+}
+
+// setNext откладывает следующую отправку в чат до момента next
+func (o *outbox) setNext(q *chatQueue, next time.Time) {
+	o.mu.Lock()
+	if next.After(q.next) {
+		q.next = next
+	}
+	o.mu.Unlock()
+}
+
+// release уменьшает глубину очереди после доставки или отказа
+func (o *outbox) release() {
+	o.mu.Lock()
+	o.depth--
+	metrics.SendQueueDepth.WithLabelValues(o.bot).Set(float64(o.depth))
+	o.mu.Unlock()
+}
+
+// finish записывает сообщение из очереди в dead letter
+func (o *outbox) finish(m *outMessage, reason string, err error) {
+	o.release()
+	o.fail(m, reason, err)
+}
+
+// fail записывает недоставленное сообщение в журнал dead letter и возвращает ошибку отправителю
+func (o *outbox) fail(m *outMessage, reason string, err error) {
+	metrics.DeadLettersTotal.WithLabelValues(o.bot, reason).Inc()
+	chatID := strconv.FormatInt(m.chat.ID, 10)
+	o.logger.Warnw("message not delivered", "chat_id", chatID, "kind", m.kind, "reason", reason, "attempts", m.attempts, "error", err)
+
+	rec := deadletter.Record{
+		Time:     time.Now(),
+		Bot:      o.bot,
+		ChatID:   m.chat.ID,
+		Kind:     m.kind,
+		Text:     m.text,
+		Reason:   reason,
+		Attempts: m.attempts,
+		Error:    err.Error(),
+		Queued:   m.queued,
+	}
+	if m.opts.ReplyTo != nil {
+		rec.ReplyTo = m.opts.ReplyTo.ID
+	}
+	if werr := o.dead.Write(rec); werr != nil {
+		metrics.ErrorsTotal.WithLabelValues(o.bot, "dead_letter").Inc()
+		o.logger.Errorw("dead letter write failed", "error", werr)
+	}
+	m.result <- fmt.Errorf("%s: %w", reason, err)
+}
+
+// sleep ждёт d; возвращает false, если ожидание прервано остановкой очереди
+func (o *outbox) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-o.stop:
+		return false
+	}
+}
+
+// close перестаёт принимать сообщения и дожидается, пока очередь опустеет.
+// Если ctx отменён раньше, ожидания прерываются, а оставшиеся сообщения записываются в dead letter.
+func (o *outbox) close(ctx context.Context) error {
+	o.mu.Lock()
+	o.closed = true
+	o.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		o.workers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	o.mu.Lock()
+	if o.stopped {
+		o.mu.Unlock()
+		return nil
+	}
+	o.stopped = true
+	var left []*outMessage
+	for _, q := range o.chats {
+		left = append(left, q.items...)
+		q.items = nil
+	}
+	o.mu.Unlock()
+	close(o.stop)
+
+	for _, m := range left {
+		o.finish(m, deadletter.ReasonShutdown, errors.Join(errShutdown, m.lastErr))
+	}
+	return fmt.Errorf("send queue not drained, %d messages dead-lettered: %w", len(left), ctx.Err())
+}
+
+// classifySend определяет класс ошибки отправки и паузу retry_after для 429
+func classifySend(err error) (int, time.Duration) {
+	var flood tb.FloodError
+	if errors.As(err, &flood) {
+		return sendRateLimited, time.Duration(flood.RetryAfter) * time.Second
+	}
+
+	code := 0
+	var apiErr *tb.Error
+	if errors.As(err, &apiErr) {
+		code = apiErr.Code
+	} else if m := apiErrorCode.FindStringSubmatch(err.Error()); m != nil {
+		code, _ = strconv.Atoi(m[1])
+	}
+	switch {
+	case code == 429:
+		return sendRateLimited, 0
+	case code == 0 || code >= 500:
+		// Нет ответа Bot API (сеть, таймаут, не-JSON от прокси) или ошибка на стороне Telegram
+		return sendTransient, 0
+	default:
+		return sendRejected, 0
+	}
+}
+
+// backoff возвращает паузу перед повтором после attempt попыток:
+// retry_backoff, удваиваемый с каждой попыткой до max_backoff, со случайным разбросом до половины паузы
+func backoff(conf config.SendQueueConfig, attempt int) time.Duration {
+	d := conf.RetryBackoff
+	for i := 1; i < attempt && d < conf.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, conf.MaxBackoff)
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// breaker — предохранитель отправки. После failures сбоев подряд размыкается на cooldown;
+// затем пропускает одну пробную отправку: успех замыкает его, сбой снова размыкает.
+type breaker struct {
+	mu        sync.Mutex
+	failures  int       // сбоев подряд
+	openUntil time.Time // до этого момента отправка приостановлена
+	probing   bool      // идёт пробная отправка
+}
+
+// allow возвращает, сколько ждать до отправки; 0 — отправлять можно
+func (b *breaker) allow(now time.Time, conf config.BreakerConfig) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < conf.Failures {
+		return 0
+	}
+	if now.Before(b.openUntil) {
+		return b.openUntil.Sub(now)
+	}
+	if b.probing {
+		return probeWait
+	}
+	b.probing = true
+	return 0
+}
+
+// success учитывает ответ Bot API. Возвращает true, если предохранитель был разомкнут.
+func (b *breaker) success() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasOpen := b.probing || !b.openUntil.IsZero()
+	b.failures, b.probing, b.openUntil = 0, false, time.Time{}
+	return wasOpen
+}
+
+// failure учитывает сбой отправки. Возвращает true, если предохранитель разомкнулся:
+// сбои набрали порог или не удалась пробная отправка.
+func (b *breaker) failure(now time.Time, conf config.BreakerConfig) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasOpen, probe := !b.openUntil.IsZero(), b.probing
+	b.failures++
+	b.probing = false
+	if b.failures < conf.Failures {
+		return false
+	}
+	b.openUntil = now.Add(conf.Cooldown)
+	return !wasOpen || probe
+}
@@ -0,0 +1,380 @@
+package telegram
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/st-kuptsov/balabol/config"
+	"github.com/st-kuptsov/balabol/internal/telegramtest"
+	"github.com/st-kuptsov/balabol/pkg/deadletter"
+	"github.com/st-kuptsov/balabol/pkg/metrics"
+	"go.uber.org/zap"
+	tb "gopkg.in/telebot.v3"
+)
+
+// outboxToken — токен бота очереди в фейковом Bot API
+const outboxToken = "4343:TEST"
+
+// testQueue — быстрые настройки очереди: лимиты не мешают, паузы повторов — миллисекунды
+func testQueue() config.SendQueueConfig {
+	return config.SendQueueConfig{
+		GlobalRate:   1000,
+		ChatRate:     1000,
+		GroupRate:    60000,
+		Size:         100,
+		MaxAttempts:  3,
+		RetryBackoff: 10 * time.Millisecond,
+		MaxBackoff:   40 * time.Millisecond,
+		MaxAge:       5 * time.Second,
+		Breaker:      config.BreakerConfig{Failures: 100, Cooldown: time.Second},
+	}
+}
+
+// outboxFixture — очередь бота, отправляющая в фейковый Bot API, и её журнал dead letter
+type outboxFixture struct {
+	out  *outbox
+	fake *telegramtest.Bot
+	name string // имя бота: лейбл метрик, у каждого теста свой
+	dead string // путь к журналу dead letter
+}
+
+// newOutboxFixture создаёт очередь с настройками conf. Имя бота — имя теста, чтобы метрики тестов не смешивались.
+func newOutboxFixture(t *testing.T, conf config.SendQueueConfig) *outboxFixture {
+	t.Helper()
+	api := telegramtest.NewServer()
+	t.Cleanup(api.Close)
+
+	api.Bot(outboxToken)
+	bot, err := tb.NewBot(tb.Settings{URL: api.URL, Token: outboxToken, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	conf.DeadLetter = config.DeadLetterConfig{Enabled: true, Directory: dir, Filename: "dead_letters.log", MaxSize: 1}
+	dead := deadletter.New(conf.DeadLetter)
+	t.Cleanup(func() { _ = dead.Close() })
+
+	settings := &config.BotConfig{Name: t.Name(), SendQueue: conf}
+	f := &outboxFixture{
+		out:  newOutbox(t.Name(), bot, func() *config.BotConfig { return settings }, dead, zap.NewNop().Sugar()),
+		fake: api.Bot(outboxToken),
+		name: t.Name(),
+		dead: filepath.Join(dir, conf.DeadLetter.Filename),
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = f.out.close(ctx)
+	})
+	return f
+}
+
+// queue ставит сообщение в очередь, не дожидаясь отправки
+func (f *outboxFixture) queue(chat int64, text string) *outMessage {
+	m := &outMessage{
+		chat:   &tb.Chat{ID: chat},
+		kind:   kindReply,
+		text:   text,
+		opts:   &tb.SendOptions{},
+		queued: time.Now(),
+		result: make(chan error, 1),
+	}
+	f.out.enqueue(m)
+	return m
+}
+
+// wait дожидается итога отправки сообщения
+func wait(t *testing.T, m *outMessage) error {
+	t.Helper()
+	select {
+	case err := <-m.result:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatalf("message %q: no result", m.text)
+		return nil
+	}
+}
+
+// sentTexts возвращает тексты доставленных сообщений по порядку
+func (f *outboxFixture) sentTexts() []string {
+	var texts []string
+	for _, m := range f.fake.Sent() {
+		texts = append(texts, m.Text)
+	}
+	return texts
+}
+
+// deadLetters читает записи журнала dead letter
+func (f *outboxFixture) deadLetters(t *testing.T) []deadletter.Record {
+	t.Helper()
+	file, err := os.Open(f.dead)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var recs []deadletter.Record
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		var rec deadletter.Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// failFirst отвечает на первые n вызовов метода ошибкой err, дальше — обычной обработкой
+func failFirst(n int32, err *telegramtest.Error) telegramtest.HandlerFunc {
+	var calls atomic.Int32
+	return func(telegramtest.Call) (any, error) {
+		if calls.Add(1) <= n {
+			return nil, err
+		}
+		return nil, nil
+	}
+}
+
+// serverError — ошибка 5xx Bot API
+var serverError = &telegramtest.Error{Code: 502, Description: "Bad Gateway"}
+
+func TestOutboxRetryAfterKeepsChatOrder(t *testing.T) {
+	f := newOutboxFixture(t, testQueue())
+	f.fake.Handle("sendMessage", failFirst(1, telegramtest.TooManyRequests(1)))
+	retries := metrics.SendRetriesTotal.WithLabelValues(f.name, "rate_limited")
+	retriesBefore := testutil.ToFloat64(retries)
+
+	first := f.queue(7, "первое")
+	second := f.queue(7, "второе")
+	third := f.queue(7, "третье")
+	for _, m := range []*outMessage{first, second, third} {
+		if err := wait(t, m); err != nil {
+			t.Fatalf("message %q: %v", m.text, err)
+		}
+	}
+
+	// Отклонённое первое сообщение повторяется раньше следующих: порядок в чате сохраняется
+	if got, want := f.sentTexts(), []string{"первое", "второе", "третье"}; !slices.Equal(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+	calls := f.fake.Calls("sendMessage")
+	if len(calls) != 4 {
+		t.Fatalf("sendMessage called %d times, want 4 (one rate-limited)", len(calls))
+	}
+	if gap := calls[1].Time.Sub(calls[0].Time); gap < time.Second {
+		t.Errorf("retry after %s, want at least retry_after (1s)", gap)
+	}
+	if first.attempts != 2 || second.attempts != 1 {
+		t.Errorf("attempts = %d, %d; want 2, 1", first.attempts, second.attempts)
+	}
+	if got := testutil.ToFloat64(retries) - retriesBefore; got != 1 {
+		t.Errorf("rate_limited retries = %v, want 1", got)
+	}
+}
+
+func TestOutboxFailures(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		conf      func(*config.SendQueueConfig)
+		fail      int32 // сколько первых вызовов завершаются ошибкой (-1 — все)
+		err       *telegramtest.Error
+		calls     int    // ожидаемое число вызовов sendMessage
+		reason    string // причина в dead letter; пусто — сообщение доставлено
+		attempts  int    // попыток в записи dead letter
+		transient int    // повторов с reason="transient" для обоих сообщений (-1 — зависит от времени, не проверяется)
+	}{
+		{
+			name: "server errors then success", fail: 2, err: serverError,
+			calls: 3, transient: 2,
+		},
+		{
+			name: "retries exhausted", fail: -1, err: serverError,
+			calls: 3, reason: deadletter.ReasonExhausted, attempts: 3, transient: 4,
+		},
+		{
+			name: "rejected", fail: -1, err: &telegramtest.Error{Description: "Bad Request: message text is empty"},
+			calls: 1, reason: deadletter.ReasonRejected, attempts: 1,
+		},
+		{
+			name: "forbidden", fail: -1, err: &telegramtest.Error{Code: 403, Description: "Forbidden: bot was blocked by the user"},
+			calls: 1, reason: deadletter.ReasonRejected, attempts: 1,
+		},
+		{
+			name: "expired",
+			conf: func(c *config.SendQueueConfig) {
+				c.MaxAttempts, c.MaxAge, c.RetryBackoff, c.MaxBackoff = 100, 300*time.Millisecond, 100*time.Millisecond, 100*time.Millisecond
+			},
+			fail: -1, err: serverError,
+			reason: deadletter.ReasonExpired, transient: -1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := testQueue()
+			if tc.conf != nil {
+				tc.conf(&conf)
+			}
+			f := newOutboxFixture(t, conf)
+			fail := tc.fail
+			if fail < 0 {
+				fail = 1 << 30
+			}
+			f.fake.Handle("sendMessage", failFirst(fail, tc.err))
+			retries := metrics.SendRetriesTotal.WithLabelValues(f.name, "transient")
+			retriesBefore := testutil.ToFloat64(retries)
+
+			m := f.queue(-100, "ответ")
+			next := f.queue(-100, "следующее")
+			err := wait(t, m)
+			if nextErr := wait(t, next); tc.fail >= 0 && nextErr != nil {
+				t.Errorf("next message: %v", nextErr)
+			}
+
+			if tc.calls > 0 {
+				// Вызовы для первого сообщения идут до вызовов для следующего
+				if calls := f.fake.Calls("sendMessage"); len(calls) < tc.calls || calls[tc.calls-1].Params["text"] != "ответ" {
+					t.Errorf("sendMessage calls for the first message: got %d call(s), want %d before the next message", len(calls), tc.calls)
+				}
+			}
+			if got := testutil.ToFloat64(retries) - retriesBefore; tc.transient >= 0 && int(got) != tc.transient {
+				t.Errorf("transient retries = %v, want %d", got, tc.transient)
+			}
+
+			if tc.reason == "" {
+				if err != nil {
+					t.Fatalf("send failed: %v", err)
+				}
+				if got := f.sentTexts(); !slices.Equal(got, []string{"ответ", "следующее"}) {
+					t.Errorf("sent %q, want both messages in order", got)
+				}
+				if recs := f.deadLetters(t); len(recs) != 0 {
+					t.Errorf("dead letters = %+v, want none", recs)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("send succeeded, want dead letter")
+			}
+			recs := f.deadLetters(t)
+			if len(recs) == 0 {
+				t.Fatal("no dead letter written")
+			}
+			rec := recs[0]
+			if rec.Reason != tc.reason || rec.Text != "ответ" || rec.ChatID != -100 || rec.Bot != f.name {
+				t.Errorf("dead letter = %+v, want reason %q for the first message", rec, tc.reason)
+			}
+			if tc.attempts > 0 && rec.Attempts != tc.attempts {
+				t.Errorf("dead letter attempts = %d, want %d", rec.Attempts, tc.attempts)
+			}
+			if got := testutil.ToFloat64(metrics.DeadLettersTotal.WithLabelValues(f.name, tc.reason)); got < 1 {
+				t.Errorf("dead letters metric for %s = %v, want at least 1", tc.reason, got)
+			}
+		})
+	}
+}
+
+func TestOutboxBreaker(t *testing.T) {
+	conf := testQueue()
+	conf.MaxAttempts = 10
+	conf.Breaker = config.BreakerConfig{Failures: 2, Cooldown: 300 * time.Millisecond}
+	f := newOutboxFixture(t, conf)
+	// Два сбоя размыкают предохранитель, пробная отправка после паузы тоже не удаётся,
+	// вторая пробная отправка проходит и замыкает его
+	f.fake.Handle("sendMessage", failFirst(3, serverError))
+	circuit := metrics.SendCircuitOpen.WithLabelValues(f.name)
+
+	m := f.queue(7, "ответ")
+	if _, err := f.fake.WaitCalls("sendMessage", 2, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// Предохранитель размыкается сразу после ответа на второй вызов
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(circuit) != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(circuit); got != 1 {
+		t.Fatalf("circuit open = %v after %d failures, want 1", got, conf.Breaker.Failures)
+	}
+
+	if err := wait(t, m); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	calls := f.fake.Calls("sendMessage")
+	if len(calls) != 4 {
+		t.Fatalf("sendMessage called %d times, want 4", len(calls))
+	}
+	// Разомкнутый предохранитель выдерживает cooldown перед каждой пробной отправкой
+	for _, i := range []int{2, 3} {
+		if gap := calls[i].Time.Sub(calls[i-1].Time); gap < conf.Breaker.Cooldown {
+			t.Errorf("call %d came %s after the previous one, want at least cooldown %s", i+1, gap, conf.Breaker.Cooldown)
+		}
+	}
+	// Ожидание разомкнутого предохранителя не расходует попытки
+	if m.attempts != 4 {
+		t.Errorf("attempts = %d, want 4", m.attempts)
+	}
+	if got := testutil.ToFloat64(circuit); got != 0 {
+		t.Errorf("circuit open = %v after a successful probe, want 0", got)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	conf := config.BreakerConfig{Failures: 2, Cooldown: time.Minute}
+	now := time.Now()
+	var b breaker
+
+	if b.failure(now, conf) {
+		t.Fatal("opened after 1 failure, want after 2")
+	}
+	if !b.failure(now, conf) {
+		t.Fatal("not opened after 2 failures")
+	}
+	if wait := b.allow(now, conf); wait != conf.Cooldown {
+		t.Errorf("allow while open = %s, want cooldown %s", wait, conf.Cooldown)
+	}
+
+	// После паузы пропускается одна пробная отправка, остальные ждут её итога
+	later := now.Add(conf.Cooldown)
+	if wait := b.allow(later, conf); wait != 0 {
+		t.Fatalf("probe not allowed after cooldown: wait %s", wait)
+	}
+	if wait := b.allow(later, conf); wait != probeWait {
+		t.Errorf("second sender during probe: wait %s, want %s", wait, probeWait)
+	}
+
+	// Неудачная проба снова размыкает предохранитель
+	if !b.failure(later, conf) {
+		t.Error("failed probe did not reopen the breaker")
+	}
+	if wait := b.allow(later, conf); wait != conf.Cooldown {
+		t.Errorf("allow after failed probe = %s, want cooldown %s", wait, conf.Cooldown)
+	}
+
+	// Удачная проба замыкает его
+	probe := later.Add(conf.Cooldown)
+	if wait := b.allow(probe, conf); wait != 0 {
+		t.Fatalf("second probe not allowed: wait %s", wait)
+	}
+	if !b.success() {
+		t.Error("successful probe: success() = false, want true (breaker was open)")
+	}
+	if wait := b.allow(probe, conf); wait != 0 {
+		t.Errorf("allow after close = %s, want 0", wait)
+	}
+	if b.success() {
+		t.Error("success() on a closed breaker = true, want false")
+	}
+}
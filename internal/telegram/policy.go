@@ -0,0 +1,181 @@
+package telegram
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config"      // настройки фильтра отправителей
+	"github.com/st-kuptsov/balabol/pkg/metrics" // метрики Prometheus
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"        // структурированное логирование
+	tb "gopkg.in/telebot.v3" // библиотека для Telegram-бота
+)
+
+// Причины отброшенных сообщений (лейбл reason метрики bot_messages_dropped_total)
+const (
+	dropSelf           = "self"             // сообщение самого бота
+	dropBot            = "bot"              // отправитель — бот
+	dropForward        = "forward"          // пересланное сообщение
+	dropViaBot         = "via_bot"          // сообщение через inline-бота
+	dropSenderChat     = "sender_chat"      // от имени чата: анонимный админ или пост канала
+	dropUserBlocked    = "user_blocked"     // пользователь в sender_policy.users.block
+	dropUserNotAllowed = "user_not_allowed" // пользователя нет в sender_policy.users.allow
+	dropChatBlocked    = "chat_blocked"     // чат в sender_policy.chats.block
+	dropChatNotAllowed = "chat_not_allowed" // чата нет в sender_policy.chats.allow
+	dropFlood          = "flood"            // пользователь временно заглушён за флуд
+)
+
+// filterSenders создаёт middleware, которое отбрасывает сообщения по sender_policy
+// до обработчиков. Настройки читаются через settingsFn, поэтому применяются без перезапуска.
+func filterSenders(bot string, settingsFn func() *config.BotConfig, flood *floodGuard, logger *zap.SugaredLogger) tb.MiddlewareFunc {
+	return func(next tb.HandlerFunc) tb.HandlerFunc {
+		return func(c tb.Context) error {
+			settings := settingsFn()
+			if settings == nil || c.Message() == nil {
+				return next(c)
+			}
+
+			reason := dropReason(c, settings.SenderPolicy, flood, logger)
+			if reason == "" {
+				return next(c)
+			}
+			metrics.DroppedTotal.WithLabelValues(bot, reason).Inc()
+			trace.SpanFromContext(updateContext(c)).SetAttributes(attribute.String("dropped.reason", reason))
+			return nil
+		}
+	}
+}
+
+// dropReason возвращает причину, по которой сообщение нужно отбросить,
+// или пустую строку, если сообщение проходит фильтр
+func dropReason(c tb.Context, policy config.SenderPolicyConfig, flood *floodGuard, logger *zap.SugaredLogger) string {
+	msg := c.Message()
+	sender := msg.Sender
+
+	switch {
+	case sender != nil && c.Bot().Me != nil && sender.ID == c.Bot().Me.ID:
+		return dropSelf
+	case policy.IgnoreBots && sender != nil && sender.IsBot:
+		return dropBot
+	case policy.IgnoreForwards && isForwarded(msg):
+		return dropForward
+	case policy.IgnoreViaBot && msg.Via != nil:
+		return dropViaBot
+	case policy.IgnoreSenderChat && msg.SenderChat != nil:
+		return dropSenderChat
+	}
+
+	if chat := c.Chat(); chat != nil {
+		switch policy.Chats.Check(chat.ID) {
+		case config.AccessBlocked:
+			return dropChatBlocked
+		case config.AccessNotAllowed:
+			return dropChatNotAllowed
+		}
+	}
+
+	if sender == nil {
+		return ""
+	}
+	switch policy.Users.Check(sender.ID) {
+	case config.AccessBlocked:
+		return dropUserBlocked
+	case config.AccessNotAllowed:
+		return dropUserNotAllowed
+	}
+
+	if policy.Flood.Enabled {
+		muted, started := flood.Hit(sender.ID, policy.Flood, time.Now())
+		if started {
+			logger.Warnw("sender muted for flooding",
+				"user_id", strconv.FormatInt(sender.ID, 10),
+				"messages", policy.Flood.Messages,
+				"window", policy.Flood.Window,
+				"mute", policy.Flood.Mute,
+			)
+		}
+		if muted {
+			return dropFlood
+		}
+	}
+	return ""
+}
+
+// isForwarded сообщает, переслано ли сообщение (в том числе от скрытого отправителя)
+func isForwarded(msg *tb.Message) bool {
+	return msg.IsForwarded() || msg.Origin != nil || msg.OriginalSenderName != ""
+}
+
+// floodPruneInterval — как часто удаляются записи пользователей, давно не писавших боту
+const floodPruneInterval = time.Minute
+
+// floodGuard считает входящие сообщения каждого пользователя в скользящем окне
+// и временно заглушает бота для пользователя, превысившего лимит
+type floodGuard struct {
+	mu        sync.Mutex
+	users     map[int64]*floodState
+	lastPrune time.Time
+}
+
+// floodState — недавние сообщения одного пользователя
+type floodState struct {
+	times      []time.Time // время сообщений в пределах окна
+	mutedUntil time.Time   // до какого момента бот не отвечает пользователю
+}
+
+// newFloodGuard создаёт пустой счётчик флуда
+func newFloodGuard() *floodGuard {
+	return &floodGuard{users: make(map[int64]*floodState)}
+}
+
+// Hit учитывает сообщение пользователя userID.
+// Возвращает muted=true, если пользователь заглушён (в том числе этим сообщением),
+// и started=true, если заглушение началось именно сейчас.
+func (g *floodGuard) Hit(userID int64, conf config.FloodConfig, now time.Time) (muted, started bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.prune(conf, now)
+
+	st := g.users[userID]
+	if st == nil {
+		st = &floodState{}
+		g.users[userID] = st
+	}
+	if now.Before(st.mutedUntil) {
+		return true, false
+	}
+
+	// Оставляем только сообщения внутри окна
+	cutoff := now.Add(-conf.Window)
+	i := 0
+	for i < len(st.times) && !st.times[i].After(cutoff) {
+		i++
+	}
+	st.times = append(st.times[i:], now)
+
+	if len(st.times) > conf.Messages {
+		st.mutedUntil = now.Add(conf.Mute)
+		st.times = nil
+		return true, true
+	}
+	return false, false
+}
+
+// prune удаляет пользователей без сообщений в окне и без действующего заглушения
+func (g *floodGuard) prune(conf config.FloodConfig, now time.Time) {
+	if now.Sub(g.lastPrune) < floodPruneInterval {
+		return
+	}
+	g.lastPrune = now
+
+	cutoff := now.Add(-conf.Window)
+	for id, st := range g.users {
+		idle := len(st.times) == 0 || !st.times[len(st.times)-1].After(cutoff)
+		if idle && !now.Before(st.mutedUntil) {
+			delete(g.users, id)
+		}
+	}
+}
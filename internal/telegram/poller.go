@@ -0,0 +1,319 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config" // настройки получения обновлений
+	"go.uber.org/zap"                      // структурированное логирование
+	tb "gopkg.in/telebot.v3"               // библиотека для Telegram-бота
+)
+
+// dedupWindow — сколько последних update_id помнит фильтр дубликатов.
+// Telegram повторяет доставку webhook, если не получил ответ вовремя,
+// поэтому одно и то же обновление может прийти несколько раз.
+const dedupWindow = 1024
+
+// secretTokenHeader — заголовок, в котором Telegram передаёт секрет webhook
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// pollRetryDelay — пауза перед повтором getUpdates после ошибки
+const pollRetryDelay = 3 * time.Second
+
+// pollHealth хранит состояние получения обновлений для проверок готовности
+type pollHealth struct {
+	lastPoll   atomic.Int64 // время последнего успешного получения обновлений (UnixNano)
+	registered atomic.Bool  // webhook зарегистрирован в Telegram
+}
+
+// markPoll запоминает время успешного получения обновлений
+func (h *pollHealth) markPoll() {
+	h.lastPoll.Store(time.Now().UnixNano())
+}
+
+// LastPoll возвращает время последнего успешного получения обновлений
+func (h *pollHealth) LastPoll() time.Time {
+	ns := h.lastPoll.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// newPoller создаёт поставщика обновлений согласно telegram.poller.
+// Любой поллер оборачивается фильтром, который отбрасывает повторные update_id
+// и передаёт каждое обновление в observe (учёт исходов экспериментов).
+// Реакции telebot не обрабатывает, поэтому дальше observe они не передаются.
+func newPoller(conf config.TelegramConfig, health *pollHealth, observe func(*tb.Update), logger *zap.SugaredLogger) (tb.Poller, error) {
+	var poller tb.Poller
+
+	allowed := allowedUpdates(conf)
+	switch conf.Poller {
+	case config.PollerLongPolling, "":
+		poller = &longPoller{timeout: conf.LongPolling.Timeout, allowed: allowed, health: health}
+	case config.PollerWebhook:
+		if conf.Webhook.PublicURL == "" {
+			return nil, errors.New("webhook poller requires telegram.webhook.public_url")
+		}
+		if (conf.Webhook.CertFile == "") != (conf.Webhook.KeyFile == "") {
+			return nil, errors.New("webhook poller requires both cert_file and key_file for TLS")
+		}
+		poller = &webhookPoller{conf: conf.Webhook, allowed: allowed, health: health, logger: logger}
+	default:
+		return nil, fmt.Errorf("unknown poller %q", conf.Poller)
+	}
+
+	dedup := newUpdateDeduper(dedupWindow)
+	return tb.NewMiddlewarePoller(poller, func(upd *tb.Update) bool {
+		if dedup.seen(upd.ID) {
+			logger.Debugw("duplicate update dropped", "update_id", upd.ID)
+			return false
+		}
+		observe(upd)
+		return upd.MessageReaction == nil
+	}), nil
+}
+
+// allowedUpdates возвращает типы обновлений для getUpdates и setWebhook.
+// Реакции Telegram присылает, только если их запросить явно; nil — не менять набор,
+// запрошенный ранее (по умолчанию — все типы, кроме реакций и изменений участников).
+func allowedUpdates(conf config.TelegramConfig) []string {
+	if !conf.Reactions {
+		return nil
+	}
+	// Боту нужны только сообщения; реакции — для учёта экспериментов
+	return []string{"message", "message_reaction"}
+}
+
+// updateDeduper помнит последние size идентификаторов обновлений
+// в кольцевом буфере и сообщает, встречался ли идентификатор ранее.
+type updateDeduper struct {
+	mu   sync.Mutex
+	ids  map[int]struct{} // множество запомненных update_id
+	ring []int            // порядок поступления для вытеснения старых
+	next int              // позиция в ring для следующей записи
+}
+
+// newUpdateDeduper создаёт фильтр дубликатов на size обновлений
+func newUpdateDeduper(size int) *updateDeduper {
+	return &updateDeduper{
+		ids:  make(map[int]struct{}, size),
+		ring: make([]int, 0, size),
+	}
+}
+
+// seen возвращает true, если id уже встречался, иначе запоминает его
+func (d *updateDeduper) seen(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.ids[id]; ok {
+		return true
+	}
+
+	// Буфер заполнен — вытесняем самый старый идентификатор
+	if len(d.ring) < cap(d.ring) {
+		d.ring = append(d.ring, id)
+	} else {
+		delete(d.ids, d.ring[d.next])
+		d.ring[d.next] = id
+		d.next = (d.next + 1) % len(d.ring)
+	}
+	d.ids[id] = struct{}{}
+	return false
+}
+
+// longPoller получает обновления через getUpdates.
+// В отличие от tb.LongPoller, отмечает успешные запросы для проверки готовности
+// и передаёт ошибки в OnError бота с паузой перед повтором.
+type longPoller struct {
+	timeout      time.Duration
+	allowed      []string // типы обновлений (nil — набор, запрошенный ранее)
+	health       *pollHealth
+	lastUpdateID int
+}
+
+// Poll запрашивает обновления, пока не будет закрыт stop
+func (p *longPoller) Poll(b *tb.Bot, dest chan tb.Update, stop chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		updates, err := p.getUpdates(b)
+		if err != nil {
+			// Запрос прерван остановкой бота — это не ошибка, ждём закрытия stop
+			if !errors.Is(err, context.Canceled) {
+				b.OnError(fmt.Errorf("get updates: %w", err), nil)
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(pollRetryDelay):
+			}
+			continue
+		}
+		p.health.markPoll()
+
+		for _, upd := range updates {
+			p.lastUpdateID = upd.ID
+			select {
+			case dest <- upd:
+			case <-stop:
+				return
+			}
+		}
+	}
+}
+
+// getUpdates выполняет один запрос getUpdates начиная со следующего обновления
+func (p *longPoller) getUpdates(b *tb.Bot) ([]tb.Update, error) {
+	params := map[string]string{
+		"offset":  strconv.Itoa(p.lastUpdateID + 1),
+		"timeout": strconv.Itoa(int(p.timeout / time.Second)),
+	}
+	if p.allowed != nil {
+		data, _ := json.Marshal(p.allowed)
+		params["allowed_updates"] = string(data)
+	}
+
+	data, err := b.Raw("getUpdates", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Result []tb.Update `json:"result"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("decode getUpdates response: %w", err)
+	}
+	return resp.Result, nil
+}
+
+// webhookPoller принимает обновления на собственном HTTP-сервере.
+// При старте регистрирует webhook в Telegram, при остановке — удаляет его.
+type webhookPoller struct {
+	conf    config.WebhookConfig
+	allowed []string // типы обновлений (nil — набор, запрошенный ранее)
+	health  *pollHealth
+	logger  *zap.SugaredLogger
+}
+
+// Poll запускает HTTP-сервер webhook и работает до закрытия stop
+func (p *webhookPoller) Poll(b *tb.Bot, dest chan tb.Update, stop chan struct{}) {
+	mux := http.NewServeMux()
+	mux.Handle(p.conf.Path, p.handler(dest, stop))
+	srv := &http.Server{
+		Addr:              p.conf.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Сервер поднимаем до регистрации, чтобы не потерять первые обновления
+	serveErr := make(chan error, 1)
+	go func() {
+		if p.conf.CertFile != "" {
+			serveErr <- srv.ListenAndServeTLS(p.conf.CertFile, p.conf.KeyFile)
+		} else {
+			serveErr <- srv.ListenAndServe()
+		}
+	}()
+	p.logger.Infow("webhook server started", "listen", p.conf.Listen, "path", p.conf.Path, "tls", p.conf.CertFile != "")
+
+	if err := b.SetWebhook(p.webhook()); err != nil {
+		b.OnError(fmt.Errorf("set webhook: %w", err), nil)
+		p.shutdown(srv)
+		return
+	}
+	p.health.registered.Store(true)
+	p.health.markPoll()
+	p.logger.Infow("webhook registered", "url", p.conf.PublicURL)
+
+	select {
+	case <-stop:
+	case err := <-serveErr:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			b.OnError(fmt.Errorf("webhook server: %w", err), nil)
+		}
+	}
+
+	p.health.registered.Store(false)
+	p.shutdown(srv)
+	if err := b.RemoveWebhook(); err != nil {
+		b.OnError(fmt.Errorf("remove webhook: %w", err), nil)
+		return
+	}
+	p.logger.Info("webhook removed")
+}
+
+// webhook собирает параметры setWebhook из конфигурации
+func (p *webhookPoller) webhook() *tb.Webhook {
+	endpoint := &tb.WebhookEndpoint{PublicURL: p.conf.PublicURL}
+	if p.conf.SelfSigned {
+		endpoint.Cert = p.conf.CertFile // самоподписанный сертификат нужно передать Telegram
+	}
+	return &tb.Webhook{
+		MaxConnections: p.conf.MaxConnections,
+		AllowedUpdates: p.allowed,
+		DropUpdates:    p.conf.DropPending,
+		SecretToken:    p.conf.SecretToken,
+		Endpoint:       endpoint,
+	}
+}
+
+// shutdown останавливает HTTP-сервер webhook, дожидаясь текущих запросов
+func (p *webhookPoller) shutdown(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		p.logger.Warnw("webhook server shutdown failed", "error", err)
+	}
+}
+
+// handler разбирает входящие обновления и передаёт их боту.
+// Запросы без корректного секрета отклоняются с кодом 401.
+func (p *webhookPoller) handler(dest chan tb.Update, stop chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Проверяем секрет, если он задан в файле секретов
+		if p.conf.SecretToken != "" {
+			got := r.Header.Get(secretTokenHeader)
+			if subtle.ConstantTimeCompare([]byte(got), []byte(p.conf.SecretToken)) != 1 {
+				p.logger.Warnw("webhook request with invalid secret token", "remote", r.RemoteAddr)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+
+		var upd tb.Update
+		if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+			p.logger.Warnw("cannot decode webhook update", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case dest <- upd:
+			p.health.markPoll()
+			w.WriteHeader(http.StatusOK)
+		case <-stop:
+			// Бот останавливается — Telegram доставит обновление повторно
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+}
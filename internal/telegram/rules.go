@@ -2,18 +2,24 @@ package telegram
 
 import (
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"strings"
 
 	"github.com/st-kuptsov/balabol/config"
+	"github.com/st-kuptsov/balabol/pkg/experiments"
+	"github.com/st-kuptsov/balabol/pkg/i18n"
+	"github.com/st-kuptsov/balabol/pkg/redact"
 )
 
 // hit представляет совпадение текста с правилом
 type hit struct {
-	pos      int    // позиция совпадения в тексте
-	ruleIdx  int    // индекс правила в списке rules
-	resp     string // ответ, связанный с правилом
-	ruleName string // название правила (Pattern)
-	ruleText string // текстовое описание правила
+	pos      int                 // позиция совпадения в тексте
+	ruleIdx  int                 // индекс правила в списке rules
+	ruleName string              // название правила (Pattern)
+	ruleText string              // текстовое описание правила
+	shadow   bool                // теневое правило: учитывается отдельно и не влияет на ответ
+	variant  *config.Variant     // вариант ответа эксперимента (заполняется pickVariants)
+	choice   *experiments.Choice // имена эксперимента и варианта для учёта и аудита
 }
 
 // MatchRules проверяет текст на соответствие правилам.
@@ -21,11 +27,16 @@ type hit struct {
 // - text: текст для проверки
 // - rules: список правил (config.Rule)
 // - mode: режим обработки ("first_last" или "all")
+// - redactMode: режим скрытия текста в отладочных логах (log_settings.redact)
 // - logger: логгер для отладки
 //
+// Теневые правила (shadow) проверяются наравне с остальными; отделить их совпадения — splitShadow.
+//
 // Возвращает список hit — все совпадения с правилами.
-func MatchRules(text string, rules []config.Rule, mode string, logger *zap.SugaredLogger) []hit {
+func MatchRules(text string, rules []config.Rule, mode, redactMode string, logger *zap.SugaredLogger) []hit {
 	var hits []hit
+	// Повторный поиск совпадений и скрытие текста нужны только для отладочного лога
+	debug := logger.Desugar().Core().Enabled(zapcore.DebugLevel)
 
 	switch mode {
 	case "first_last":
@@ -37,13 +48,14 @@ func MatchRules(text string, rules []config.Rule, mode string, logger *zap.Sugar
 				continue
 			}
 
-			locs := re.FindAllStringIndex(text, -1)      // позиции совпадений
-			matchedStrings := re.FindAllString(text, -1) // сами совпадения
-			logger.Debugw("Pattern matching",
-				"text", text,
-				"pattern", rule.Pattern,
-				"matches", locs,
-				"matchedStrings", matchedStrings)
+			locs := re.FindAllStringIndex(text, -1) // позиции совпадений
+			if debug {
+				logger.Debugw("Pattern matching",
+					"text", redact.Text(redactMode, text),
+					"pattern", rule.Pattern,
+					"matches", locs,
+					"matchedStrings", redact.Strings(redactMode, re.FindAllString(text, -1)))
+			}
 
 			if len(locs) == 0 {
 				continue
@@ -54,9 +66,9 @@ func MatchRules(text string, rules []config.Rule, mode string, logger *zap.Sugar
 				hits = append(hits, hit{
 					pos:      locs[0][0],
 					ruleIdx:  i,
-					resp:     rule.Response,
 					ruleName: rule.Pattern,
 					ruleText: rule.Text,
+					shadow:   rule.Shadow,
 				})
 			}
 
@@ -67,9 +79,9 @@ func MatchRules(text string, rules []config.Rule, mode string, logger *zap.Sugar
 					hits = append(hits, hit{
 						pos:      lastLoc[0],
 						ruleIdx:  i,
-						resp:     rule.Response,
 						ruleName: rule.Pattern,
 						ruleText: rule.Text,
+						shadow:   rule.Shadow,
 					})
 				}
 			}
@@ -84,21 +96,22 @@ func MatchRules(text string, rules []config.Rule, mode string, logger *zap.Sugar
 				continue
 			}
 
-			locs := re.FindAllStringIndex(text, -1)
-			matchedStrings := re.FindAllString(text, -1)
-			logger.Debugw("Pattern matching",
-				"text", text,
-				"pattern", rule.Pattern,
-				"matches", locs,
-				"matchedStrings", matchedStrings)
+			locs := re.FindAllStringIndex(text, -1) // позиции совпадений
+			if debug {
+				logger.Debugw("Pattern matching",
+					"text", redact.Text(redactMode, text),
+					"pattern", rule.Pattern,
+					"matches", locs,
+					"matchedStrings", redact.Strings(redactMode, re.FindAllString(text, -1)))
+			}
 
 			for _, loc := range locs {
 				hits = append(hits, hit{
 					pos:      loc[0],
 					ruleIdx:  i,
-					resp:     rule.Response,
 					ruleName: rule.Pattern,
 					ruleText: rule.Text,
+					shadow:   rule.Shadow,
 				})
 			}
 		}
@@ -106,3 +119,61 @@ func MatchRules(text string, rules []config.Rule, mode string, logger *zap.Sugar
 
 	return hits
 }
+
+// splitShadow разделяет совпадения на обычные и совпадения теневых правил
+func splitShadow(hits []hit) (live, shadow []hit) {
+	for _, h := range hits {
+		if h.shadow {
+			shadow = append(shadow, h)
+		} else {
+			live = append(live, h)
+		}
+	}
+	return live, shadow
+}
+
+// Evaluation — результат прогона текста через правила бота
+type Evaluation struct {
+	Text   string // текст после очистки
+	Rules  []int  // индексы сработавших правил (без теневых), каждое правило один раз
+	Shadow []int  // индексы сработавших теневых правил
+	Reply  bool   // бот ответил бы на сообщение (есть ответ, а не только действия модерации)
+}
+
+// Evaluate прогоняет текст сообщения через те же очистку и MatchRules, что и обработчик бота,
+// но ничего не отправляет и не учитывает в метриках. Используется командой replay.
+//
+// Параметры:
+// - raw: исходный текст сообщения
+// - settings: настройки бота (правила, режим, очистка текста)
+// - logger: логгер для отладки
+//
+// Фильтр отправителей (sender_policy) не применяется: в тексте нет сведений об отправителе.
+func Evaluate(raw string, settings *config.BotConfig, logger *zap.SugaredLogger) Evaluation {
+	var ev Evaluation
+	ev.Text = cleanText(strings.TrimSpace(raw), settings.CleanRe(), settings.RemoveDuplicates(), settings.Redact, logger)
+	if ev.Text == "" {
+		return ev
+	}
+
+	live, shadow := splitShadow(MatchRules(ev.Text, settings.Rules, settings.BotMode, settings.Redact, logger))
+	chain := i18n.Resolve(settings.I18n, 0, 0, "").Chain // язык по умолчанию
+	seen := make(map[int]bool)
+	for _, h := range live {
+		rule := &settings.Rules[h.ruleIdx]
+		if resp, _ := rule.ResponseFor(chain); resp != "" || rule.Experiment != nil {
+			ev.Reply = true
+		}
+		if !seen[h.ruleIdx] {
+			seen[h.ruleIdx] = true
+			ev.Rules = append(ev.Rules, h.ruleIdx)
+		}
+	}
+	for _, h := range shadow {
+		if !seen[h.ruleIdx] {
+			seen[h.ruleIdx] = true
+			ev.Shadow = append(ev.Shadow, h.ruleIdx)
+		}
+	}
+	return ev
+}
@@ -0,0 +1,131 @@
+package telegram
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/st-kuptsov/balabol/config"    // настройки статистики и языка
+	"github.com/st-kuptsov/balabol/pkg/i18n"  // тексты статистики
+	"github.com/st-kuptsov/balabol/pkg/stats" // счётчики статистики
+	tb "gopkg.in/telebot.v3"                  // библиотека для Telegram-бота
+)
+
+// maxMessageLength — максимальная длина текста сообщения в Telegram
+const maxMessageLength = 4096
+
+// windowMessages — ключ сообщения с названием каждого окна статистики
+var windowMessages = map[string]string{
+	stats.WindowDay:  i18n.MsgStatsDay,
+	stats.WindowWeek: i18n.MsgStatsWeek,
+	stats.WindowAll:  i18n.MsgStatsAll,
+}
+
+// statsMessage собирает запись статистики о сообщении, на которое сработали правила
+func statsMessage(bot string, c tb.Context, hits []hit, replied bool) stats.Message {
+	msg := stats.Message{Bot: bot, ChatID: c.Chat().ID, Title: c.Chat().Title, Replied: replied}
+	if sender := c.Sender(); sender != nil {
+		msg.UserID, msg.User = sender.ID, mention(sender)
+	}
+	for _, h := range hits {
+		msg.Rules = append(msg.Rules, h.ruleText)
+	}
+	return msg
+}
+
+// formatSummary форматирует статистику чата: по блоку на окно с числом ответов,
+// срабатываний и рейтингами правил и участников
+func formatSummary(conf config.I18nConfig, chain []string, sum stats.Summary) string {
+	blocks := make([]string, 0, len(sum.Windows))
+	for _, w := range sum.Windows {
+		lines := []string{i18n.Text(conf, chain, i18n.MsgStatsTotals, i18n.Text(conf, chain, windowMessages[w.Name]), w.Replies, w.Hits)}
+		if len(w.Rules) > 0 {
+			lines = append(lines, i18n.Text(conf, chain, i18n.MsgStatsRules, formatEntries(w.Rules)))
+		}
+		if len(w.Users) > 0 {
+			lines = append(lines, i18n.Text(conf, chain, i18n.MsgStatsUsers, formatEntries(w.Users)))
+		}
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// formatEntries форматирует рейтинг: "Привет — 3, Пока — 1"
+func formatEntries(entries []stats.Entry) string {
+	parts := make([]string, 0, len(entries))
+	for _, e := range entries {
+		parts = append(parts, fmt.Sprintf("%s — %d", e.Name, e.Count))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SendDigest отправляет сводку статистики в чат администраторов (stats.digest.chat_id).
+// В сводку входят чаты из stats.digest.chats, а если список пуст — чаты,
+// где правила срабатывали с момента since. Длинная сводка делится на несколько сообщений.
+//
+// Параметры:
+// - settings: текущие настройки бота
+// - store: счётчики статистики
+// - since: время предыдущей сводки
+// - now: текущее время
+//
+// Возвращает число чатов в сводке или ошибку отправки.
+func (b *Bot) SendDigest(settings *config.BotConfig, store *stats.Store, since, now time.Time) (int, error) {
+	conf := settings.Stats.Digest
+	lang := i18n.Resolve(settings.I18n, conf.ChatID, 0, "")
+
+	chats := conf.Chats
+	if len(chats) == 0 {
+		chats = store.ActiveChats(settings.Name, since)
+	}
+
+	sections := []string{i18n.Text(settings.I18n, lang.Chain, i18n.MsgDigestTitle)}
+	if len(chats) == 0 {
+		sections = append(sections, i18n.Text(settings.I18n, lang.Chain, i18n.MsgDigestEmpty))
+	}
+	for _, id := range chats {
+		sum := store.Summary(settings.Name, id, settings.Stats.Top, now)
+		heading := strconv.FormatInt(id, 10)
+		if sum.Title != "" {
+			heading = sum.Title + " (" + heading + ")"
+		}
+		sections = append(sections, heading+"\n"+formatSummary(settings.I18n, lang.Chain, sum))
+	}
+
+	admin := &tb.Chat{ID: conf.ChatID}
+	for _, text := range splitMessages(sections, "\n\n") {
+		if _, err := b.out.send(admin, kindDigest, text, nil); err != nil {
+			return 0, err
+		}
+	}
+	return len(chats), nil
+}
+
+// splitMessages склеивает части через sep в сообщения не длиннее maxMessageLength символов.
+// Часть длиннее лимита обрезается.
+func splitMessages(parts []string, sep string) []string {
+	var messages []string
+	var cur []string
+	size := 0
+	for _, p := range parts {
+		if r := []rune(p); len(r) > maxMessageLength {
+			p = string(r[:maxMessageLength])
+		}
+		n := utf8.RuneCountInString(p)
+		if len(cur) > 0 && size+utf8.RuneCountInString(sep)+n > maxMessageLength {
+			messages = append(messages, strings.Join(cur, sep))
+			cur, size = nil, 0
+		}
+		if len(cur) > 0 {
+			size += utf8.RuneCountInString(sep)
+		}
+		cur = append(cur, p)
+		size += n
+	}
+	if len(cur) > 0 {
+		messages = append(messages, strings.Join(cur, sep))
+	}
+	return messages
+}
@@ -0,0 +1,54 @@
+package telegram
+
+import (
+	"context"
+
+	"github.com/st-kuptsov/balabol/pkg/tracing" // трассировка OpenTelemetry
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	tb "gopkg.in/telebot.v3" // библиотека для Telegram-бота
+)
+
+// updateContextKey — ключ, под которым в tb.Context хранится контекст спана обновления
+const updateContextKey = "balabol.ctx"
+
+// traceUpdates создаёт middleware, которое открывает корневой спан на каждое обновление.
+// Контекст спана доступен обработчикам через updateContext.
+func traceUpdates(bot string) tb.MiddlewareFunc {
+	return func(next tb.HandlerFunc) tb.HandlerFunc {
+		return func(c tb.Context) error {
+			ctx, span := tracing.Tracer().Start(context.Background(), "telegram.update",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					attribute.String("bot", bot),
+					attribute.Int("update_id", c.Update().ID),
+				),
+			)
+			defer span.End()
+
+			if chat := c.Chat(); chat != nil {
+				span.SetAttributes(attribute.Int64("chat_id", chat.ID))
+			}
+			if msg := c.Message(); msg != nil {
+				span.SetAttributes(attribute.Int("message_id", msg.ID))
+			}
+
+			c.Set(updateContextKey, ctx)
+			err := next(c)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		}
+	}
+}
+
+// updateContext возвращает контекст спана текущего обновления
+func updateContext(c tb.Context) context.Context {
+	if ctx, ok := c.Get(updateContextKey).(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
@@ -0,0 +1,84 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/st-kuptsov/balabol/pkg/metrics" // метрики Prometheus
+)
+
+// Классы ошибок Telegram Bot API для метрики bot_api_errors_total
+const (
+	errClassRateLimited = "rate_limited" // 429 Too Many Requests — Telegram ограничивает частоту
+	errClassForbidden   = "forbidden"    // 403 — бота исключили из чата или заблокировали
+	errClassNetwork     = "network"      // запрос не дошёл до Telegram или ответ не получен
+	errClassClient      = "client"       // прочие 4xx — некорректный запрос
+	errClassServer      = "server"       // 5xx — ошибка на стороне Telegram
+)
+
+// apiTimeout — таймаут HTTP-клиента Bot API (как у telebot по умолчанию)
+const apiTimeout = time.Minute
+
+// apiTimeoutMargin — запас сверх long_polling.timeout, за который Telegram успевает ответить на getUpdates
+const apiTimeoutMargin = 30 * time.Second
+
+// newAPIClient создаёт HTTP-клиент, который учитывает в метриках
+// каждый запрос бота к Telegram Bot API.
+// Таймаут клиента не меньше long_polling.timeout с запасом, иначе длинный getUpdates
+// обрывался бы клиентом раньше, чем Telegram успевает ответить.
+func newAPIClient(bot string, pollTimeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   max(apiTimeout, pollTimeout+apiTimeoutMargin),
+		Transport: &instrumentedTransport{bot: bot, next: http.DefaultTransport},
+	}
+}
+
+// instrumentedTransport измеряет длительность запросов к Bot API
+// и считает ошибки по методу и классу ошибки
+type instrumentedTransport struct {
+	bot  string            // имя бота для лейбла bot
+	next http.RoundTripper // транспорт, выполняющий запрос
+}
+
+// RoundTrip выполняет запрос и обновляет метрики
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// URL запроса: <api>/bot<token>/<method>, токен в лейбл не попадает
+	method := path.Base(req.URL.Path)
+	start := time.Now()
+
+	resp, err := t.next.RoundTrip(req)
+	metrics.APIRequestDuration.WithLabelValues(t.bot, method).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		// Отмена запроса при остановке бота ошибкой не считается
+		if !errors.Is(err, context.Canceled) {
+			metrics.APIErrorsTotal.WithLabelValues(t.bot, method, errClassNetwork).Inc()
+		}
+		return resp, err
+	}
+
+	if class := errorClass(resp.StatusCode); class != "" {
+		metrics.APIErrorsTotal.WithLabelValues(t.bot, method, class).Inc()
+	}
+	return resp, nil
+}
+
+// errorClass определяет класс ошибки по HTTP-статусу ответа Bot API.
+// Для успешных ответов возвращает пустую строку.
+func errorClass(status int) string {
+	switch {
+	case status < 400:
+		return ""
+	case status == http.StatusTooManyRequests:
+		return errClassRateLimited
+	case status == http.StatusForbidden:
+		return errClassForbidden
+	case status >= 500:
+		return errClassServer
+	default:
+		return errClassClient
+	}
+}
@@ -0,0 +1,249 @@
+package telegramtest
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tb "gopkg.in/telebot.v3" // типы Bot API
+)
+
+// Call — запрос бота к Bot API
+type Call struct {
+	Method string            // метод, например sendMessage
+	Params map[string]string // параметры; не строковые значения — в виде JSON
+	Time   time.Time         // когда запрос получен
+}
+
+// HandlerFunc подменяет ответ метода (см. Bot.Handle).
+// Ненулевая ошибка возвращается боту как ошибка Bot API (*Error — с её кодом, иначе 500);
+// результат nil без ошибки означает обычную обработку метода.
+type HandlerFunc func(call Call) (result any, err error)
+
+// Bot — состояние одного бота фейкового сервера: очередь обновлений, известные чаты
+// и сообщения, отправленные ботом сообщения и журнал запросов.
+// Методы безопасны для вызова из нескольких горутин.
+type Bot struct {
+	Token string   // токен бота
+	Me    *tb.User // ответ getMe: ID из числовой части токена
+
+	server *Server
+
+	mu          sync.Mutex
+	changed     chan struct{} // закрывается и заменяется при каждом изменении: новое обновление или запрос
+	updates     []tb.Update   // обновления, которые бот ещё не подтвердил
+	allowed     []string      // allowed_updates из последнего getUpdates или setWebhook
+	lastUpdate  int           // ID последнего добавленного обновления
+	lastMessage int           // ID последнего сообщения (общий для всех чатов)
+	chats       map[int64]*tb.Chat
+	messages    map[messageKey]*tb.Message // сообщения, на которые можно ответить или которые можно удалить
+	sent        []*tb.Message              // сообщения, отправленные ботом
+	calls       []Call
+	handlers    map[string]HandlerFunc
+	webhook     *webhook // зарегистрированный webhook (nil — long polling)
+}
+
+// messageKey — сообщение в чате
+type messageKey struct {
+	chat int64
+	id   int
+}
+
+// newBot создаёт бота с пустой очередью обновлений
+func newBot(s *Server, token string) *Bot {
+	id, err := strconv.ParseInt(strings.SplitN(token, ":", 2)[0], 10, 64)
+	if err != nil || id <= 0 {
+		id = 1
+	}
+	return &Bot{
+		Token: token,
+		Me: &tb.User{
+			ID:        id,
+			IsBot:     true,
+			FirstName: "Test Bot",
+			Username:  fmt.Sprintf("test_%d_bot", id),
+		},
+		server:   s,
+		changed:  make(chan struct{}),
+		chats:    make(map[int64]*tb.Chat),
+		messages: make(map[messageKey]*tb.Message),
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// User возвращает пользователя с идентификатором id
+func User(id int64) *tb.User {
+	return &tb.User{ID: id, FirstName: fmt.Sprintf("User %d", id), LanguageCode: "ru"}
+}
+
+// Group возвращает супергруппу с идентификатором id (обычно отрицательным)
+func Group(id int64) *tb.Chat {
+	return &tb.Chat{ID: id, Type: tb.ChatSuperGroup, Title: fmt.Sprintf("Group %d", id)}
+}
+
+// Private возвращает личный чат с пользователем u
+func Private(u *tb.User) *tb.Chat {
+	return &tb.Chat{ID: u.ID, Type: tb.ChatPrivate, FirstName: u.FirstName, Username: u.Username}
+}
+
+// PushUpdate ставит обновление в очередь бота. Нулевой ID заменяется следующим по порядку.
+// Возвращает ID обновления.
+func (b *Bot) PushUpdate(u tb.Update) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if u.ID == 0 {
+		u.ID = b.lastUpdate + 1
+	}
+	b.lastUpdate = max(b.lastUpdate, u.ID)
+	b.updates = append(b.updates, u)
+	b.notify()
+	return u.ID
+}
+
+// PushMessage ставит в очередь входящее сообщение m и запоминает его, чтобы на него
+// можно было ответить или удалить. Нулевые ID и время заполняются.
+// Возвращает сообщение в том виде, в котором его получит бот.
+func (b *Bot) PushMessage(m tb.Message) *tb.Message {
+	b.mu.Lock()
+	if m.ID == 0 {
+		m.ID = b.nextMessageID()
+	}
+	if m.Unixtime == 0 {
+		m.Unixtime = time.Now().Unix()
+	}
+	if m.Chat != nil {
+		b.chats[m.Chat.ID] = m.Chat
+	}
+	msg := &m
+	b.remember(msg)
+	b.mu.Unlock()
+
+	b.PushUpdate(tb.Update{Message: msg})
+	return msg
+}
+
+// PushText ставит в очередь текстовое сообщение пользователя from в чате chat
+func (b *Bot) PushText(chat *tb.Chat, from *tb.User, text string) *tb.Message {
+	return b.PushMessage(tb.Message{Chat: chat, Sender: from, Text: text})
+}
+
+// PushReply ставит в очередь текстовый реплай пользователя from на сообщение to
+func (b *Bot) PushReply(to *tb.Message, from *tb.User, text string) *tb.Message {
+	return b.PushMessage(tb.Message{Chat: to.Chat, Sender: from, Text: text, ReplyTo: to})
+}
+
+// PushReaction ставит в очередь реакцию emoji пользователя from на сообщение to.
+// Бот получит её, только если запросил message_reaction в allowed_updates, как в Telegram.
+// Возвращает ID обновления.
+func (b *Bot) PushReaction(to *tb.Message, from *tb.User, emoji string) int {
+	return b.PushUpdate(tb.Update{MessageReaction: &tb.MessageReaction{
+		Chat:         to.Chat,
+		MessageID:    to.ID,
+		User:         from,
+		DateUnixtime: time.Now().Unix(),
+		OldReaction:  []tb.Reaction{},
+		NewReaction:  []tb.Reaction{{Type: "emoji", Emoji: emoji}},
+	}})
+}
+
+// Handle подменяет обработку метода method, например чтобы вернуть ошибку
+// (TooManyRequests, &Error{Code: 403, ...}). nil возвращает обычную обработку.
+func (b *Bot) Handle(method string, fn HandlerFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if fn == nil {
+		delete(b.handlers, method)
+		return
+	}
+	b.handlers[method] = fn
+}
+
+// Calls возвращает запросы к методу method в порядке поступления (пустой method — все запросы)
+func (b *Bot) Calls(method string) []Call {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.callsLocked(method)
+}
+
+// WaitCalls ждёт, пока бот сделает не меньше n запросов к методу method, и возвращает их.
+// Если за timeout запросов меньше, возвращает полученные и ошибку.
+func (b *Bot) WaitCalls(method string, n int, timeout time.Duration) ([]Call, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		b.mu.Lock()
+		calls := b.callsLocked(method)
+		changed := b.changed
+		b.mu.Unlock()
+		if len(calls) >= n {
+			return calls, nil
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return calls, fmt.Errorf("telegramtest: %d %s call(s) after %s, want %d", len(calls), method, timeout, n)
+		}
+	}
+}
+
+// Sent возвращает сообщения, отправленные ботом через sendMessage, в порядке отправки
+func (b *Bot) Sent() []*tb.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.sent)
+}
+
+// Pending возвращает число обновлений, которые бот ещё не подтвердил
+func (b *Bot) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.updates)
+}
+
+// record добавляет запрос в журнал
+func (b *Bot) record(call Call) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls = append(b.calls, call)
+	b.notify()
+}
+
+// callsLocked возвращает копию журнала запросов к методу. Вызывается под b.mu.
+func (b *Bot) callsLocked(method string) []Call {
+	var calls []Call
+	for _, c := range b.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// notify будит всех, кто ждёт изменений. Вызывается под b.mu.
+func (b *Bot) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// nextMessageID возвращает ID следующего сообщения. Вызывается под b.mu.
+func (b *Bot) nextMessageID() int {
+	b.lastMessage++
+	return b.lastMessage
+}
+
+// remember запоминает сообщение для ответов и удаления. Вызывается под b.mu.
+func (b *Bot) remember(m *tb.Message) {
+	if m.Chat == nil {
+		return
+	}
+	b.messages[messageKey{chat: m.Chat.ID, id: m.ID}] = m
+	// ID сообщений, заданные тестом, не должны совпасть со следующими
+	b.lastMessage = max(b.lastMessage, m.ID)
+}
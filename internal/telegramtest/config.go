@@ -0,0 +1,59 @@
+package telegramtest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/st-kuptsov/balabol/config" // загрузка тестовой конфигурации
+)
+
+// WriteConfig записывает config.yaml с содержимым conf во временный каталог теста
+// и возвращает путь к файлу. Пары replace (шаблон, значение), как у strings.NewReplacer,
+// подставляются в conf перед записью.
+//
+// Пример:
+//
+//	path := telegramtest.WriteConfig(t, "rule_sources:\n  - name: remote\n    url: \"{url}\"\n", "{url}", origin.URL)
+func WriteConfig(t testing.TB, conf string, replace ...string) string {
+	t.Helper()
+	if len(replace) > 0 {
+		conf = strings.NewReplacer(replace...).Replace(conf)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, conf)
+	return path
+}
+
+// WriteConfig записывает конфигурацию бота с токеном token, который ходит в этот сервер:
+// secrets.yaml с токеном и config.yaml с содержимым conf, в котором {secrets} заменяется
+// путём к секретам, а {api_url} — адресом сервера. Возвращает путь к config.yaml.
+//
+// Пример:
+//
+//	path := srv.WriteConfig(t, "123456:TEST", "secrets: \"{secrets}\"\ntelegram:\n  api_url: \"{api_url}\"\n")
+func (s *Server) WriteConfig(t testing.TB, token, conf string) string {
+	t.Helper()
+	secrets := filepath.Join(t.TempDir(), "secrets.yaml")
+	writeFile(t, secrets, "telegram:\n  token: \""+token+"\"\n")
+	return WriteConfig(t, conf, "{secrets}", secrets, "{api_url}", s.URL)
+}
+
+// LoadBot загружает конфигурацию из path (config.GetConfig) и возвращает настройки первого бота
+func LoadBot(t testing.TB, path string) *config.BotConfig {
+	t.Helper()
+	cfg, err := config.GetConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &cfg.Bots[0]
+}
+
+// writeFile записывает файл конфигурации, завершая тест при ошибке
+func writeFile(t testing.TB, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,388 @@
+package telegramtest
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	tb "gopkg.in/telebot.v3" // типы Bot API
+)
+
+// defaultUpdates — типы обновлений, которые Telegram не присылает без явного allowed_updates
+var defaultUpdates = []string{"chat_member", "message_reaction", "message_reaction_count"}
+
+// webhookRetry — пауза перед повторной доставкой обновления, которое webhook не принял
+const webhookRetry = 100 * time.Millisecond
+
+// webhook — зарегистрированный webhook бота
+type webhook struct {
+	url    string
+	secret string        // secret_token: заголовок X-Telegram-Bot-Api-Secret-Token
+	stop   chan struct{} // закрывается при удалении или замене webhook
+}
+
+// call выполняет метод Bot API: подменённый обработчик или встроенную реализацию
+func (b *Bot) call(r *http.Request, call Call) (any, error) {
+	b.mu.Lock()
+	handler := b.handlers[call.Method]
+	b.mu.Unlock()
+	if handler != nil {
+		result, err := handler(call)
+		if err != nil || result != nil {
+			return result, err
+		}
+	}
+
+	p := call.Params
+	switch call.Method {
+	case "getMe":
+		return b.Me, nil
+	case "getUpdates":
+		return b.getUpdates(r, p)
+	case "sendMessage":
+		return b.sendMessage(p)
+	case "deleteMessage":
+		return b.deleteMessage(p)
+	case "getChatMember":
+		return b.getChatMember(p)
+	case "setWebhook":
+		return b.setWebhook(p)
+	case "deleteWebhook":
+		return b.deleteWebhook(p)
+	case "getWebhookInfo":
+		return b.webhookInfo(), nil
+	case "pinChatMessage", "unpinChatMessage", "unpinAllChatMessages",
+		"restrictChatMember", "banChatMember", "unbanChatMember",
+		"sendChatAction", "setMyCommands", "deleteMyCommands", "leaveChat":
+		return true, nil
+	}
+	return nil, &Error{Code: http.StatusNotFound, Description: "Not Found"}
+}
+
+// getUpdates возвращает неподтверждённые обновления, начиная с offset.
+// Если обновлений нет, ждёт их до timeout секунд, как long polling Telegram.
+func (b *Bot) getUpdates(r *http.Request, p map[string]string) (any, error) {
+	offset, _ := strconv.Atoi(p["offset"])
+	timeout, _ := strconv.Atoi(p["timeout"])
+	limit, err := strconv.Atoi(p["limit"])
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 100
+	}
+	deadline := time.NewTimer(time.Duration(timeout) * time.Second)
+	defer deadline.Stop()
+
+	b.mu.Lock()
+	if b.webhook != nil {
+		b.mu.Unlock()
+		return nil, &Error{Code: http.StatusConflict, Description: "Conflict: can't use getUpdates method while webhook is active; use deleteWebhook to delete the webhook first"}
+	}
+	if allowed, ok := p["allowed_updates"]; ok {
+		b.setAllowed(allowed)
+	}
+	b.mu.Unlock()
+
+	for {
+		b.mu.Lock()
+		// Обновления с ID меньше offset подтверждены и больше не возвращаются
+		i := 0
+		for i < len(b.updates) && b.updates[i].ID < offset {
+			i++
+		}
+		b.updates = b.updates[i:]
+		updates := b.deliverable()
+		changed := b.changed
+		b.mu.Unlock()
+
+		if len(updates) > 0 {
+			return updates[:min(limit, len(updates))], nil
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return []tb.Update{}, nil
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		case <-b.server.closed:
+			return []tb.Update{}, nil
+		}
+	}
+}
+
+// sendMessage запоминает сообщение бота и возвращает его с новым ID
+func (b *Bot) sendMessage(p map[string]string) (any, error) {
+	chatID, err := strconv.ParseInt(p["chat_id"], 10, 64)
+	if err != nil {
+		return nil, badRequest("chat not found")
+	}
+	if p["text"] == "" {
+		return nil, badRequest("message text is empty")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replyTo *tb.Message
+	if replyID, allowWithout := replyParams(p); replyID != 0 {
+		replyTo = b.messages[messageKey{chat: chatID, id: replyID}]
+		if replyTo == nil && !allowWithout {
+			return nil, badRequest("message to be replied not found")
+		}
+	}
+
+	msg := &tb.Message{
+		ID:       b.nextMessageID(),
+		Unixtime: time.Now().Unix(),
+		Chat:     b.chat(chatID),
+		Sender:   b.Me,
+		Text:     p["text"],
+		ReplyTo:  replyTo,
+	}
+	b.remember(msg)
+	b.sent = append(b.sent, msg)
+	return msg, nil
+}
+
+// replyParams возвращает ID сообщения, на которое отвечает бот, из reply_to_message_id
+// или reply_parameters, и разрешена ли отправка без него
+func replyParams(p map[string]string) (id int, allowWithout bool) {
+	id, _ = strconv.Atoi(p["reply_to_message_id"])
+	allowWithout = p["allow_sending_without_reply"] == "true"
+	if raw := p["reply_parameters"]; raw != "" {
+		var rp struct {
+			MessageID    int  `json:"message_id"`
+			AllowWithout bool `json:"allow_sending_without_reply"`
+		}
+		if json.Unmarshal([]byte(raw), &rp) == nil {
+			id, allowWithout = rp.MessageID, rp.AllowWithout
+		}
+	}
+	return id, allowWithout
+}
+
+// deleteMessage удаляет известное серверу сообщение
+func (b *Bot) deleteMessage(p map[string]string) (any, error) {
+	chatID, _ := strconv.ParseInt(p["chat_id"], 10, 64)
+	msgID, _ := strconv.Atoi(p["message_id"])
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := messageKey{chat: chatID, id: msgID}
+	if b.messages[key] == nil {
+		return nil, badRequest("message to delete not found")
+	}
+	delete(b.messages, key)
+	return true, nil
+}
+
+// getChatMember возвращает администратора со всеми правами для самого бота
+// и обычного участника для остальных пользователей
+func (b *Bot) getChatMember(p map[string]string) (any, error) {
+	userID, err := strconv.ParseInt(p["user_id"], 10, 64)
+	if err != nil {
+		return nil, badRequest("invalid user_id specified")
+	}
+	if userID == b.Me.ID {
+		return map[string]any{
+			"status":               "administrator",
+			"user":                 b.Me,
+			"can_manage_chat":      true,
+			"can_delete_messages":  true,
+			"can_restrict_members": true,
+			"can_pin_messages":     true,
+		}, nil
+	}
+	return map[string]any{"status": "member", "user": User(userID)}, nil
+}
+
+// setWebhook регистрирует webhook и начинает доставлять на него обновления
+func (b *Bot) setWebhook(p map[string]string) (any, error) {
+	if p["url"] == "" {
+		return b.deleteWebhook(p)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stopWebhook()
+	if p["drop_pending_updates"] == "true" {
+		b.updates = nil
+	}
+	if allowed, ok := p["allowed_updates"]; ok {
+		b.setAllowed(allowed)
+	}
+	hook := &webhook{url: p["url"], secret: p["secret_token"], stop: make(chan struct{})}
+	b.webhook = hook
+	go b.deliver(hook)
+	return true, nil
+}
+
+// deleteWebhook удаляет webhook: обновления снова доступны через getUpdates
+func (b *Bot) deleteWebhook(p map[string]string) (any, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stopWebhook()
+	if p["drop_pending_updates"] == "true" {
+		b.updates = nil
+	}
+	return true, nil
+}
+
+// webhookInfo возвращает ответ getWebhookInfo
+func (b *Bot) webhookInfo() map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	info := map[string]any{"url": "", "pending_update_count": len(b.updates)}
+	if b.webhook != nil {
+		info["url"] = b.webhook.url
+	}
+	if b.allowed != nil {
+		info["allowed_updates"] = b.allowed
+	}
+	return info
+}
+
+// stopWebhook останавливает доставку на текущий webhook. Вызывается под b.mu.
+func (b *Bot) stopWebhook() {
+	if b.webhook != nil {
+		close(b.webhook.stop)
+		b.webhook = nil
+		b.notify()
+	}
+}
+
+// deliver отправляет обновления на webhook по одному, пока он зарегистрирован.
+// Обновление, которое webhook не принял (не 2xx), доставляется повторно, как в Telegram.
+func (b *Bot) deliver(hook *webhook) {
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		// Сертификат webhook в тестах обычно самоподписанный
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	defer client.CloseIdleConnections()
+
+	for {
+		b.mu.Lock()
+		updates := b.deliverable()
+		changed := b.changed
+		b.mu.Unlock()
+
+		if len(updates) == 0 {
+			select {
+			case <-changed:
+				continue
+			case <-hook.stop:
+				return
+			case <-b.server.closed:
+				return
+			}
+		}
+
+		next := updates[0]
+		if err := postUpdate(client, hook, next); err != nil {
+			select {
+			case <-time.After(webhookRetry):
+				continue
+			case <-hook.stop:
+				return
+			case <-b.server.closed:
+				return
+			}
+		}
+
+		b.mu.Lock()
+		if i := slices.IndexFunc(b.updates, func(u tb.Update) bool { return u.ID == next.ID }); i >= 0 {
+			b.updates = slices.Delete(b.updates, i, i+1)
+			b.notify()
+		}
+		b.mu.Unlock()
+	}
+}
+
+// postUpdate отправляет одно обновление на webhook
+func postUpdate(client *http.Client, hook *webhook, u tb.Update) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, hook.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hook.secret != "" {
+		req.Header.Set("X-Telegram-Bot-Api-Secret-Token", hook.secret)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// setAllowed запоминает allowed_updates; пустой список возвращает набор по умолчанию.
+// Вызывается под b.mu.
+func (b *Bot) setAllowed(raw string) {
+	var allowed []string
+	if err := json.Unmarshal([]byte(raw), &allowed); err != nil || len(allowed) == 0 {
+		b.allowed = nil
+		return
+	}
+	b.allowed = allowed
+}
+
+// deliverable удаляет из очереди обновления, которые бот не запрашивал (allowed_updates),
+// и возвращает копию оставшихся. Вызывается под b.mu.
+func (b *Bot) deliverable() []tb.Update {
+	b.updates = slices.DeleteFunc(b.updates, func(u tb.Update) bool {
+		kind := updateType(u)
+		if b.allowed == nil {
+			return slices.Contains(defaultUpdates, kind)
+		}
+		return !slices.Contains(b.allowed, kind)
+	})
+	return slices.Clone(b.updates)
+}
+
+// updateType возвращает тип обновления — имя заполненного поля (message, message_reaction и т.п.)
+func updateType(u tb.Update) string {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return ""
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return ""
+	}
+	for name, value := range fields {
+		if name != "update_id" && string(value) != "null" {
+			return name
+		}
+	}
+	return ""
+}
+
+// chat возвращает известный серверу чат или создаёт его по ID:
+// положительный — личный чат, отрицательный — супергруппа. Вызывается под b.mu.
+func (b *Bot) chat(id int64) *tb.Chat {
+	if c := b.chats[id]; c != nil {
+		return c
+	}
+	c := &tb.Chat{ID: id, Type: tb.ChatPrivate}
+	if id < 0 {
+		c.Type = tb.ChatSuperGroup
+	}
+	b.chats[id] = c
+	return c
+}
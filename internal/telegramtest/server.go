@@ -0,0 +1,188 @@
+package telegramtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server — фейковый сервер Telegram Bot API в том же процессе: бот и всё приложение
+// проверяются без сети и настоящего токена. Для каждого токена ведётся отдельный бот
+// со своей очередью обновлений, сообщениями и журналом вызовов (см. Bot).
+//
+// Пример:
+//
+//	srv := telegramtest.NewServer()
+//	defer srv.Close()
+//	fake := srv.Bot("123456:TEST")
+//	// в конфигурации: telegram.api_url = srv.URL, токен бота — "123456:TEST"
+//	fake.PushText(telegramtest.Group(-100), telegramtest.User(7), "привет")
+//	calls, err := fake.WaitCalls("sendMessage", 1, 5*time.Second)
+type Server struct {
+	URL string // адрес сервера для telegram.api_url
+
+	srv    *httptest.Server
+	closed chan struct{} // закрывается в Close: прерывает ожидающие getUpdates
+
+	mu   sync.Mutex
+	bots map[string]*Bot // по токену
+}
+
+// NewServer запускает фейковый сервер Bot API на локальном порту.
+// Сервер нужно остановить вызовом Close.
+func NewServer() *Server {
+	s := &Server{
+		closed: make(chan struct{}),
+		bots:   make(map[string]*Bot),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close прерывает ожидающие getUpdates, останавливает доставку webhook и сервер
+func (s *Server) Close() {
+	select {
+	case <-s.closed:
+		return
+	default:
+	}
+	close(s.closed)
+	s.srv.Close()
+}
+
+// Bot возвращает бота с токеном token, создавая его при первом обращении.
+// Бот создаётся и при первом запросе с этим токеном, поэтому обращаться
+// к Bot до запуска приложения необязательно.
+func (s *Server) Bot(token string) *Bot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.bots[token]
+	if b == nil {
+		b = newBot(s, token)
+		s.bots[token] = b
+	}
+	return b
+}
+
+// Error — ошибка Bot API, которую возвращает обработчик метода (см. Bot.Handle)
+type Error struct {
+	Code        int    // HTTP-статус и error_code (0 — 400)
+	Description string // описание ошибки, например "Forbidden: bot was blocked by the user"
+	RetryAfter  int    // parameters.retry_after для 429 Too Many Requests
+}
+
+// Error возвращает описание ошибки
+func (e *Error) Error() string {
+	return fmt.Sprintf("telegram: %s (%d)", e.Description, e.code())
+}
+
+// code возвращает HTTP-статус ошибки
+func (e *Error) code() int {
+	if e.Code == 0 {
+		return http.StatusBadRequest
+	}
+	return e.Code
+}
+
+// TooManyRequests возвращает ошибку 429 с паузой retryAfter секунд
+func TooManyRequests(retryAfter int) *Error {
+	return &Error{
+		Code:        http.StatusTooManyRequests,
+		Description: fmt.Sprintf("Too Many Requests: retry after %d", retryAfter),
+		RetryAfter:  retryAfter,
+	}
+}
+
+// badRequest возвращает ошибку 400 с описанием в формате Telegram
+func badRequest(description string) *Error {
+	return &Error{Code: http.StatusBadRequest, Description: "Bad Request: " + description}
+}
+
+// serveHTTP разбирает запрос вида /bot<token>/<method> и вызывает метод бота
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	token, method, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+	if !ok || !strings.HasPrefix(r.URL.Path, "/bot") || token == "" || method == "" {
+		writeResult(w, nil, &Error{Code: http.StatusNotFound, Description: "Not Found"})
+		return
+	}
+	params, err := readParams(r)
+	if err != nil {
+		writeResult(w, nil, badRequest(err.Error()))
+		return
+	}
+
+	b := s.Bot(token)
+	call := Call{Method: method, Params: params, Time: time.Now()}
+	b.record(call)
+	result, err := b.call(r, call)
+	writeResult(w, result, err)
+}
+
+// readParams читает параметры метода: JSON (так отправляет telebot), форму или multipart.
+// Значения, которые не являются строками JSON (числа, объекты), сохраняются как JSON.
+func readParams(r *http.Request) (map[string]string, error) {
+	params := make(map[string]string)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(body) == 0 {
+			return params, nil
+		}
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return nil, fmt.Errorf("can't parse JSON: %w", err)
+		}
+		for k, v := range raw {
+			var s string
+			if err := json.Unmarshal(v, &s); err == nil {
+				params[k] = s
+			} else {
+				params[k] = string(v)
+			}
+		}
+		return params, nil
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return nil, err
+	}
+	for k, v := range r.Form {
+		params[k] = v[0]
+	}
+	return params, nil
+}
+
+// writeResult отвечает в формате Bot API: {"ok": true, "result": ...} или описание ошибки
+func writeResult(w http.ResponseWriter, result any, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err == nil {
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+		return
+	}
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = &Error{Code: http.StatusInternalServerError, Description: "Internal Server Error: " + err.Error()}
+	}
+	resp := map[string]any{
+		"ok":          false,
+		"error_code":  apiErr.code(),
+		"description": apiErr.Description,
+	}
+	if apiErr.RetryAfter > 0 {
+		resp["parameters"] = map[string]any{"retry_after": apiErr.RetryAfter}
+	}
+	w.WriteHeader(apiErr.code())
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config" // настройки аудита
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Hit — одно сработавшее правило в записи аудита
+type Hit struct {
+	Rule       string `json:"rule"`                 // текст правила (Rule.Text)
+	Pattern    string `json:"pattern"`              // регулярное выражение правила
+	Position   int    `json:"position"`             // позиция совпадения в очищенном тексте
+	Shadow     bool   `json:"shadow,omitempty"`     // теневое правило: на ответ и действия не повлияло
+	Experiment string `json:"experiment,omitempty"` // эксперимент правила
+	Variant    string `json:"variant,omitempty"`    // выбранный вариант ответа
+}
+
+// Action — действие модерации, выполненное по сообщению
+type Action struct {
+	Type   string `json:"type"`             // delete, restrict, ban, pin или warn
+	Result string `json:"result"`           // done, dry_run, no_rights, failed или unsupported
+	Detail string `json:"detail,omitempty"` // срок ограничения или счётчик предупреждений
+}
+
+// Record — запись аудита об одном ответе бота
+type Record struct {
+	Time      time.Time `json:"time"`              // время отправки ответа
+	Bot       string    `json:"bot"`               // имя бота
+	ChatID    int64     `json:"chat_id"`           // чат
+	UserID    int64     `json:"user_id"`           // отправитель исходного сообщения
+	Username  string    `json:"username"`          // username отправителя
+	MessageID int       `json:"message_id"`        // исходное сообщение
+	Text      string    `json:"text"`              // очищенный текст (с учётом log_settings.redact)
+	Hits      []Hit     `json:"hits"`              // все сработавшие правила
+	Reply     string    `json:"reply"`             // отправленный ответ
+	Lang      string    `json:"lang"`              // язык ответа (i18n)
+	Actions   []Action  `json:"actions,omitempty"` // действия модерации
+	Error     string    `json:"error,omitempty"`   // ошибка отправки, если ответ не доставлен
+	DryRun    bool      `json:"dry_run,omitempty"` // режим dry_run: ответ и действия не отправлялись
+}
+
+// Log пишет записи аудита в отдельный файл с ротацией, по одной JSON-строке на ответ.
+// Нулевой или выключенный Log ничего не пишет.
+type Log struct {
+	mu      sync.Mutex
+	conf    config.AuditConfig // текущие настройки
+	out     *lumberjack.Logger // nil, если аудит выключен
+	applied bool               // настройки уже применялись
+}
+
+// New создаёт журнал аудита по настройкам
+func New(conf config.AuditConfig) *Log {
+	l := &Log{}
+	l.Reload(conf)
+	return l
+}
+
+// Reload применяет новые настройки, если они изменились.
+// Возвращает true, если файл журнала был переоткрыт.
+func (l *Log) Reload(conf config.AuditConfig) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.applied && conf == l.conf {
+		return false
+	}
+
+	if l.out != nil {
+		_ = l.out.Close()
+		l.out = nil
+	}
+	if conf.Enabled {
+		l.out = &lumberjack.Logger{
+			Filename:   filepath.Join(conf.Directory, conf.Filename),
+			MaxSize:    conf.MaxSize,
+			MaxBackups: conf.MaxBackups,
+			MaxAge:     conf.MaxAge,
+			Compress:   conf.Compress,
+		}
+	}
+	l.conf = conf
+	l.applied = true
+	return true
+}
+
+// Enabled сообщает, включён ли аудит
+func (l *Log) Enabled() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.out != nil
+}
+
+// Write добавляет запись в журнал
+func (l *Log) Write(rec Record) error {
+	if l == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.out == nil {
+		return nil
+	}
+	_, err = l.out.Write(data)
+	return err
+}
+
+// Close закрывает файл журнала
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.out == nil {
+		return nil
+	}
+	err := l.out.Close()
+	l.out = nil
+	return err
+}
@@ -0,0 +1,115 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config" // настройки журнала
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Причины, по которым сообщение не доставлено (поле reason и лейбл метрики bot_send_dead_letters_total)
+const (
+	ReasonRejected  = "rejected"          // Telegram отклонил сообщение (4xx), повтор не поможет
+	ReasonExhausted = "retries_exhausted" // исчерпаны попытки send_queue.max_attempts
+	ReasonExpired   = "expired"           // сообщение пролежало в очереди дольше send_queue.max_age
+	ReasonQueueFull = "queue_full"        // очередь бота переполнена (send_queue.size)
+	ReasonShutdown  = "shutdown"          // бот остановлен раньше, чем очередь опустела
+)
+
+// Record — запись о недоставленном сообщении
+type Record struct {
+	Time     time.Time `json:"time"`               // когда сообщение признано недоставленным
+	Bot      string    `json:"bot"`                // имя бота
+	ChatID   int64     `json:"chat_id"`            // чат получателя
+	ReplyTo  int       `json:"reply_to,omitempty"` // сообщение, на которое отвечал бот
+	Kind     string    `json:"kind"`               // reply, warn, command или digest
+	Text     string    `json:"text"`               // текст сообщения
+	Reason   string    `json:"reason"`             // причина (Reason*)
+	Attempts int       `json:"attempts"`           // сделано попыток отправки
+	Error    string    `json:"error,omitempty"`    // последняя ошибка Bot API
+	Queued   time.Time `json:"queued"`             // когда сообщение поставлено в очередь
+}
+
+// Log пишет недоставленные сообщения в отдельный файл с ротацией, по одной JSON-строке на сообщение.
+// Нулевой или выключенный Log ничего не пишет.
+type Log struct {
+	mu      sync.Mutex
+	conf    config.DeadLetterConfig // текущие настройки
+	out     *lumberjack.Logger      // nil, если журнал выключен
+	applied bool                    // настройки уже применялись
+}
+
+// New создаёт журнал недоставленных сообщений по настройкам
+func New(conf config.DeadLetterConfig) *Log {
+	l := &Log{}
+	l.Reload(conf)
+	return l
+}
+
+// Reload применяет новые настройки, если они изменились.
+// Возвращает true, если файл журнала был переоткрыт.
+func (l *Log) Reload(conf config.DeadLetterConfig) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.applied && conf == l.conf {
+		return false
+	}
+
+	if l.out != nil {
+		_ = l.out.Close()
+		l.out = nil
+	}
+	if conf.Enabled {
+		l.out = &lumberjack.Logger{
+			Filename:   filepath.Join(conf.Directory, conf.Filename),
+			MaxSize:    conf.MaxSize,
+			MaxBackups: conf.MaxBackups,
+			MaxAge:     conf.MaxAge,
+			Compress:   conf.Compress,
+		}
+	}
+	l.conf = conf
+	l.applied = true
+	return true
+}
+
+// Write добавляет запись в журнал
+func (l *Log) Write(rec Record) error {
+	if l == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.out == nil {
+		return nil
+	}
+	_, err = l.out.Write(data)
+	return err
+}
+
+// Close закрывает файл журнала
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.out == nil {
+		return nil
+	}
+	err := l.out.Close()
+	l.out = nil
+	return err
+}
@@ -0,0 +1,335 @@
+package experiments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Исходы ответа (лейбл outcome метрики bot_experiment_outcomes_total)
+const (
+	OutcomeReply    = "reply"    // реплай на сообщение бота
+	OutcomeReaction = "reaction" // реакция на сообщение бота
+)
+
+// Choice — вариант эксперимента, которым ответил бот
+type Choice struct {
+	Experiment string `json:"experiment"` // имя эксперимента
+	Variant    string `json:"variant"`    // имя варианта
+}
+
+// Counts — счётчики одного варианта
+type Counts struct {
+	Exposures int `json:"exposures"` // доставленные ответы с этим вариантом
+	Replies   int `json:"replies"`   // ответы, на которые ответили реплаем в течение окна
+	Reactions int `json:"reactions"` // ответы, на которые поставили реакцию в течение окна
+	Engaged   int `json:"engaged"`   // ответы с реплаем или реакцией (каждый учитывается один раз)
+}
+
+// exposure — сообщение бота, исход которого ещё ждём
+type exposure struct {
+	Bot     string    `json:"bot"`               // имя бота
+	Choices []Choice  `json:"choices"`           // варианты в сообщении (при bot_mode: all их может быть несколько)
+	Sent    time.Time `json:"sent"`              // когда сообщение доставлено
+	Replied bool      `json:"replied,omitempty"` // реплай уже учтён
+	Reacted bool      `json:"reacted,omitempty"` // реакция уже учтена
+}
+
+// file — содержимое файла результатов
+type file struct {
+	Results map[string]map[string]map[string]*Counts `json:"results"`           // бот → эксперимент → вариант → счётчики
+	Pending map[string]*exposure                     `json:"pending,omitempty"` // ключ — бот:чат:сообщение
+}
+
+// Store хранит результаты экспериментов и сообщения бота, исход которых ещё ждём.
+// Изменения накапливаются в памяти и записываются в файл вызовом Flush.
+// Нулевой Store не пригоден к использованию — создавайте через Open.
+type Store struct {
+	mu    sync.Mutex
+	path  string // путь к файлу
+	data  file
+	dirty bool // есть несохранённые изменения
+}
+
+// Open загружает результаты из файла path. Отсутствующий файл — пустые результаты.
+func Open(path string) (*Store, error) {
+	data, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{path: path, data: data}, nil
+}
+
+// Reload переключает хранилище на другой файл, если путь изменился.
+// Несохранённые изменения сначала записываются в прежний файл.
+// Возвращает true, если файл был перечитан. При ошибке чтения остаётся прежний файл.
+func (s *Store) Reload(path string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if path == s.path {
+		return false, nil
+	}
+	data, err := load(path)
+	if err != nil {
+		return false, err
+	}
+	if err := s.save(); err != nil {
+		return false, err
+	}
+	s.path, s.data = path, data
+	return true, nil
+}
+
+// Expose учитывает доставленный ответ бота с вариантами choices и начинает ждать его исхода
+//
+// Параметры:
+// - bot, chatID, messageID: доставленное сообщение бота
+// - choices: варианты экспериментов, из которых собран ответ
+// - now: время доставки (от него отсчитывается окно исхода)
+func (s *Store) Expose(bot string, chatID int64, messageID int, choices []Choice, now time.Time) {
+	if len(choices) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range choices {
+		s.counts(bot, c).Exposures++
+	}
+	if s.data.Pending == nil {
+		s.data.Pending = make(map[string]*exposure)
+	}
+	s.data.Pending[key(bot, chatID, messageID)] = &exposure{Bot: bot, Choices: choices, Sent: now}
+	s.dirty = true
+}
+
+// Outcome учитывает реплай или реакцию на сообщение бота.
+// Каждый вид исхода учитывается для сообщения один раз; сообщения без экспериментов
+// и исходы позже window после доставки не учитываются.
+//
+// Параметры:
+// - bot, chatID, messageID: сообщение бота, на которое ответили или отреагировали
+// - outcome: OutcomeReply или OutcomeReaction
+// - now, window: текущее время и окно исхода (experiments.window)
+//
+// Возвращает варианты, которым засчитан исход (nil — исход не учтён).
+func (s *Store) Outcome(bot string, chatID int64, messageID int, outcome string, now time.Time, window time.Duration) []Choice {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(bot, chatID, messageID)
+	e := s.data.Pending[k]
+	if e == nil {
+		return nil
+	}
+	if now.Sub(e.Sent) > window {
+		delete(s.data.Pending, k)
+		s.dirty = true
+		return nil
+	}
+
+	first := !e.Replied && !e.Reacted
+	switch outcome {
+	case OutcomeReply:
+		if e.Replied {
+			return nil
+		}
+		e.Replied = true
+	case OutcomeReaction:
+		if e.Reacted {
+			return nil
+		}
+		e.Reacted = true
+	default:
+		return nil
+	}
+
+	for _, c := range e.Choices {
+		counts := s.counts(bot, c)
+		if outcome == OutcomeReply {
+			counts.Replies++
+		} else {
+			counts.Reactions++
+		}
+		if first {
+			counts.Engaged++
+		}
+	}
+	// Оба исхода учтены — ждать больше нечего
+	if e.Replied && e.Reacted {
+		delete(s.data.Pending, k)
+	}
+	s.dirty = true
+	return e.Choices
+}
+
+// Prune удаляет сообщения, окно исхода которых истекло
+func (s *Store) Prune(now time.Time, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, e := range s.data.Pending {
+		if now.Sub(e.Sent) > window {
+			delete(s.data.Pending, k)
+			s.dirty = true
+		}
+	}
+}
+
+// counts возвращает счётчики варианта, создавая их при необходимости. Вызывается под s.mu.
+func (s *Store) counts(bot string, c Choice) *Counts {
+	if s.data.Results == nil {
+		s.data.Results = make(map[string]map[string]map[string]*Counts)
+	}
+	exps := s.data.Results[bot]
+	if exps == nil {
+		exps = make(map[string]map[string]*Counts)
+		s.data.Results[bot] = exps
+	}
+	variants := exps[c.Experiment]
+	if variants == nil {
+		variants = make(map[string]*Counts)
+		exps[c.Experiment] = variants
+	}
+	counts := variants[c.Variant]
+	if counts == nil {
+		counts = &Counts{}
+		variants[c.Variant] = counts
+	}
+	return counts
+}
+
+// VariantResult — итоги одного варианта
+type VariantResult struct {
+	Name string `json:"name"` // имя варианта
+	Counts
+	Pending int     `json:"pending"` // ответы без реплая и реакции, окно исхода которых ещё не истекло
+	Rate    float64 `json:"rate"`    // доля ответов с реплаем или реакцией среди ответов с известным исходом
+}
+
+// Result — итоги одного эксперимента одного бота
+type Result struct {
+	Bot        string          `json:"bot"`
+	Experiment string          `json:"experiment"`
+	Variants   []VariantResult `json:"variants"` // по имени варианта
+}
+
+// Report возвращает итоги экспериментов, отсортированные по боту и имени эксперимента.
+//
+// Параметры:
+// - bot: чьи эксперименты (пусто — всех ботов)
+// - now, window: текущее время и окно исхода; ответы моложе окна учитываются как pending
+// и не входят в знаменатель rate, чтобы свежие ответы не занижали долю
+func (s *Store) Report(bot string, now time.Time, window time.Duration) []Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Ответы без исхода, окно которых ещё не истекло: их исход неизвестен
+	pending := make(map[string]map[Choice]int)
+	for _, e := range s.data.Pending {
+		if now.Sub(e.Sent) > window || e.Replied || e.Reacted {
+			continue
+		}
+		if pending[e.Bot] == nil {
+			pending[e.Bot] = make(map[Choice]int)
+		}
+		for _, c := range e.Choices {
+			pending[e.Bot][c]++
+		}
+	}
+
+	var results []Result
+	for b, exps := range s.data.Results {
+		if bot != "" && b != bot {
+			continue
+		}
+		for name, variants := range exps {
+			res := Result{Bot: b, Experiment: name}
+			for v, c := range variants {
+				vr := VariantResult{Name: v, Counts: *c, Pending: pending[b][Choice{Experiment: name, Variant: v}]}
+				if decided := c.Exposures - vr.Pending; decided > 0 {
+					vr.Rate = float64(c.Engaged) / float64(decided)
+				}
+				res.Variants = append(res.Variants, vr)
+			}
+			sort.Slice(res.Variants, func(i, j int) bool { return res.Variants[i].Name < res.Variants[j].Name })
+			results = append(results, res)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Bot != results[j].Bot {
+			return results[i].Bot < results[j].Bot
+		}
+		return results[i].Experiment < results[j].Experiment
+	})
+	return results
+}
+
+// Flush записывает несохранённые изменения в файл
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+// key составляет ключ сообщения бота
+func key(bot string, chatID int64, messageID int) string {
+	return bot + ":" + strconv.FormatInt(chatID, 10) + ":" + strconv.Itoa(messageID)
+}
+
+// load читает файл результатов
+func load(path string) (file, error) {
+	var data file
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return data, nil
+	}
+	if err != nil {
+		return data, fmt.Errorf("experiments file: %w", err)
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return data, fmt.Errorf("experiments file %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// save атомарно записывает результаты, если есть несохранённые изменения:
+// во временный файл рядом и переименованием. Вызывается под s.mu.
+func (s *Store) save() error {
+	if !s.dirty {
+		return nil
+	}
+	data, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("experiments file: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("experiments file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("experiments file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("experiments file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("experiments file: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
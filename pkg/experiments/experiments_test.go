@@ -0,0 +1,186 @@
+package experiments
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Варианты тестового эксперимента
+var (
+	short = Choice{Experiment: "joke", Variant: "short"}
+	long  = Choice{Experiment: "joke", Variant: "long"}
+)
+
+// window — окно исхода в тестах
+const window = time.Hour
+
+// newStore создаёт пустое хранилище во временном каталоге
+func newStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "experiments.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// variant возвращает итоги варианта name эксперимента joke бота bot
+func variant(t *testing.T, results []Result, name string) VariantResult {
+	t.Helper()
+	for _, r := range results {
+		if r.Bot != "bot" || r.Experiment != "joke" {
+			continue
+		}
+		for _, v := range r.Variants {
+			if v.Name == name {
+				return v
+			}
+		}
+	}
+	t.Fatalf("variant %q not found in %+v", name, results)
+	return VariantResult{}
+}
+
+func TestOutcome(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	type event struct {
+		outcome string
+		after   time.Duration // время исхода после доставки
+		counted bool          // ожидается, что исход засчитан
+	}
+	for _, tc := range []struct {
+		name   string
+		events []event
+		want   Counts
+	}{
+		{"no outcome", nil, Counts{Exposures: 1}},
+		{"reply", []event{{OutcomeReply, time.Minute, true}}, Counts{Exposures: 1, Replies: 1, Engaged: 1}},
+		{"reply counted once", []event{
+			{OutcomeReply, time.Minute, true},
+			{OutcomeReply, 2 * time.Minute, false},
+		}, Counts{Exposures: 1, Replies: 1, Engaged: 1}},
+		{"reply and reaction engage once", []event{
+			{OutcomeReaction, time.Minute, true},
+			{OutcomeReply, 2 * time.Minute, true},
+			{OutcomeReaction, 3 * time.Minute, false},
+		}, Counts{Exposures: 1, Replies: 1, Reactions: 1, Engaged: 1}},
+		{"outside window", []event{{OutcomeReply, window + time.Second, false}}, Counts{Exposures: 1}},
+		{"expired message is forgotten", []event{
+			{OutcomeReply, window + time.Second, false},
+			{OutcomeReaction, time.Minute, false},
+		}, Counts{Exposures: 1}},
+		{"unknown outcome", []event{{"forward", time.Minute, false}}, Counts{Exposures: 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newStore(t)
+			s.Expose("bot", -100, 7, []Choice{short}, start)
+			for i, e := range tc.events {
+				got := s.Outcome("bot", -100, 7, e.outcome, start.Add(e.after), window)
+				if counted := len(got) > 0; counted != e.counted {
+					t.Errorf("event %d (%s after %s): counted = %v, want %v", i, e.outcome, e.after, counted, e.counted)
+				}
+			}
+			if got := variant(t, s.Report("bot", start.Add(2*window), window), "short").Counts; got != tc.want {
+				t.Errorf("counts = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOutcomeIgnoresOtherMessages(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := newStore(t)
+	s.Expose("bot", -100, 7, []Choice{short}, start)
+
+	for _, tc := range []struct {
+		bot     string
+		chat    int64
+		message int
+	}{
+		{"other", -100, 7},
+		{"bot", -200, 7},
+		{"bot", -100, 8},
+	} {
+		if got := s.Outcome(tc.bot, tc.chat, tc.message, OutcomeReply, start, window); got != nil {
+			t.Errorf("outcome for %s:%d:%d = %v, want nil", tc.bot, tc.chat, tc.message, got)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := newStore(t)
+
+	// short: 4 ответа — 2 с исходом, 1 без исхода с истёкшим окном, 1 свежий без исхода
+	s.Expose("bot", -100, 1, []Choice{short}, start)
+	s.Expose("bot", -100, 2, []Choice{short}, start)
+	s.Expose("bot", -100, 3, []Choice{short}, start)
+	s.Expose("bot", -100, 4, []Choice{short}, start.Add(90*time.Minute))
+	s.Outcome("bot", -100, 1, OutcomeReply, start.Add(time.Minute), window)
+	s.Outcome("bot", -100, 2, OutcomeReaction, start.Add(time.Minute), window)
+	s.Outcome("bot", -100, 2, OutcomeReply, start.Add(2*time.Minute), window)
+	// long: свежий ответ уже с реплаем не считается ожидающим
+	s.Expose("bot", -100, 5, []Choice{long}, start.Add(90*time.Minute))
+	s.Outcome("bot", -100, 5, OutcomeReply, start.Add(91*time.Minute), window)
+	// Эксперимент другого бота в отчёт бота bot не попадает
+	s.Expose("other", -100, 6, []Choice{short}, start)
+
+	now := start.Add(100 * time.Minute)
+	results := s.Report("bot", now, window)
+	if len(results) != 1 {
+		t.Fatalf("report has %d experiment(s), want 1: %+v", len(results), results)
+	}
+	if names := []string{results[0].Variants[0].Name, results[0].Variants[1].Name}; names[0] != "long" || names[1] != "short" {
+		t.Errorf("variants = %q, want sorted [long short]", names)
+	}
+
+	for _, tc := range []struct {
+		variant string
+		want    VariantResult
+	}{
+		// rate = engaged / (exposures - pending): свежий ответ без исхода не занижает долю
+		{"short", VariantResult{Name: "short", Counts: Counts{Exposures: 4, Replies: 2, Reactions: 1, Engaged: 2}, Pending: 1, Rate: 2.0 / 3}},
+		{"long", VariantResult{Name: "long", Counts: Counts{Exposures: 1, Replies: 1, Engaged: 1}, Rate: 1}},
+	} {
+		if got := variant(t, results, tc.variant); got != tc.want {
+			t.Errorf("%s = %+v, want %+v", tc.variant, got, tc.want)
+		}
+	}
+
+	// Все ответы ещё ожидают исхода: rate не определена и равна 0
+	fresh := newStore(t)
+	fresh.Expose("bot", -100, 1, []Choice{short}, start)
+	if got := variant(t, fresh.Report("", start, window), "short"); got.Pending != 1 || got.Rate != 0 {
+		t.Errorf("fresh = %+v, want pending 1 and rate 0", got)
+	}
+
+	if all := s.Report("", now, window); len(all) != 2 || all[0].Bot != "bot" || all[1].Bot != "other" {
+		t.Errorf("report for all bots = %+v, want bot and other", all)
+	}
+}
+
+func TestFlushAndReopen(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "experiments.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Expose("bot", -100, 1, []Choice{short}, start)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ожидающее исхода сообщение переживает перезапуск: реплай после него засчитывается
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Outcome("bot", -100, 1, OutcomeReply, start.Add(time.Minute), window); len(got) != 1 {
+		t.Fatalf("outcome after reopen = %v, want counted", got)
+	}
+	if got := variant(t, reopened.Report("bot", start.Add(2*window), window), "short").Counts; got != (Counts{Exposures: 1, Replies: 1, Engaged: 1}) {
+		t.Errorf("counts after reopen = %+v", got)
+	}
+}
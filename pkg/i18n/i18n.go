@@ -0,0 +1,178 @@
+package i18n
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/st-kuptsov/balabol/config" // настройки языка (секция i18n)
+)
+
+// Источники выбранного языка
+const (
+	SourceUser     = "user"     // настройка пользователя (i18n.users)
+	SourceChat     = "chat"     // настройка чата (i18n.chats)
+	SourceTelegram = "telegram" // language_code отправителя
+	SourceDefault  = "default"  // i18n.default_language
+)
+
+// fallbackLanguage — язык встроенных сообщений, если ни один язык цепочки не найден
+const fallbackLanguage = "ru"
+
+// Choice — язык, выбранный для ответа на сообщение
+type Choice struct {
+	Lang   string   // язык с наивысшим приоритетом (лейбл lang в метриках)
+	Source string   // откуда взят Lang
+	Chain  []string // языки по убыванию приоритета, без повторов
+}
+
+// Resolve выбирает язык ответа по цепочке: настройка пользователя, настройка чата,
+// language_code отправителя в Telegram, язык по умолчанию.
+//
+// Параметры:
+// - conf: секция i18n конфигурации
+// - chatID, userID: чат и отправитель сообщения (0 — неизвестен)
+// - languageCode: language_code отправителя (может быть пустым)
+//
+// Возвращает выбранный язык и цепочку для поиска переводов.
+func Resolve(conf config.I18nConfig, chatID, userID int64, languageCode string) Choice {
+	var c Choice
+	add := func(lang, source string) {
+		if lang == "" || slices.Contains(c.Chain, lang) {
+			return
+		}
+		if c.Lang == "" {
+			c.Lang, c.Source = lang, source
+		}
+		c.Chain = append(c.Chain, lang)
+	}
+
+	if userID != 0 {
+		add(conf.Users[userID], SourceUser)
+	}
+	if chatID != 0 {
+		add(conf.Chats[chatID], SourceChat)
+	}
+	add(config.NormalizeLanguage(languageCode), SourceTelegram)
+	add(conf.DefaultLanguage, SourceDefault)
+	return c
+}
+
+// Ключи системных сообщений бота
+const (
+	MsgLangCurrent        = "lang_current"         // ответ на /lang: язык и его источник
+	MsgLangSourceUser     = "lang_source_user"     // источник: настройка пользователя
+	MsgLangSourceChat     = "lang_source_chat"     // источник: настройка чата
+	MsgLangSourceTelegram = "lang_source_telegram" // источник: язык Telegram
+	MsgLangSourceDefault  = "lang_source_default"  // источник: язык по умолчанию
+	MsgWarnIssued         = "warn_issued"          // предупреждение без эскалации: кому и номер
+	MsgWarnIssuedOf       = "warn_issued_of"       // предупреждение: кому, номер и сколько до последней ступени
+	MsgStatsTitle         = "stats_title"          // заголовок ответа на /stats
+	MsgStatsDay           = "stats_day"            // окно: последние 24 часа
+	MsgStatsWeek          = "stats_week"           // окно: последние 7 дней
+	MsgStatsAll           = "stats_all"            // окно: всё время
+	MsgStatsTotals        = "stats_totals"         // окно, число ответов и срабатываний
+	MsgStatsRules         = "stats_rules"          // самые частые правила
+	MsgStatsUsers         = "stats_users"          // самые активные участники
+	MsgDigestTitle        = "digest_title"         // заголовок периодической сводки
+	MsgDigestEmpty        = "digest_empty"         // сводка без срабатываний
+)
+
+// builtin — встроенные тексты системных сообщений: язык → ключ → текст.
+// Тексты можно переопределить и дополнить другими языками в i18n.messages.
+var builtin = map[string]map[string]string{
+	"ru": {
+		MsgLangCurrent:        "Язык ответов: %s (%s)",
+		MsgLangSourceUser:     "ваша настройка",
+		MsgLangSourceChat:     "настройка чата",
+		MsgLangSourceTelegram: "язык Telegram",
+		MsgLangSourceDefault:  "по умолчанию",
+		MsgWarnIssued:         "%s, предупреждение %d",
+		MsgWarnIssuedOf:       "%s, предупреждение %d из %d",
+		MsgStatsTitle:         "Статистика чата",
+		MsgStatsDay:           "За сутки",
+		MsgStatsWeek:          "За неделю",
+		MsgStatsAll:           "За всё время",
+		MsgStatsTotals:        "%s: ответов %d, срабатываний %d",
+		MsgStatsRules:         "Правила: %s",
+		MsgStatsUsers:         "Участники: %s",
+		MsgDigestTitle:        "Сводка активности",
+		MsgDigestEmpty:        "Правила не срабатывали",
+	},
+	"en": {
+		MsgLangCurrent:        "Reply language: %s (%s)",
+		MsgLangSourceUser:     "your setting",
+		MsgLangSourceChat:     "chat setting",
+		MsgLangSourceTelegram: "Telegram language",
+		MsgLangSourceDefault:  "default",
+		MsgWarnIssued:         "%s, warning %d",
+		MsgWarnIssuedOf:       "%s, warning %d of %d",
+		MsgStatsTitle:         "Chat statistics",
+		MsgStatsDay:           "Last 24 hours",
+		MsgStatsWeek:          "Last 7 days",
+		MsgStatsAll:           "All time",
+		MsgStatsTotals:        "%s: replies %d, rule hits %d",
+		MsgStatsRules:         "Rules: %s",
+		MsgStatsUsers:         "Members: %s",
+		MsgDigestTitle:        "Activity digest",
+		MsgDigestEmpty:        "No rules were triggered",
+	},
+}
+
+// sourceMessages — ключ сообщения с названием каждого источника языка
+var sourceMessages = map[string]string{
+	SourceUser:     MsgLangSourceUser,
+	SourceChat:     MsgLangSourceChat,
+	SourceTelegram: MsgLangSourceTelegram,
+	SourceDefault:  MsgLangSourceDefault,
+}
+
+// Text возвращает системное сообщение key на первом языке цепочки, для которого
+// есть текст. Переопределения из i18n.messages важнее встроенных текстов.
+// args подставляются в текст через fmt.Sprintf.
+func Text(conf config.I18nConfig, chain []string, key string, args ...any) string {
+	format, ok := lookup(conf, chain, key)
+	if !ok {
+		format, ok = lookup(conf, []string{fallbackLanguage}, key)
+	}
+	if !ok {
+		format = key
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// lookup ищет текст сообщения по цепочке языков
+func lookup(conf config.I18nConfig, chain []string, key string) (string, bool) {
+	for _, lang := range chain {
+		if text, ok := conf.Messages[lang][key]; ok {
+			return text, true
+		}
+		if text, ok := builtin[lang][key]; ok {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+// SourceName возвращает локализованное название источника языка
+func SourceName(conf config.I18nConfig, chain []string, source string) string {
+	return Text(conf, chain, sourceMessages[source])
+}
+
+// UnknownMessages возвращает ключи из i18n.messages, которых нет среди системных сообщений,
+// в виде "язык.ключ" — обычно это опечатки.
+func UnknownMessages(conf config.I18nConfig) []string {
+	var unknown []string
+	for lang, messages := range conf.Messages {
+		for key := range messages {
+			if _, ok := builtin[fallbackLanguage][key]; !ok {
+				unknown = append(unknown, lang+"."+key)
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
@@ -0,0 +1,180 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap" // структурированное логирование
+)
+
+// DefaultTimeout — таймаут запуска и остановки компонента, если он не задан
+const DefaultTimeout = 10 * time.Second
+
+// Component — часть приложения под управлением Supervisor.
+// Любой из хуков может быть nil.
+type Component struct {
+	Name string // имя для логов и ошибок
+
+	// Start запускает компонент. Ошибка прерывает запуск приложения:
+	// уже запущенные компоненты останавливаются в обратном порядке.
+	Start func(ctx context.Context) error
+	// Run — основной цикл компонента; работает до отмены ctx.
+	// Ошибка (кроме отмены ctx) фатальна и запускает остановку всего приложения.
+	Run func(ctx context.Context) error
+	// Stop останавливает компонент; ctx отменяется по StopTimeout
+	Stop func(ctx context.Context) error
+
+	StartTimeout time.Duration // таймаут Start (по умолчанию DefaultTimeout)
+	StopTimeout  time.Duration // таймаут Stop и завершения Run (по умолчанию DefaultTimeout)
+}
+
+// Supervisor запускает компоненты по порядку добавления, следит за их циклами
+// и останавливает их в обратном порядке — по отмене контекста или фатальной ошибке любого из них.
+type Supervisor struct {
+	components []Component
+	logger     *zap.SugaredLogger
+}
+
+// New создаёт пустой Supervisor
+func New(logger *zap.SugaredLogger) *Supervisor {
+	return &Supervisor{logger: logger}
+}
+
+// Add добавляет компонент. Компоненты запускаются в порядке добавления
+// и останавливаются в обратном, поэтому зависимости добавляются раньше зависящих от них.
+func (s *Supervisor) Add(c Component) {
+	s.components = append(s.components, c)
+}
+
+// Run запускает компоненты и блокируется до отмены ctx или фатальной ошибки одного из них,
+// после чего останавливает все запущенные компоненты в обратном порядке.
+//
+// Параметры:
+// - ctx: контекст приложения; его отмена (например, по SIGTERM) — штатная остановка
+//
+// Возвращает:
+// - ошибку запуска, если компонент не запустился
+// - фатальную ошибку компонента и ошибки остановки
+// - nil при штатной остановке
+func (s *Supervisor) Run(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		started []*running
+		fatal   = make(chan error, len(s.components))
+	)
+
+	// Запуск по порядку; при ошибке останавливаем уже запущенные
+	for i := range s.components {
+		c := &s.components[i]
+		if err := s.start(runCtx, c); err != nil {
+			cancel()
+			return errors.Join(fmt.Errorf("%s: start: %w", c.Name, err), s.stopAll(started))
+		}
+		r := &running{Component: c, done: make(chan struct{})}
+		started = append(started, r)
+		go r.run(runCtx, fatal)
+	}
+	s.logger.Infow("application started", "components", len(started))
+
+	// Ждём сигнала остановки или фатальной ошибки
+	var cause error
+	select {
+	case <-ctx.Done():
+		s.logger.Info("shutting down gracefully...")
+	case cause = <-fatal:
+		s.logger.Errorw("component failed, shutting down", "error", cause)
+	}
+	cancel()
+
+	return errors.Join(cause, s.stopAll(started))
+}
+
+// start вызывает Start компонента с таймаутом
+func (s *Supervisor) start(ctx context.Context, c *Component) error {
+	if c.Start == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, orDefault(c.StartTimeout))
+	defer cancel()
+	return call(ctx, c.Start)
+}
+
+// stopAll останавливает компоненты в обратном порядке запуска.
+// Компонент, не остановившийся за StopTimeout, пропускается с ошибкой.
+func (s *Supervisor) stopAll(started []*running) error {
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		r := started[i]
+		if err := r.stop(); err != nil {
+			s.logger.Warnw("component stop failed", "component", r.Name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: stop: %w", r.Name, err))
+			continue
+		}
+		s.logger.Debugw("component stopped", "component", r.Name)
+	}
+	return errors.Join(errs...)
+}
+
+// running — запущенный компонент
+type running struct {
+	*Component
+	done chan struct{} // закрывается, когда Run завершился
+}
+
+// run выполняет основной цикл компонента и сообщает о фатальной ошибке
+func (r *running) run(ctx context.Context, fatal chan<- error) {
+	defer close(r.done)
+	if r.Run == nil {
+		return
+	}
+	if err := r.Run(ctx); err != nil && ctx.Err() == nil {
+		fatal <- fmt.Errorf("%s: %w", r.Name, err)
+	}
+}
+
+// stop вызывает Stop и дожидается завершения Run в пределах StopTimeout
+func (r *running) stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), orDefault(r.StopTimeout))
+	defer cancel()
+
+	var err error
+	if r.Stop != nil {
+		err = call(ctx, r.Stop)
+	}
+	// Run обычно уже завершился: ctx отменён до вызова stopAll
+	select {
+	case <-r.done:
+		return err
+	default:
+	}
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		err = errors.Join(err, fmt.Errorf("run did not return: %w", ctx.Err()))
+	}
+	return err
+}
+
+// call вызывает хук и возвращается не позже отмены ctx, даже если хук его не учитывает
+func call(ctx context.Context, hook func(context.Context) error) error {
+	result := make(chan error, 1)
+	go func() { result <- hook(ctx) }()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// orDefault возвращает таймаут или DefaultTimeout, если он не задан
+func orDefault(d time.Duration) time.Duration {
+	if d <= 0 {
+		return DefaultTimeout
+	}
+	return d
+}
@@ -1,6 +1,9 @@
 package log
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
 
 	"github.com/st-kuptsov/balabol/config"
@@ -10,13 +13,15 @@ import (
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+// logLevel переводит уровень из конфигурации в уровень zap.
+// Принимаются как "warn", так и "warning".
 func logLevel(level string) zapcore.Level {
 	switch level {
 	case "debug":
 		return zapcore.DebugLevel
 	case "info":
 		return zapcore.InfoLevel
-	case "warning":
+	case "warn", "warning":
 		return zapcore.WarnLevel
 	case "error":
 		return zapcore.ErrorLevel
@@ -25,20 +30,32 @@ func logLevel(level string) zapcore.Level {
 	}
 }
 
-func DefaultLogger(logConfig config.LogConfig) *zap.SugaredLogger {
+// sinkLevel возвращает уровень отдельного приёмника логов:
+// собственный, если он задан, иначе общий уровень, изменяемый на лету
+func sinkLevel(level string, common zap.AtomicLevel) zapcore.LevelEnabler {
+	if level == "" {
+		return common
+	}
+	return logLevel(level)
+}
+
+// newCore собирает ядро zap по настройкам логирования.
+// Каждый приёмник (файл, консоль, syslog) получает свой уровень и формат.
+// Возвращает также ресурсы, которые нужно закрыть при замене ядра,
+// и ошибку необязательного приёмника — ядро при этом собирается без него.
+func newCore(logConfig config.LogConfig, level zap.AtomicLevel) (zapcore.Core, io.Closer, error) {
 	logPath := logConfig.Directory + "/" + logConfig.Filename
 
 	// Конфигурация файла
-	fileWriter := zapcore.AddSync(&lumberjack.Logger{
+	fileLogger := &lumberjack.Logger{
 		Filename:   logPath,
 		MaxSize:    logConfig.MaxSize,
 		MaxBackups: logConfig.MaxBackups,
 		MaxAge:     logConfig.MaxAge,
 		Compress:   logConfig.Compress,
-	})
-
-	// Уровень логирования
-	level := logLevel(logConfig.Level)
+	}
+	fileWriter := zapcore.AddSync(fileLogger)
+	closers := multiCloser{fileLogger}
 
 	// Конфигурация кодировщиков
 	encoderConfig := zapcore.EncoderConfig{
@@ -55,29 +72,47 @@ func DefaultLogger(logConfig config.LogConfig) *zap.SugaredLogger {
 
 	// JSON логирование в файл
 	jsonEncoder := zapcore.NewJSONEncoder(encoderConfig)
-	fileCore := zapcore.NewCore(
+	cores := []zapcore.Core{zapcore.NewCore(
 		jsonEncoder,
 		fileWriter,
-		level,
-	)
-
-	var core zapcore.Core
+		sinkLevel(logConfig.FileLevel, level),
+	)}
 
-	// JSON логирование в консоль
+	// Логирование в консоль: JSON или человекочитаемый формат
 	if logConfig.Console {
-
 		consoleEncoder := zapcore.NewJSONEncoder(encoderConfig)
-		consoleCore := zapcore.NewCore(
+		if logConfig.ConsoleFormat == config.LogFormatConsole {
+			consoleEncoder = zapcore.NewConsoleEncoder(encoderConfig)
+		}
+		cores = append(cores, zapcore.NewCore(
 			consoleEncoder,
 			zapcore.AddSync(os.Stdout),
-			level,
-		)
-		core = zapcore.NewTee(fileCore, consoleCore)
-	} else {
-		core = fileCore
+			sinkLevel(logConfig.ConsoleLevel, level),
+		))
 	}
 
-	// Создание логгера
-	logger := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
-	return logger.Sugar()
+	// Логирование в syslog через локальный unix-сокет
+	var sinkErr error
+	if logConfig.Syslog.Enabled {
+		sysCore, closer, err := newSyslogCore(logConfig.Syslog, encoderConfig, sinkLevel(logConfig.Syslog.Level, level))
+		if err != nil {
+			sinkErr = fmt.Errorf("syslog sink: %w", err)
+		} else {
+			cores = append(cores, sysCore)
+			closers = append(closers, closer)
+		}
+	}
+
+	return zapcore.NewTee(cores...), closers, sinkErr
+}
+
+// multiCloser закрывает несколько ресурсов приёмников логов
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var errs []error
+	for _, c := range m {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,136 @@
+package log
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/st-kuptsov/balabol/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Reloader позволяет пересобрать логгер по новым настройкам на лету.
+// Все логгеры, полученные из ReloadableLogger (в том числе через With),
+// сразу начинают писать через новое ядро.
+// Общий уровень хранится в zap.AtomicLevel и меняется без пересборки ядра.
+type Reloader struct {
+	mu     sync.Mutex
+	core   atomic.Pointer[zapcore.Core] // текущее ядро
+	closer io.Closer                    // ресурсы приёмников текущего ядра
+	conf   config.LogConfig             // настройки текущего ядра
+	level  zap.AtomicLevel              // общий уровень для приёмников без собственного
+}
+
+// ReloadableLogger создаёт логгер, ядро которого можно заменить через Reloader.
+// Если необязательный приёмник (syslog) недоступен, логгер работает без него,
+// а ошибка выводится в лог.
+func ReloadableLogger(logConfig config.LogConfig) (*zap.SugaredLogger, *Reloader) {
+	r := &Reloader{level: zap.NewAtomicLevelAt(logLevel(logConfig.Level))}
+	err := r.swap(logConfig)
+
+	logger := zap.New(&swapCore{src: &r.core}, zap.AddCaller(), zap.AddCallerSkip(1)).Sugar()
+	if err != nil {
+		logger.Warnw("log sink unavailable", "error", err)
+	}
+	return logger, r
+}
+
+// Level возвращает общий уровень логирования, который можно менять на лету
+func (r *Reloader) Level() zap.AtomicLevel {
+	return r.level
+}
+
+// Reload применяет новые настройки логирования.
+// Если изменился только общий уровень, он меняется без пересборки ядра;
+// иначе ядро пересобирается. Уровень, заданный на лету через Level,
+// сохраняется, пока level в конфигурации не изменится.
+// Возвращает true, если настройки были применены, и ошибку необязательного приёмника.
+func (r *Reloader) Reload(logConfig config.LogConfig) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if logConfig == r.conf {
+		return false, nil
+	}
+	if logConfig.Level != r.conf.Level {
+		r.level.SetLevel(logLevel(logConfig.Level))
+	}
+
+	// Сравниваем без общего уровня: он уже применён
+	prev, next := r.conf, logConfig
+	prev.Level, next.Level = "", ""
+	if prev == next {
+		r.conf = logConfig
+		return true, nil
+	}
+	return true, r.swap(logConfig)
+}
+
+// swap собирает новое ядро, подменяет им текущее и закрывает ресурсы старого
+func (r *Reloader) swap(logConfig config.LogConfig) error {
+	core, closer, err := newCore(logConfig, r.level)
+	old := r.core.Swap(&core)
+	if old != nil {
+		_ = (*old).Sync()
+	}
+	if r.closer != nil {
+		_ = r.closer.Close()
+	}
+	r.closer = closer
+	r.conf = logConfig
+	return err
+}
+
+// swapCore — ядро zap, которое делегирует запись текущему ядру Reloader.
+// Поля, добавленные через With, хранятся отдельно и применяются к ядру один раз
+// после каждой подмены: ядро с полями кешируется до следующей пересборки.
+type swapCore struct {
+	src    *atomic.Pointer[zapcore.Core]
+	fields []zapcore.Field
+	cache  atomic.Pointer[derivedCore] // ядро с полями fields для последнего загруженного ядра
+}
+
+// derivedCore — ядро с полями, собранное из ядра src
+type derivedCore struct {
+	src  *zapcore.Core
+	core zapcore.Core
+}
+
+// current возвращает текущее ядро с накопленными полями
+func (s *swapCore) current() zapcore.Core {
+	src := s.src.Load()
+	if len(s.fields) == 0 {
+		return *src
+	}
+	if d := s.cache.Load(); d != nil && d.src == src {
+		return d.core
+	}
+	d := &derivedCore{src: src, core: (*src).With(s.fields)}
+	s.cache.Store(d)
+	return d.core
+}
+
+func (s *swapCore) Enabled(level zapcore.Level) bool {
+	return (*s.src.Load()).Enabled(level)
+}
+
+func (s *swapCore) With(fields []zapcore.Field) zapcore.Core {
+	merged := make([]zapcore.Field, 0, len(s.fields)+len(fields))
+	merged = append(merged, s.fields...)
+	merged = append(merged, fields...)
+	return &swapCore{src: s.src, fields: merged}
+}
+
+func (s *swapCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return s.current().Check(entry, checked)
+}
+
+func (s *swapCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return s.current().Write(entry, fields)
+}
+
+func (s *swapCore) Sync() error {
+	return (*s.src.Load()).Sync()
+}
@@ -0,0 +1,84 @@
+//go:build !windows && !plan9
+
+package log
+
+import (
+	"io"
+	"log/syslog"
+
+	"github.com/st-kuptsov/balabol/config"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// newSyslogCore подключается к локальному syslog через unix-сокет
+// и возвращает ядро, которое пишет записи с приоритетом по уровню zap.
+func newSyslogCore(conf config.SyslogConfig, encoderConfig zapcore.EncoderConfig, level zapcore.LevelEnabler) (zapcore.Core, io.Closer, error) {
+	// Время и уровень проставляет сам syslog
+	encoderConfig.TimeKey = ""
+	encoderConfig.LevelKey = ""
+
+	// Локальные демоны слушают либо датаграммный, либо потоковый сокет
+	w, err := syslog.Dial("unixgram", conf.Address, syslog.LOG_INFO|syslog.LOG_DAEMON, conf.Tag)
+	if err != nil {
+		w, err = syslog.Dial("unix", conf.Address, syslog.LOG_INFO|syslog.LOG_DAEMON, conf.Tag)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	core := &syslogCore{
+		LevelEnabler: level,
+		enc:          zapcore.NewJSONEncoder(encoderConfig),
+		w:            w,
+	}
+	return core, w, nil
+}
+
+// syslogCore — ядро zap, отправляющее записи в syslog
+type syslogCore struct {
+	zapcore.LevelEnabler
+	enc zapcore.Encoder
+	w   *syslog.Writer
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return &syslogCore{LevelEnabler: c.LevelEnabler, enc: enc, w: c.w}
+}
+
+func (c *syslogCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *syslogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(entry, fields)
+	if err != nil {
+		return err
+	}
+	msg := buf.String()
+	buf.Free()
+
+	switch {
+	case entry.Level >= zapcore.DPanicLevel:
+		return c.w.Crit(msg)
+	case entry.Level == zapcore.ErrorLevel:
+		return c.w.Err(msg)
+	case entry.Level == zapcore.WarnLevel:
+		return c.w.Warning(msg)
+	case entry.Level == zapcore.InfoLevel:
+		return c.w.Info(msg)
+	default:
+		return c.w.Debug(msg)
+	}
+}
+
+func (c *syslogCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build windows || plan9
+
+package log
+
+import (
+	"errors"
+	"io"
+
+	"github.com/st-kuptsov/balabol/config"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// newSyslogCore недоступен на платформах без log/syslog
+func newSyslogCore(config.SyslogConfig, zapcore.EncoderConfig, zapcore.LevelEnabler) (zapcore.Core, io.Closer, error) {
+	return nil, nil, errors.New("syslog is not supported on this platform")
+}
@@ -9,29 +9,43 @@ import (
 
 var (
 	// MessagesTotal — общее количество сообщений, полученных ботом
-	// Лейбл "chat_id" позволяет различать сообщения по чатам
+	// Лейбл "bot" — имя бота, "chat_id" позволяет различать сообщения по чатам
 	MessagesTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "bot_messages_total",
 			Help: "Total messages received by the bot",
 		},
-		[]string{"chat_id"},
+		[]string{"bot", "chat_id"},
 	)
 
 	// RepliesTotal — общее количество отправленных ботом ответов
-	RepliesTotal = prometheus.NewCounter(
+	// Лейбл "lang" — язык отправленного ответа (секция i18n)
+	RepliesTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "bot_replies_total",
 			Help: "Total replies sent by the bot",
 		},
+		[]string{"bot", "lang"},
 	)
 
 	// NoMatchTotal — количество сообщений, на которые не найдено совпадений с правилами
-	NoMatchTotal = prometheus.NewCounter(
+	NoMatchTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "bot_messages_no_match_total",
 			Help: "Messages received with no matching rules",
 		},
+		[]string{"bot"},
+	)
+
+	// DroppedTotal — сообщения, отброшенные фильтром отправителей (sender_policy)
+	// Лейбл "reason" — причина: self, bot, forward, via_bot, sender_chat,
+	// user_blocked, user_not_allowed, chat_blocked, chat_not_allowed, flood
+	DroppedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_messages_dropped_total",
+			Help: "Messages dropped by the sender policy by reason",
+		},
+		[]string{"bot", "reason"},
 	)
 
 	// ErrorsTotal — количество ошибок на разных стадиях обработки сообщений
@@ -41,7 +55,17 @@ var (
 			Name: "bot_errors_total",
 			Help: "Total errors occurred in bot processing",
 		},
-		[]string{"stage"},
+		[]string{"bot", "stage"},
+	)
+
+	// ModerationActionsTotal — действия модерации из правил (actions) и эскалации предупреждений
+	// Лейбл "action" — delete, restrict, ban, pin, warn; "result" — done, dry_run, no_rights, failed, unsupported
+	ModerationActionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_moderation_actions_total",
+			Help: "Moderation actions by type and result",
+		},
+		[]string{"bot", "action", "result"},
 	)
 
 	// RuleHitsTotal — количество срабатываний каждого правила
@@ -51,16 +75,125 @@ var (
 			Name: "bot_rule_hits_total",
 			Help: "Number of times each rule was triggered",
 		},
-		[]string{"rule"},
+		[]string{"bot", "rule"},
+	)
+
+	// RuleShadowHitsTotal — срабатывания теневых правил (shadow: true), которые не дают ответа
+	// Лейбл "rule" хранит текст правила
+	RuleShadowHitsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_rule_shadow_hits_total",
+			Help: "Number of times each shadow rule would have been triggered",
+		},
+		[]string{"bot", "rule"},
+	)
+
+	// ExperimentExposuresTotal — доставленные ответы по вариантам A/B-экспериментов
+	ExperimentExposuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_experiment_exposures_total",
+			Help: "Number of delivered replies per experiment variant",
+		},
+		[]string{"bot", "experiment", "variant"},
+	)
+
+	// ExperimentOutcomesTotal — реплаи и реакции на ответы вариантов в течение experiments.window
+	// Лейбл "outcome": reply или reaction
+	ExperimentOutcomesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_experiment_outcomes_total",
+			Help: "Number of replies and reactions to experiment variant replies within the outcome window",
+		},
+		[]string{"bot", "experiment", "variant", "outcome"},
 	)
 
 	// MessageProcessingDuration — гистограмма времени обработки одного сообщения
-	MessageProcessingDuration = prometheus.NewHistogram(
+	MessageProcessingDuration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "bot_message_processing_duration_seconds",
 			Help:    "Duration to process one message",
 			Buckets: prometheus.DefBuckets, // стандартные интервалы Prometheus
 		},
+		[]string{"bot"},
+	)
+
+	// APIRequestDuration — время выполнения запросов к Telegram Bot API
+	// Лейбл "method" — метод Bot API (sendMessage, getUpdates и т.д.)
+	APIRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "bot_api_request_duration_seconds",
+			Help:    "Duration of Telegram Bot API requests",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}, // с учётом long polling
+		},
+		[]string{"bot", "method"},
+	)
+
+	// APIErrorsTotal — количество неуспешных запросов к Telegram Bot API
+	// Лейбл "class" — класс ошибки: rate_limited (429), forbidden (403), network, client, server
+	APIErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_api_errors_total",
+			Help: "Total failed Telegram Bot API requests by error class",
+		},
+		[]string{"bot", "method", "class"},
+	)
+
+	// SendQueueDepth — сообщения в очереди исходящих сообщений бота (send_queue)
+	SendQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "bot_send_queue_depth",
+			Help: "Outbound messages waiting in the send queue",
+		},
+		[]string{"bot"},
+	)
+
+	// SendQueueWait — время от постановки сообщения в очередь до его доставки
+	SendQueueWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "bot_send_queue_wait_seconds",
+			Help:    "Time from enqueueing an outbound message to its delivery",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+		},
+		[]string{"bot"},
+	)
+
+	// SendRetriesTotal — повторные попытки отправки
+	// Лейбл "reason" — rate_limited (429 с retry_after) или transient (сеть, 5xx)
+	SendRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_send_retries_total",
+			Help: "Outbound message send retries by reason",
+		},
+		[]string{"bot", "reason"},
+	)
+
+	// DeadLettersTotal — сообщения, которые не удалось доставить (записаны в dead letter)
+	// Лейбл "reason" — rejected, retries_exhausted, expired, queue_full, shutdown
+	DeadLettersTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_send_dead_letters_total",
+			Help: "Undeliverable outbound messages by reason",
+		},
+		[]string{"bot", "reason"},
+	)
+
+	// SendCircuitOpen — разомкнут ли предохранитель отправки (1/0): Bot API недоступен
+	SendCircuitOpen = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "bot_send_circuit_open",
+			Help: "Whether the send circuit breaker is open",
+		},
+		[]string{"bot"},
+	)
+
+	// UpdateLag — задержка между отправкой сообщения пользователем и началом его обработки
+	UpdateLag = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "bot_update_lag_seconds",
+			Help:    "Delay between message date and its processing",
+			Buckets: []float64{0.5, 1, 2, 5, 10, 30, 60, 300, 900},
+		},
+		[]string{"bot"},
 	)
 
 	// ConfigReloadDuration — время выполнения reload конфигурации
@@ -86,6 +219,43 @@ var (
 			Help: "Number of errors during config reload",
 		},
 	)
+
+	// RuleSourceUp — успешна ли последняя загрузка удалённого источника правил (1/0)
+	RuleSourceUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "bot_rule_source_up",
+			Help: "Whether the last fetch of the remote rule source succeeded",
+		},
+		[]string{"source"},
+	)
+
+	// RuleSourceLastSuccess — время последней успешной загрузки источника (Unix, секунды)
+	RuleSourceLastSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "bot_rule_source_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful fetch of the remote rule source",
+		},
+		[]string{"source"},
+	)
+
+	// RuleSourceFetchTotal — количество запросов к источнику правил.
+	// Лейбл "result": updated, not_modified или error
+	RuleSourceFetchTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_rule_source_fetch_total",
+			Help: "Fetches of the remote rule source by result",
+		},
+		[]string{"source", "result"},
+	)
+
+	// RuleSourceRules — количество действующих правил из источника
+	RuleSourceRules = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "bot_rule_source_rules",
+			Help: "Number of live rules loaded from the remote rule source",
+		},
+		[]string{"source"},
+	)
 )
 
 // InitMetrics регистрирует все метрики в Prometheus
@@ -94,18 +264,68 @@ func InitMetrics() {
 		MessagesTotal,
 		RepliesTotal,
 		NoMatchTotal,
+		DroppedTotal,
 		ErrorsTotal,
+		ModerationActionsTotal,
 		RuleHitsTotal,
+		RuleShadowHitsTotal,
+		ExperimentExposuresTotal,
+		ExperimentOutcomesTotal,
 		MessageProcessingDuration,
+		APIRequestDuration,
+		APIErrorsTotal,
+		SendQueueDepth,
+		SendQueueWait,
+		SendRetriesTotal,
+		DeadLettersTotal,
+		SendCircuitOpen,
+		UpdateLag,
 		ConfigReloadDuration,
 		ConfigReloadTotal,
 		ConfigReloadErrorsTotal,
+		RuleSourceUp,
+		RuleSourceLastSuccess,
+		RuleSourceFetchTotal,
+		RuleSourceRules,
 	)
 }
 
-// ObserveProcessing измеряет длительность обработки сообщения
+// ObserveProcessing измеряет длительность обработки сообщения ботом bot
 // и обновляет гистограмму MessageProcessingDuration
-func ObserveProcessing(start time.Time) {
+func ObserveProcessing(bot string, start time.Time) {
 	duration := time.Since(start).Seconds()
-	MessageProcessingDuration.Observe(duration)
+	MessageProcessingDuration.WithLabelValues(bot).Observe(duration)
+}
+
+// DeleteBot удаляет все серии метрик бота, например после его удаления из конфигурации
+func DeleteBot(bot string) {
+	labels := prometheus.Labels{"bot": bot}
+	MessagesTotal.DeletePartialMatch(labels)
+	RepliesTotal.DeletePartialMatch(labels)
+	NoMatchTotal.DeletePartialMatch(labels)
+	DroppedTotal.DeletePartialMatch(labels)
+	ErrorsTotal.DeletePartialMatch(labels)
+	ModerationActionsTotal.DeletePartialMatch(labels)
+	RuleHitsTotal.DeletePartialMatch(labels)
+	RuleShadowHitsTotal.DeletePartialMatch(labels)
+	ExperimentExposuresTotal.DeletePartialMatch(labels)
+	ExperimentOutcomesTotal.DeletePartialMatch(labels)
+	MessageProcessingDuration.DeletePartialMatch(labels)
+	APIRequestDuration.DeletePartialMatch(labels)
+	APIErrorsTotal.DeletePartialMatch(labels)
+	SendQueueDepth.DeletePartialMatch(labels)
+	SendQueueWait.DeletePartialMatch(labels)
+	SendRetriesTotal.DeletePartialMatch(labels)
+	DeadLettersTotal.DeletePartialMatch(labels)
+	SendCircuitOpen.DeletePartialMatch(labels)
+	UpdateLag.DeletePartialMatch(labels)
+}
+
+// DeleteRuleSource удаляет серии метрик источника правил после его удаления из конфигурации
+func DeleteRuleSource(source string) {
+	labels := prometheus.Labels{"source": source}
+	RuleSourceUp.DeletePartialMatch(labels)
+	RuleSourceLastSuccess.DeletePartialMatch(labels)
+	RuleSourceFetchTotal.DeletePartialMatch(labels)
+	RuleSourceRules.DeletePartialMatch(labels)
 }
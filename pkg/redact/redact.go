@@ -0,0 +1,70 @@
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/st-kuptsov/balabol/config" // режимы скрытия (log_settings.redact)
+)
+
+// placeholder заменяет текст в режиме полного скрытия
+const placeholder = "[redacted]"
+
+// Text возвращает представление текста пользователя, допустимое для логов
+// и журнала аудита в заданном режиме:
+// - none: текст как есть
+// - hash: первые 12 символов SHA-256 — одинаковые сообщения можно сопоставить, не раскрывая их
+// - length: только длина в символах
+// - hidden: текст полностью скрыт
+func Text(mode, s string) string {
+	switch mode {
+	case config.RedactHash:
+		sum := sha256.Sum256([]byte(s))
+		return "sha256:" + hex.EncodeToString(sum[:])[:12]
+	case config.RedactLength:
+		return fmt.Sprintf("len:%d", utf8.RuneCountInString(s))
+	case config.RedactHidden:
+		return placeholder
+	default:
+		return s
+	}
+}
+
+// Strings применяет Text к каждому элементу списка
+func Strings(mode string, list []string) []string {
+	if mode == config.RedactNone || mode == "" {
+		return list
+	}
+	out := make([]string, len(list))
+	for i, s := range list {
+		out[i] = Text(mode, s)
+	}
+	return out
+}
+
+// Error возвращает текст ошибки, в котором вхождения texts заменены
+// их скрытым представлением. Нужен для ошибок, которые могут содержать
+// фрагменты сообщения пользователя.
+func Error(mode string, err error, texts ...string) string {
+	if err == nil {
+		return ""
+	}
+	msg := err.Error()
+	if mode == config.RedactNone || mode == "" {
+		return msg
+	}
+	for _, t := range texts {
+		if t != "" {
+			msg = strings.ReplaceAll(msg, t, Text(mode, t))
+		}
+	}
+	return msg
+}
+
+// Enabled сообщает, скрывается ли текст в заданном режиме
+func Enabled(mode string) bool {
+	return mode != config.RedactNone && mode != ""
+}
@@ -0,0 +1,384 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Окна статистики
+const (
+	WindowDay  = "day"  // последние 24 часа
+	WindowWeek = "week" // последние 7 дней
+	WindowAll  = "all"  // всё время
+)
+
+// retention — сколько хранятся почасовые счётчики (окно week)
+const retention = 7 * 24 * time.Hour
+
+// Counts — счётчики за час или за всё время
+type Counts struct {
+	Replies int            `json:"replies"`         // отправленные ответы
+	Rules   map[string]int `json:"rules,omitempty"` // срабатывания по тексту правила
+	Users   map[int64]int  `json:"users,omitempty"` // сообщения со срабатываниями по отправителю
+}
+
+// chat — статистика одного чата одного бота
+type chat struct {
+	Title string            `json:"title,omitempty"` // название чата на момент последнего сообщения
+	Hours map[int64]*Counts `json:"hours"`           // почасовые счётчики: начало часа (Unix) → счётчики
+	Total Counts            `json:"total"`           // счётчики за всё время
+	Names map[int64]string  `json:"names,omitempty"` // отображаемые имена отправителей
+}
+
+// file — содержимое файла статистики
+type file struct {
+	Chats   map[string]*chat     `json:"chats"`             // ключ — бот:чат
+	Digests map[string]time.Time `json:"digests,omitempty"` // время последней сводки по боту
+}
+
+// Store хранит счётчики срабатываний правил, активных участников и ответов по чатам.
+// Изменения накапливаются в памяти и записываются в файл вызовом Flush.
+// Нулевой Store не пригоден к использованию — создавайте через Open.
+type Store struct {
+	mu    sync.Mutex
+	path  string // путь к файлу
+	data  file
+	dirty bool // есть несохранённые изменения
+}
+
+// Open загружает статистику из файла path. Отсутствующий файл — пустая статистика.
+func Open(path string) (*Store, error) {
+	data, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{path: path, data: data}, nil
+}
+
+// Reload переключает хранилище на другой файл, если путь изменился.
+// Несохранённые изменения сначала записываются в прежний файл.
+// Возвращает true, если файл был перечитан. При ошибке чтения остаётся прежний файл.
+func (s *Store) Reload(path string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if path == s.path {
+		return false, nil
+	}
+	data, err := load(path)
+	if err != nil {
+		return false, err
+	}
+	if err := s.save(); err != nil {
+		return false, err
+	}
+	s.path, s.data = path, data
+	return true, nil
+}
+
+// Message — сообщение, на которое сработали правила
+type Message struct {
+	Bot     string   // имя бота
+	ChatID  int64    // чат
+	Title   string   // название чата (пустое у личных сообщений)
+	UserID  int64    // отправитель (0 — неизвестен)
+	User    string   // отображаемое имя отправителя
+	Rules   []string // тексты сработавших правил
+	Replied bool     // ответ отправлен
+}
+
+// Record учитывает сообщение, на которое сработали правила, и удаляет почасовые счётчики старше недели
+func (s *Store) Record(msg Message, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(msg.Bot, msg.ChatID)
+	ch := s.data.Chats[k]
+	if ch == nil {
+		ch = &chat{Hours: make(map[int64]*Counts)}
+		s.data.Chats[k] = ch
+	}
+	if msg.Title != "" {
+		ch.Title = msg.Title
+	}
+
+	hour := now.Truncate(time.Hour).Unix()
+	bucket := ch.Hours[hour]
+	if bucket == nil {
+		bucket = &Counts{}
+		ch.Hours[hour] = bucket
+		// Новый час — заодно удаляем часы за пределами недели
+		cutoff := now.Add(-retention).Unix()
+		for h := range ch.Hours {
+			if h <= cutoff {
+				delete(ch.Hours, h)
+			}
+		}
+	}
+
+	for _, c := range []*Counts{bucket, &ch.Total} {
+		c.add(msg)
+	}
+	if msg.UserID != 0 && msg.User != "" {
+		if ch.Names == nil {
+			ch.Names = make(map[int64]string)
+		}
+		ch.Names[msg.UserID] = msg.User
+	}
+	s.dirty = true
+}
+
+// add прибавляет к счётчикам одно сообщение
+func (c *Counts) add(msg Message) {
+	if msg.Replied {
+		c.Replies++
+	}
+	if c.Rules == nil {
+		c.Rules = make(map[string]int)
+	}
+	for _, r := range msg.Rules {
+		c.Rules[r]++
+	}
+	if msg.UserID != 0 {
+		if c.Users == nil {
+			c.Users = make(map[int64]int)
+		}
+		c.Users[msg.UserID]++
+	}
+}
+
+// Entry — строка рейтинга: правило или участник и число срабатываний
+type Entry struct {
+	Name  string
+	Count int
+}
+
+// Window — итоги за одно окно
+type Window struct {
+	Name    string  // WindowDay, WindowWeek или WindowAll
+	Replies int     // отправленные ответы
+	Hits    int     // срабатывания правил
+	Rules   []Entry // самые частые правила
+	Users   []Entry // самые активные участники
+}
+
+// Summary — статистика чата за окна day, week и all
+type Summary struct {
+	ChatID  int64
+	Title   string
+	Windows []Window
+}
+
+// Summary возвращает статистику чата.
+//
+// Параметры:
+// - bot, chatID: чей чат
+// - top: сколько правил и участников включать в рейтинги
+// - now: текущее время (от него отсчитываются окна day и week)
+func (s *Store) Summary(bot string, chatID int64, top int, now time.Time) Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sum := Summary{ChatID: chatID}
+	ch := s.data.Chats[key(bot, chatID)]
+	if ch == nil {
+		ch = &chat{}
+	}
+	sum.Title = ch.Title
+
+	day, week := Counts{}, Counts{}
+	dayFrom := now.Add(-24 * time.Hour).Unix()
+	weekFrom := now.Add(-retention).Unix()
+	for h, c := range ch.Hours {
+		// Час попадает в окно, если он не закончился до начала окна
+		end := h + int64(time.Hour/time.Second)
+		if end > weekFrom {
+			week.merge(c)
+		}
+		if end > dayFrom {
+			day.merge(c)
+		}
+	}
+
+	for _, w := range []struct {
+		name string
+		c    Counts
+	}{{WindowDay, day}, {WindowWeek, week}, {WindowAll, ch.Total}} {
+		sum.Windows = append(sum.Windows, w.c.window(w.name, ch.Names, top))
+	}
+	return sum
+}
+
+// merge прибавляет к счётчикам счётчики другого часа
+func (c *Counts) merge(other *Counts) {
+	c.Replies += other.Replies
+	if c.Rules == nil {
+		c.Rules = make(map[string]int)
+	}
+	for r, n := range other.Rules {
+		c.Rules[r] += n
+	}
+	if c.Users == nil {
+		c.Users = make(map[int64]int)
+	}
+	for u, n := range other.Users {
+		c.Users[u] += n
+	}
+}
+
+// window строит итоги окна с рейтингами длиной top
+func (c Counts) window(name string, names map[int64]string, top int) Window {
+	w := Window{Name: name, Replies: c.Replies}
+	for r, n := range c.Rules {
+		w.Hits += n
+		w.Rules = append(w.Rules, Entry{Name: r, Count: n})
+	}
+	for id, n := range c.Users {
+		display := names[id]
+		if display == "" {
+			display = strconv.FormatInt(id, 10)
+		}
+		w.Users = append(w.Users, Entry{Name: display, Count: n})
+	}
+	w.Rules = topEntries(w.Rules, top)
+	w.Users = topEntries(w.Users, top)
+	return w
+}
+
+// topEntries сортирует строки по убыванию числа (при равенстве — по имени) и оставляет первые top
+func topEntries(entries []Entry, top int) []Entry {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if top > 0 && len(entries) > top {
+		entries = entries[:top]
+	}
+	return entries
+}
+
+// ActiveChats возвращает чаты бота, в которых правила срабатывали после since, по возрастанию ID
+func (s *Store) ActiveChats(bot string, since time.Time) []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from := since.Truncate(time.Hour).Unix()
+	var ids []int64
+	for k, ch := range s.data.Chats {
+		rest, ok := strings.CutPrefix(k, bot+":")
+		if !ok {
+			continue
+		}
+		id, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			continue
+		}
+		for h := range ch.Hours {
+			if h >= from {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// LastDigest возвращает время последней сводки бота (нулевое — сводка ещё не отправлялась)
+func (s *Store) LastDigest(bot string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.Digests[bot]
+}
+
+// SetLastDigest запоминает время последней сводки бота
+func (s *Store) SetLastDigest(bot string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Digests == nil {
+		s.data.Digests = make(map[string]time.Time)
+	}
+	s.data.Digests[bot] = t
+	s.dirty = true
+}
+
+// Flush записывает несохранённые изменения в файл
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+// key составляет ключ чата
+func key(bot string, chatID int64) string {
+	return bot + ":" + strconv.FormatInt(chatID, 10)
+}
+
+// load читает файл статистики
+func load(path string) (file, error) {
+	data := file{Chats: make(map[string]*chat)}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return data, nil
+	}
+	if err != nil {
+		return data, fmt.Errorf("stats file: %w", err)
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return data, fmt.Errorf("stats file %s: %w", path, err)
+	}
+	if data.Chats == nil {
+		data.Chats = make(map[string]*chat)
+	}
+	for _, ch := range data.Chats {
+		if ch.Hours == nil {
+			ch.Hours = make(map[int64]*Counts)
+		}
+	}
+	return data, nil
+}
+
+// save атомарно записывает статистику, если есть несохранённые изменения:
+// во временный файл рядом и переименованием. Вызывается под s.mu.
+func (s *Store) save() error {
+	if !s.dirty {
+		return nil
+	}
+	data, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("stats file: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("stats file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("stats file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("stats file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("stats file: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
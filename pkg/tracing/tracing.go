@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/st-kuptsov/balabol/config" // настройки трассировки
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// instrumentationName — имя библиотеки инструментирования в спанах
+const instrumentationName = "github.com/st-kuptsov/balabol"
+
+// Provider управляет глобальным TracerProvider и позволяет
+// перенастроить его при изменении конфигурации.
+type Provider struct {
+	mu       sync.Mutex
+	conf     config.TracingConfig     // текущие настройки
+	version  string                   // версия приложения для ресурса
+	provider *sdktrace.TracerProvider // nil, если трассировка выключена
+	applied  bool                     // настройки уже применялись
+}
+
+// NewProvider настраивает трассировку по конфигурации.
+// При выключенной трассировке используется noop-провайдер, и спаны ничего не стоят.
+func NewProvider(ctx context.Context, conf config.TracingConfig, version string) (*Provider, error) {
+	p := &Provider{version: version}
+	if _, err := p.Apply(ctx, conf); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Apply применяет новые настройки трассировки, если они изменились.
+// Старый провайдер сбрасывает накопленные спаны и закрывается.
+// Возвращает true, если провайдер был пересоздан.
+func (p *Provider) Apply(ctx context.Context, conf config.TracingConfig) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.applied && conf == p.conf {
+		return false, nil
+	}
+
+	var next *sdktrace.TracerProvider
+	if conf.Enabled {
+		exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(conf.Endpoint))
+		if err != nil {
+			return false, fmt.Errorf("otlp exporter: %w", err)
+		}
+
+		res := sdkresource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(conf.ServiceName),
+			semconv.ServiceVersion(p.version),
+		)
+		next = sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithResource(res),
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))),
+		)
+		otel.SetTracerProvider(next)
+	} else {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	}
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	prev := p.provider
+	p.provider = next
+	p.conf = conf
+	p.applied = true
+
+	// Закрываем старый провайдер только после переключения на новый
+	if prev != nil {
+		if err := prev.Shutdown(ctx); err != nil {
+			return true, fmt.Errorf("shutdown previous tracer provider: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// Shutdown отправляет накопленные спаны и останавливает экспорт
+func (p *Provider) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider == nil {
+		return nil
+	}
+	err := p.provider.Shutdown(ctx)
+	p.provider = nil
+	return err
+}
+
+// Tracer возвращает трассировщик текущего глобального провайдера.
+// Провайдер запрашивается при каждом вызове, чтобы после reload спаны шли в новый экспортёр.
+func Tracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(instrumentationName)
+}
+
+// LogFields возвращает поля trace_id и span_id для zap-логгера.
+// Если в контексте нет записываемого спана, возвращает nil.
+func LogFields(ctx context.Context) []any {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || !sc.IsSampled() {
+		return nil
+	}
+	return []any{"trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String()}
+}
@@ -0,0 +1,166 @@
+package tracing_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/st-kuptsov/balabol/config"
+	"github.com/st-kuptsov/balabol/internal/telegram"
+	"github.com/st-kuptsov/balabol/internal/telegramtest"
+	"github.com/st-kuptsov/balabol/pkg/tracing"
+	"go.uber.org/zap"
+
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// receiver — приёмник OTLP/HTTP, запоминающий все полученные спаны
+type receiver struct {
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/v1/traces" {
+		http.NotFound(w, req)
+		return
+	}
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var export collectorpb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(data, &export); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	for _, rs := range export.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			r.spans = append(r.spans, ss.Spans...)
+		}
+	}
+	r.mu.Unlock()
+
+	resp, _ := proto.Marshal(&collectorpb.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(resp)
+}
+
+// byName возвращает полученные спаны по имени
+func (r *receiver) byName() map[string]*tracepb.Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spans := make(map[string]*tracepb.Span, len(r.spans))
+	for _, s := range r.spans {
+		spans[s.Name] = s
+	}
+	return spans
+}
+
+// attr возвращает строковое значение атрибута спана
+func attr(s *tracepb.Span, key string) (string, bool) {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			if v, ok := kv.Value.Value.(*commonpb.AnyValue_StringValue); ok {
+				return v.StringValue, true
+			}
+		}
+	}
+	return "", false
+}
+
+// testConfig — конфигурация бота, который ходит в фейковый Bot API (см. telegramtest.Server.WriteConfig)
+const testConfig = `secrets: "{secrets}"
+telegram:
+  api_url: "{api_url}"
+  long_polling:
+    timeout: 1s
+rules:
+  - text: "Приветствие"
+    pattern: '(?i)привет'
+    response: "Здравствуй"
+`
+
+// TestUpdateSpansExported проверяет, что обработка сообщения экспортируется по OTLP/HTTP
+// одной трассой: корневой спан telegram.update и дочерние спаны очистки, правил и отправки.
+func TestUpdateSpansExported(t *testing.T) {
+	recv := &receiver{}
+	collector := httptest.NewServer(recv)
+	defer collector.Close()
+
+	ctx := context.Background()
+	provider, err := tracing.NewProvider(ctx, config.TracingConfig{
+		Enabled:     true,
+		Endpoint:    collector.URL,
+		ServiceName: "balabol-test",
+		SampleRatio: 1,
+	}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = provider.Apply(ctx, config.TracingConfig{})
+	}()
+
+	api := telegramtest.NewServer()
+	defer api.Close()
+	fake := api.Bot("123:TEST")
+
+	settings := telegramtest.LoadBot(t, api.WriteConfig(t, "123:TEST", testConfig))
+	bot, err := telegram.NewBot(*settings, func() *config.BotConfig { return settings }, telegram.Deps{}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go bot.Start()
+
+	fake.PushText(telegramtest.Group(-100), telegramtest.User(7), "Привет всем")
+	if _, err := fake.WaitCalls("sendMessage", 1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// Остановка бота дожидается обработчика, остановка провайдера отправляет накопленные спаны
+	stopCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := bot.Shutdown(stopCtx); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.Shutdown(stopCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recv.byName()
+	root := spans["telegram.update"]
+	if root == nil {
+		t.Fatalf("telegram.update span not exported, got %d span(s)", len(spans))
+	}
+	if bot, _ := attr(root, "bot"); bot != settings.Name {
+		t.Errorf("telegram.update bot = %q, want %q", bot, settings.Name)
+	}
+
+	for _, name := range []string{"cleanText", "MatchRules", "buildResponse", "telegram.Reply"} {
+		s := spans[name]
+		if s == nil {
+			t.Errorf("%s span not exported", name)
+			continue
+		}
+		if string(s.TraceId) != string(root.TraceId) || string(s.ParentSpanId) != string(root.SpanId) {
+			t.Errorf("%s span is not a child of telegram.update", name)
+		}
+	}
+	if s := spans["buildResponse"]; s != nil {
+		if lang, ok := attr(s, "lang"); !ok || lang == "" {
+			t.Errorf("buildResponse lang attribute is missing")
+		}
+	}
+}
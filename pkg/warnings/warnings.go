@@ -0,0 +1,155 @@
+package warnings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// entry — счётчик предупреждений одного пользователя в одном чате
+type entry struct {
+	Count   int       `json:"count"`   // число действующих предупреждений
+	Updated time.Time `json:"updated"` // время последнего предупреждения
+}
+
+// Store хранит счётчики предупреждений (действие warn) и сохраняет их в JSON-файл,
+// чтобы предупреждения переживали перезапуск. Файл создаётся при первом предупреждении.
+// Нулевой Store не пригоден к использованию — создавайте через Open.
+type Store struct {
+	mu      sync.Mutex
+	path    string           // путь к файлу
+	entries map[string]entry // ключ — бот:чат:пользователь
+}
+
+// Open загружает счётчики из файла path. Отсутствующий файл — пустой набор счётчиков.
+func Open(path string) (*Store, error) {
+	entries, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{path: path, entries: entries}, nil
+}
+
+// Reload переключает хранилище на другой файл, если путь изменился.
+// Возвращает true, если файл был перечитан. При ошибке чтения остаётся прежний файл.
+func (s *Store) Reload(path string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if path == s.path {
+		return false, nil
+	}
+	entries, err := load(path)
+	if err != nil {
+		return false, err
+	}
+	s.path, s.entries = path, entries
+	return true, nil
+}
+
+// Add добавляет пользователю предупреждение и сохраняет файл.
+//
+// Параметры:
+// - bot, chatID, userID: чей счётчик увеличивается
+// - ttl: если с последнего предупреждения прошло больше ttl, счётчик начинается заново (0 — не сбрасывается)
+// - now: текущее время
+//
+// Возвращает новое значение счётчика. Ошибка сохранения не отменяет предупреждение в памяти.
+func (s *Store) Add(bot string, chatID, userID int64, ttl time.Duration, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(bot, chatID, userID)
+	e := s.entries[k]
+	if ttl > 0 && now.Sub(e.Updated) > ttl {
+		e.Count = 0
+	}
+	e.Count++
+	e.Updated = now
+	s.entries[k] = e
+
+	// Заодно удаляем истёкшие счётчики, чтобы файл не рос бесконечно
+	if ttl > 0 {
+		for k, e := range s.entries {
+			if now.Sub(e.Updated) > ttl {
+				delete(s.entries, k)
+			}
+		}
+	}
+	return e.Count, s.save()
+}
+
+// Reset обнуляет счётчик пользователя (например, после последней ступени эскалации)
+func (s *Store) Reset(bot string, chatID, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(bot, chatID, userID)
+	if _, ok := s.entries[k]; !ok {
+		return nil
+	}
+	delete(s.entries, k)
+	return s.save()
+}
+
+// Count возвращает текущее число предупреждений пользователя
+func (s *Store) Count(bot string, chatID, userID int64) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[key(bot, chatID, userID)].Count
+}
+
+// key составляет ключ счётчика
+func key(bot string, chatID, userID int64) string {
+	return fmt.Sprintf("%s:%d:%d", bot, chatID, userID)
+}
+
+// load читает файл счётчиков
+func load(path string) (map[string]entry, error) {
+	entries := make(map[string]entry)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("warnings file: %w", err)
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("warnings file %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// save атомарно записывает счётчики: во временный файл рядом и переименованием.
+// Вызывается под s.mu.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("warnings file: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("warnings file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("warnings file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("warnings file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("warnings file: %w", err)
+	}
+	return nil
+}